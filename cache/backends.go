@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Memory keeps entries in a map for the life of the process
+type Memory struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+}
+
+// NewMemory creates an empty in-memory backend
+func NewMemory() *Memory {
+	return &Memory{entries: make(map[string]Entry)}
+}
+
+func (m *Memory) Get(key string) (Entry, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.entries[key]
+	return entry, ok
+}
+
+func (m *Memory) Set(key string, entry Entry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = entry
+}
+
+func (m *Memory) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+}
+
+// Disk stores each entry as a JSON file in dir, named by a hash of its key
+type Disk struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewDisk creates a backend writing to dir
+func NewDisk(dir string) *Disk {
+	return &Disk{dir: dir}
+}
+
+// diskEntry is the on-disk format; the key is kept for debugging
+type diskEntry struct {
+	Key string `json:"key"`
+	Entry
+}
+
+func (d *Disk) Get(key string) (Entry, bool) {
+	data, err := os.ReadFile(d.path(key))
+	if err != nil {
+		return Entry{}, false
+	}
+
+	var stored diskEntry
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Key != key {
+		return Entry{}, false
+	}
+	return stored.Entry, true
+}
+
+func (d *Disk) Set(key string, entry Entry) {
+	data, err := json.Marshal(diskEntry{Key: key, Entry: entry})
+	if err != nil {
+		log.Printf("cache: error encoding %s: %v", key, err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := os.MkdirAll(d.dir, 0o755); err != nil {
+		log.Printf("cache: error creating %s: %v", d.dir, err)
+		return
+	}
+
+	// Write to a temp file and rename so readers never see a partial entry
+	path := d.path(key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		log.Printf("cache: error writing %s: %v", key, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Printf("cache: error writing %s: %v", key, err)
+	}
+}
+
+func (d *Disk) Delete(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	os.Remove(d.path(key))
+}
+
+func (d *Disk) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:])+".json")
+}
@@ -0,0 +1,178 @@
+// Package cache is a TTL cache for upstream API responses. Entries are raw
+// response bodies keyed by endpoint plus query parameters, stored in memory
+// or on disk.
+//
+// An expired entry is still served for a grace period, chosen per call,
+// while a background refresh replaces it (stale-while-revalidate), so
+// callers only wait on the upstream API when nothing usable is cached.
+package cache
+
+import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a cached response body
+type Entry struct {
+	Value   []byte    `json:"value"`
+	Stored  time.Time `json:"stored"`
+	Expires time.Time `json:"expires"`
+}
+
+// Backend stores entries
+type Backend interface {
+	Get(key string) (Entry, bool)
+	Set(key string, entry Entry)
+	Delete(key string)
+}
+
+// FetchFunc loads a fresh value for a key
+type FetchFunc func(ctx context.Context) ([]byte, error)
+
+// refreshTimeout bounds fetches, which run apart from the requests
+// waiting on them and can outlive them
+const refreshTimeout = 2 * time.Minute
+
+// Cache serves entries from a Backend and fills it on a miss
+type Cache struct {
+	backend Backend
+	// maxFallback is how long past expiry an entry may still be served when
+	// a fetch fails
+	maxFallback time.Duration
+
+	mu       sync.Mutex
+	inflight map[string]*call
+}
+
+// call is a fetch in progress; concurrent misses for a key share it
+type call struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// New creates a cache over backend
+func New(backend Backend, maxFallback time.Duration) *Cache {
+	return &Cache{
+		backend:     backend,
+		maxFallback: maxFallback,
+		inflight:    make(map[string]*call),
+	}
+}
+
+// Fetch returns the value for key. A fresh entry is returned as is. An
+// entry less than staleFor past expiry is returned immediately and
+// refreshed in the background. Otherwise fetch is called and its result
+// cached for ttl. If fetch fails and the entry expired less than the
+// cache's fallback limit ago, that entry is returned instead of the error.
+func (c *Cache) Fetch(ctx context.Context, key string, ttl, staleFor time.Duration, fetch FetchFunc) ([]byte, error) {
+	now := time.Now()
+	entry, found := c.backend.Get(key)
+
+	if found && now.Before(entry.Expires) {
+		return entry.Value, nil
+	}
+
+	if found && now.Before(entry.Expires.Add(staleFor)) {
+		go func() {
+			refreshCtx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+			defer cancel()
+			if _, err := c.load(refreshCtx, key, ttl, fetch); err != nil {
+				log.Printf("cache: background refresh of %s failed: %v", key, err)
+			}
+		}()
+		return entry.Value, nil
+	}
+
+	value, err := c.load(ctx, key, ttl, fetch)
+	if err != nil {
+		if found && now.Before(entry.Expires.Add(c.maxFallback)) {
+			log.Printf("cache: serving expired %s after fetch failed: %v", key, err)
+			return entry.Value, nil
+		}
+		return nil, err
+	}
+	return value, nil
+}
+
+// Invalidate drops the entry for key
+func (c *Cache) Invalidate(key string) {
+	c.backend.Delete(key)
+}
+
+// load runs fetch for key, sharing the result with concurrent callers.
+// The fetch runs on its own context so one caller going away doesn't fail
+// the others or leave nothing cached; each caller only stops waiting when
+// its own context is done.
+func (c *Cache) load(ctx context.Context, key string, ttl time.Duration, fetch FetchFunc) ([]byte, error) {
+	c.mu.Lock()
+	current, ok := c.inflight[key]
+	if !ok {
+		current = &call{done: make(chan struct{})}
+		c.inflight[key] = current
+		go c.run(key, ttl, fetch, current)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-current.done:
+		return current.value, current.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run does a shared fetch and stores its result
+func (c *Cache) run(key string, ttl time.Duration, fetch FetchFunc, current *call) {
+	fetchCtx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+	defer cancel()
+
+	current.value, current.err = fetch(fetchCtx)
+	if current.err == nil {
+		now := time.Now()
+		c.backend.Set(key, Entry{
+			Value:   current.value,
+			Stored:  now,
+			Expires: now.Add(ttl),
+		})
+	}
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+	close(current.done)
+}
+
+var (
+	defaultOnce  sync.Once
+	defaultCache *Cache
+)
+
+// defaultMaxFallback is how long past expiry entries are served when the
+// upstream API is failing
+const defaultMaxFallback = 6 * time.Hour
+
+// Default returns the process-wide cache. CACHE_BACKEND=disk stores entries
+// under CACHE_DIR (default data/cache) so they survive restarts; anything
+// else keeps them in memory.
+func Default() *Cache {
+	defaultOnce.Do(func() {
+		var backend Backend
+		switch strings.ToLower(os.Getenv("CACHE_BACKEND")) {
+		case "disk":
+			dir := os.Getenv("CACHE_DIR")
+			if dir == "" {
+				dir = "data/cache"
+			}
+			backend = NewDisk(dir)
+		default:
+			backend = NewMemory()
+		}
+		defaultCache = New(backend, defaultMaxFallback)
+	})
+	return defaultCache
+}
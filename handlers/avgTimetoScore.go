@@ -1,100 +1,72 @@
 package handlers
 
 import (
-	"encoding/csv"
 	"net/http"
-	"os"
-	"strconv"
-	"strings"
 
 	"github.com/labstack/echo/v4"
 )
 
 type TimeToScoreStats struct {
 	Team                   string  `json:"team"`
-	TotalGoalTime          int     `json:"total_goal_time"`
+	TotalGoalTime          float64 `json:"total_goal_time"` // Minutes
 	Goals                  int     `json:"goals"`
 	AverageTimeToScore     float64 `json:"average_time_to_score"`
-	TotalFirstGoalTime     int     `json:"total_first_goal_time"`
+	TotalFirstGoalTime     float64 `json:"total_first_goal_time"`
 	FirstGoals             int     `json:"first_goals"`
 	AverageTimeToFirstGoal float64 `json:"average_time_to_first_goal"`
 }
 
-// current season data
+// ProcessTimeToScoreHandler returns average goal and first-goal times per team
+// for the shots matching the filter query parameters. Times are minutes
+// into the game; /nhl/props has the first-goal probabilities.
 func ProcessTimeToScoreHandler(c echo.Context) error {
-	file, err := os.Open("data/march3.csv")
+	allShots, err := datasetShots(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to open CSV file"})
+		return shotLoadError(c, err)
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	shots, filter, err := filterShotsFromQuery(c, allShots)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to parse CSV"})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	if len(records) < 2 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV file is empty or invalid"})
-	}
-
-	columns := records[0]
-	eventIdx := findColumnIndex(columns, "event")
-	timeIdx := findColumnIndex(columns, "time")
-	teamIdx := findColumnIndex(columns, "teamCode")
-	seasonIdx := findColumnIndex(columns, "season")
-	gameIDIdx := findColumnIndex(columns, "game_id")
-
-	if eventIdx == -1 || timeIdx == -1 || teamIdx == -1 || seasonIdx == -1 || gameIDIdx == -1 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV does not contain required columns"})
-	}
-
-	// Assume current season is "2024". This could be dynamically retrieved if needed.
-	currentSeason := "2024"
-
 	stats := make(map[string]*TimeToScoreStats)
-	firstGoalTracker := make(map[string]bool) // Tracks if a first goal has been recorded for a game
-
-	for _, record := range records[1:] {
-		event := record[eventIdx]
-		time, err := strconv.Atoi(record[timeIdx])
-		if err != nil {
-			continue
+	team := func(code string) *TimeToScoreStats {
+		if _, ok := stats[code]; !ok {
+			stats[code] = &TimeToScoreStats{Team: code}
 		}
-		timeInMinutes := time / 60
-		team := record[teamIdx]
-		season := record[seasonIdx]
-		gameID := record[gameIDIdx]
+		return stats[code]
+	}
 
-		// Filter by current season and only consider "goal" events
-		if strings.ToLower(season) != currentSeason || strings.ToLower(event) != "goal" {
+	for _, shot := range shots {
+		if !shot.Goal {
 			continue
 		}
+		stat := team(shot.TeamCode)
+		stat.TotalGoalTime += float64(shot.GameSeconds) / 60
+		stat.Goals++
+	}
 
-		if _, ok := stats[team]; !ok {
-			stats[team] = &TimeToScoreStats{Team: team}
-		}
-
-		// Track total goal time and goals
-		stats[team].TotalGoalTime += timeInMinutes
-		stats[team].Goals++
-
-		// Check for the first goal in the game
-		if !firstGoalTracker[gameID] {
-			firstGoalTracker[gameID] = true
-			stats[team].TotalFirstGoalTime += timeInMinutes
-			stats[team].FirstGoals++
+	// The first goal is the earliest in each game, not the first row read
+	for _, game := range propGames(shots) {
+		if game.firstTeam == "" {
+			continue
 		}
+		stat := team(game.firstTeam)
+		stat.TotalFirstGoalTime += float64(game.firstSeconds) / 60
+		stat.FirstGoals++
 	}
 
 	// Calculate averages
-	for _, stat := range stats {
-		if stat.Goals > 0 {
-			stat.AverageTimeToScore = float64(stat.TotalGoalTime) / float64(stat.Goals)
-		}
-		if stat.FirstGoals > 0 {
-			stat.AverageTimeToFirstGoal = float64(stat.TotalFirstGoalTime) / float64(stat.FirstGoals)
+	for code, stat := range stats {
+		if !filter.includesTeam(code) {
+			delete(stats, code)
+			continue
 		}
+		stat.TotalGoalTime = round2(stat.TotalGoalTime)
+		stat.TotalFirstGoalTime = round2(stat.TotalFirstGoalTime)
+		stat.AverageTimeToScore = round2(safeDiv(stat.TotalGoalTime, float64(stat.Goals)))
+		stat.AverageTimeToFirstGoal = round2(safeDiv(stat.TotalFirstGoalTime, float64(stat.FirstGoals)))
 	}
 
 	return c.JSON(http.StatusOK, stats)
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Chance origins, in the order a shot is checked against them
+const (
+	originRebound   = "rebound"
+	originRush      = "rush"
+	originForecheck = "forecheck"
+	originCycle     = "cycle"
+)
+
+var chanceOrigins = []string{originRebound, originRush, originForecheck, originCycle}
+
+// forecheckWindow is how soon after a takeaway or giveaway a shot has to
+// come to count as off the turnover
+const forecheckWindow = 5
+
+// defaultMinChanceShots is the attempts a player needs to be ranked
+const defaultMinChanceShots = 50
+
+// chanceOrigin classifies how a chance came about. MoneyPuck's rebound and
+// rush flags come first; a quick shot after a takeaway or giveaway that
+// wasn't a rush is a forecheck turnover; everything else came from
+// sustained possession, which is counted as the cycle.
+func chanceOrigin(shot ShotData) string {
+	switch {
+	case shot.ShotRebound:
+		return originRebound
+	case shot.ShotRush:
+		return originRush
+	case (shot.LastEventCategory == "TAKE" || shot.LastEventCategory == "GIVE") && shot.TimeSinceLastEvent <= forecheckWindow:
+		return originForecheck
+	default:
+		return originCycle
+	}
+}
+
+// ChanceOriginStats are the attempts from one chance origin
+type ChanceOriginStats struct {
+	Attempts     int     `json:"attempts"`
+	Goals        int     `json:"goals"`
+	XGoals       float64 `json:"xGoals"`
+	XGoalShare   float64 `json:"xGoalShare"` // Percent of all xG
+	XGPerAttempt float64 `json:"xgPerAttempt"`
+	XGPerGame    float64 `json:"xgPerGame"`
+	Rank         int     `json:"rank,omitempty"` // By xG per game
+}
+
+// ChanceStats describe where a set of shots came from
+type ChanceStats struct {
+	Attempts    int     `json:"attempts"`
+	ShotsOnGoal int     `json:"shotsOnGoal"`
+	Goals       int     `json:"goals"`
+	XGoals      float64 `json:"xGoals"`
+
+	// Rebound generation: shots on goal the goalie gave a rebound on
+	ReboundsGenerated     int     `json:"reboundsGenerated"`
+	ReboundGenerationRate float64 `json:"reboundGenerationRate"` // Percent of shots on goal
+	ReboundGenerationRank int     `json:"reboundGenerationRank,omitempty"`
+
+	// Rebound conversion: goals scored on rebound attempts
+	ReboundShots          int     `json:"reboundShots"`
+	ReboundGoals          int     `json:"reboundGoals"`
+	ReboundConversion     float64 `json:"reboundConversion"` // Percent of rebound attempts
+	ReboundConversionRank int     `json:"reboundConversionRank,omitempty"`
+
+	RushShots     int     `json:"rushShots"`
+	RushShare     float64 `json:"rushShare"` // Percent of attempts
+	RushShareRank int     `json:"rushShareRank,omitempty"`
+
+	Origins map[string]*ChanceOriginStats `json:"origins"`
+}
+
+// chanceTotals accumulates a ChanceStats
+type chanceTotals struct {
+	attempts, shotsOnGoal, goals int
+	xGoals                       float64
+	reboundsGenerated            int
+	reboundShots, reboundGoals   int
+	rushShots                    int
+	origins                      map[string]*ChanceOriginStats
+}
+
+func newChanceTotals() *chanceTotals {
+	t := &chanceTotals{origins: make(map[string]*ChanceOriginStats, len(chanceOrigins))}
+	for _, origin := range chanceOrigins {
+		t.origins[origin] = &ChanceOriginStats{}
+	}
+	return t
+}
+
+func (t *chanceTotals) add(shot ShotData) {
+	xGoal := 0.0
+	if !isBlockedShot(shot) {
+		xGoal = shot.XGoal
+	}
+	t.attempts++
+	t.xGoals += xGoal
+	if isShotOnGoal(shot) {
+		t.shotsOnGoal++
+		if shot.ShotGeneratedRebound {
+			t.reboundsGenerated++
+		}
+	}
+	if shot.Goal {
+		t.goals++
+	}
+	if shot.ShotRebound {
+		t.reboundShots++
+		if shot.Goal {
+			t.reboundGoals++
+		}
+	}
+	if shot.ShotRush {
+		t.rushShots++
+	}
+
+	origin := t.origins[chanceOrigin(shot)]
+	origin.Attempts++
+	origin.XGoals += xGoal
+	if shot.Goal {
+		origin.Goals++
+	}
+}
+
+func (t *chanceTotals) result(games int) *ChanceStats {
+	stats := &ChanceStats{
+		Attempts:              t.attempts,
+		ShotsOnGoal:           t.shotsOnGoal,
+		Goals:                 t.goals,
+		XGoals:                round2(t.xGoals),
+		ReboundsGenerated:     t.reboundsGenerated,
+		ReboundGenerationRate: round1(safeDiv(float64(t.reboundsGenerated), float64(t.shotsOnGoal)) * 100),
+		ReboundShots:          t.reboundShots,
+		ReboundGoals:          t.reboundGoals,
+		ReboundConversion:     round1(safeDiv(float64(t.reboundGoals), float64(t.reboundShots)) * 100),
+		RushShots:             t.rushShots,
+		RushShare:             round1(safeDiv(float64(t.rushShots), float64(t.attempts)) * 100),
+		Origins:               make(map[string]*ChanceOriginStats, len(t.origins)),
+	}
+	for key, o := range t.origins {
+		stats.Origins[key] = &ChanceOriginStats{
+			Attempts:     o.Attempts,
+			Goals:        o.Goals,
+			XGoals:       round2(o.XGoals),
+			XGoalShare:   round1(safeDiv(o.XGoals, t.xGoals) * 100),
+			XGPerAttempt: round3(safeDiv(o.XGoals, float64(o.Attempts))),
+			XGPerGame:    round2(safeDiv(o.XGoals, float64(games))),
+		}
+	}
+	return stats
+}
+
+// assignRanks ranks stats on every rate, 1 being the highest. With
+// ascending set 1 is the lowest instead, for stats allowed. Ties share a
+// rank.
+func assignRanks(stats []*ChanceStats, ascending bool) {
+	rank := func(value func(s *ChanceStats) float64, set func(s *ChanceStats, rank int)) {
+		rankBy(stats, ascending, value, set)
+	}
+
+	rank(func(s *ChanceStats) float64 { return s.ReboundGenerationRate },
+		func(s *ChanceStats, r int) { s.ReboundGenerationRank = r })
+	rank(func(s *ChanceStats) float64 { return s.ReboundConversion },
+		func(s *ChanceStats, r int) { s.ReboundConversionRank = r })
+	rank(func(s *ChanceStats) float64 { return s.RushShare },
+		func(s *ChanceStats, r int) { s.RushShareRank = r })
+	for _, origin := range chanceOrigins {
+		rank(func(s *ChanceStats) float64 { return s.Origins[origin].XGPerGame },
+			func(s *ChanceStats, r int) { s.Origins[origin].Rank = r })
+	}
+}
+
+// TeamChances are a team's chance origins for and against
+type TeamChances struct {
+	Team        string       `json:"team"`
+	GamesPlayed int          `json:"gamesPlayed"`
+	For         *ChanceStats `json:"for"`
+	Against     *ChanceStats `json:"against"`
+}
+
+// PlayerChances are a shooter's chance origins
+type PlayerChances struct {
+	PlayerID    string `json:"playerId"`
+	PlayerName  string `json:"playerName"`
+	Position    string `json:"position"`
+	TeamCode    string `json:"teamCode"`
+	GamesPlayed int    `json:"gamesPlayed"` // Games with an attempt
+	*ChanceStats
+}
+
+var chancesMetadata = map[string]interface{}{
+	"origins":               "Each attempt has one origin, checked in order: rebound and rush use MoneyPuck's flags; forecheck is a non-rush attempt within 5 seconds of a takeaway or giveaway; cycle is everything else",
+	"reboundGenerationRate": "Shots on goal that gave up a rebound; for teams' against side, rebounds their goalies allowed",
+	"ranks":                 "1 is the highest rate, except on teams' against side where 1 is the lowest",
+	"xGoals":                "Unblocked attempts only",
+}
+
+// ProcessChancesHandler reports rebound, rush and chance origin stats for
+// every team, for and against, ranked across the league.
+//
+// Query params: the shared shot filters.
+func ProcessChancesHandler(c echo.Context) error {
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	forTotals := make(map[string]*chanceTotals)
+	againstTotals := make(map[string]*chanceTotals)
+	games := make(map[string]map[string]bool)
+	league := newChanceTotals()
+	leagueGames := make(map[string]bool)
+	totals := func(m map[string]*chanceTotals, team string) *chanceTotals {
+		t, ok := m[team]
+		if !ok {
+			t = newChanceTotals()
+			m[team] = t
+		}
+		return t
+	}
+
+	for _, shot := range shots {
+		if shot.TeamCode == "" {
+			continue
+		}
+		defending := defendingTeam(shot)
+		totals(forTotals, shot.TeamCode).add(shot)
+		totals(againstTotals, defending).add(shot)
+		league.add(shot)
+		leagueGames[gameKey(shot)] = true
+		for _, team := range []string{shot.TeamCode, defending} {
+			if games[team] == nil {
+				games[team] = make(map[string]bool)
+			}
+			games[team][gameKey(shot)] = true
+		}
+	}
+
+	teams := make([]*TeamChances, 0, len(games))
+	forStats := make([]*ChanceStats, 0, len(games))
+	againstStats := make([]*ChanceStats, 0, len(games))
+	for team, played := range games {
+		t := &TeamChances{
+			Team:        team,
+			GamesPlayed: len(played),
+			For:         totals(forTotals, team).result(len(played)),
+			Against:     totals(againstTotals, team).result(len(played)),
+		}
+		teams = append(teams, t)
+		forStats = append(forStats, t.For)
+		againstStats = append(againstStats, t.Against)
+	}
+	assignRanks(forStats, false)
+	assignRanks(againstStats, true)
+
+	sort.Slice(teams, func(i, j int) bool {
+		if teams[i].For.XGoals != teams[j].For.XGoals {
+			return teams[i].For.XGoals > teams[j].For.XGoals
+		}
+		return teams[i].Team < teams[j].Team
+	})
+	filtered := make([]*TeamChances, 0, len(teams))
+	for _, team := range teams {
+		if filter.includesTeam(team.Team) {
+			filtered = append(filtered, team)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"teams": filtered,
+		// League rates are per team-game so they line up with a team's
+		"league":   league.result(2 * len(leagueGames)),
+		"metadata": chancesMetadata,
+	})
+}
+
+// PlayerChancesHandler reports rebound, rush and chance origin stats for
+// shooters, ranked among those with enough attempts.
+//
+// Query params: the shared shot filters, player (shooter ID) and minShots
+// (attempts to be ranked, default 50).
+func PlayerChancesHandler(c echo.Context) error {
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	minShots := defaultMinChanceShots
+	if value := c.QueryParam("minShots"); value != "" {
+		minShots, err = strconv.Atoi(value)
+		if err != nil || minShots < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "minShots must be a non-negative number"})
+		}
+	}
+	playerID := strings.TrimSpace(c.QueryParam("player"))
+
+	players := make(map[string]*PlayerChances)
+	totals := make(map[string]*chanceTotals)
+	games := make(map[string]map[string]bool)
+	for _, shot := range playerShotRows(shots) {
+		id := shot.ShooterPlayerID
+		if _, ok := players[id]; !ok {
+			players[id] = &PlayerChances{
+				PlayerID:   id,
+				PlayerName: shot.ShooterName,
+				Position:   shot.PlayerPosition,
+				TeamCode:   shot.TeamCode,
+			}
+			totals[id] = newChanceTotals()
+			games[id] = make(map[string]bool)
+		}
+		totals[id].add(shot)
+		games[id][gameKey(shot)] = true
+	}
+
+	ranked := make([]*PlayerChances, 0, len(players))
+	stats := make([]*ChanceStats, 0, len(players))
+	for id, player := range players {
+		if totals[id].attempts < minShots {
+			continue
+		}
+		player.GamesPlayed = len(games[id])
+		player.ChanceStats = totals[id].result(player.GamesPlayed)
+		ranked = append(ranked, player)
+		stats = append(stats, player.ChanceStats)
+	}
+	assignRanks(stats, false)
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].XGoals != ranked[j].XGoals {
+			return ranked[i].XGoals > ranked[j].XGoals
+		}
+		return ranked[i].PlayerID < ranked[j].PlayerID
+	})
+	filtered := make([]*PlayerChances, 0, len(ranked))
+	for _, player := range ranked {
+		if !filter.includesTeam(player.TeamCode) || (playerID != "" && player.PlayerID != playerID) {
+			continue
+		}
+		filtered = append(filtered, player)
+	}
+	if playerID != "" && len(filtered) == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": "No ranked shooter " + playerID + "; lower minShots to include players with fewer attempts",
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"players":  filtered,
+		"rankedOf": len(ranked),
+		"minShots": minShots,
+		"metadata": chancesMetadata,
+	})
+}
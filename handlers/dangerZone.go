@@ -1,118 +1,100 @@
 package handlers
 
 import (
-	"encoding/csv"
-	"math"
 	"net/http"
-	"os"
 	"sort"
-	"strconv"
-	"strings"
 
 	"github.com/labstack/echo/v4"
 )
 
+// DangerZoneStats is a team's shot danger profile. The "allowed" fields
+// count the high-danger tier against the team; Tiers break every tier
+// down for and against.
 type DangerZoneStats struct {
-	Team                     string  `json:"team"`
-	TotalShotsAllowed        int     `json:"total_shots_allowed"`
-	DangerZoneShotsAllowed   int     `json:"danger_zone_shots_allowed"`
-	DangerZoneBlocked        int     `json:"danger_zone_blocked"`
-	DangerZonePercentage     float64 `json:"danger_zone_percentage"`
-	AdjustedDangerPercentage float64 `json:"adjusted_danger_percentage"`
-	Rank                     int     `json:"rank"`
+	Team                     string                      `json:"team"`
+	TotalShotsAllowed        int                         `json:"total_shots_allowed"`
+	DangerZoneShotsAllowed   int                         `json:"danger_zone_shots_allowed"`
+	DangerZoneBlocked        int                         `json:"danger_zone_blocked"`
+	DangerZonePercentage     float64                     `json:"danger_zone_percentage"`
+	AdjustedDangerPercentage float64                     `json:"adjusted_danger_percentage"`
+	Rank                     int                         `json:"rank"`
+	Tiers                    map[string]*DangerTierStats `json:"tiers"`
+}
+
+// DangerTierStats counts shot attempts, goals and xG in one danger tier
+type DangerTierStats struct {
+	ShotsFor      int     `json:"shots_for"`
+	GoalsFor      int     `json:"goals_for"`
+	XGoalsFor     float64 `json:"xgoals_for"`
+	ShotsAgainst  int     `json:"shots_against"`
+	GoalsAgainst  int     `json:"goals_against"`
+	XGoalsAgainst float64 `json:"xgoals_against"`
 }
 
 func ProcessDangerZone(c echo.Context) error {
-	file, err := os.Open("data/march3.csv")
+	allShots, err := datasetShots(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to open CSV file"})
+		return shotLoadError(c, err)
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	shots, filter, err := filterShotsFromQuery(c, allShots)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to parse CSV"})
-	}
-
-	if len(records) < 2 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV file is empty or invalid"})
-	}
-
-	columns := records[0]
-	teamIdx := findColumnIndex(columns, "teamCode") // Updated from "defendingTeam"
-	distanceIdx := findColumnIndex(columns, "arenaAdjustedShotDistance")
-	angleIdx := findColumnIndex(columns, "shotAngleAdjusted")
-	shotTypeIdx := findColumnIndex(columns, "shotType")
-
-	if teamIdx == -1 || distanceIdx == -1 || angleIdx == -1 || shotTypeIdx == -1 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV does not contain required columns"})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
 	stats := make(map[string]*DangerZoneStats)
-
-	for _, record := range records[1:] {
-		if len(record) <= max(teamIdx, distanceIdx, angleIdx, shotTypeIdx) { // Prevent index out of range
-			continue
+	team := func(code string) *DangerZoneStats {
+		s, ok := stats[code]
+		if !ok {
+			s = &DangerZoneStats{Team: code, Tiers: make(map[string]*DangerTierStats)}
+			for _, tier := range dangerTiers {
+				s.Tiers[tier] = &DangerTierStats{}
+			}
+			stats[code] = s
 		}
+		return s
+	}
 
-		team := record[teamIdx]
-		if team == "" {
+	for _, shot := range shots {
+		if shot.TeamCode == "" || defendingTeam(shot) == "" {
 			continue
 		}
+		shooter := team(shot.TeamCode)
+		defender := team(defendingTeam(shot))
 
-		shotDistance, err1 := strconv.ParseFloat(record[distanceIdx], 64)
-		shotAngle, err2 := strconv.ParseFloat(record[angleIdx], 64)
-		if err1 != nil || err2 != nil {
-			continue // Skip invalid rows
-		}
-
-		shotType := strings.ToLower(record[shotTypeIdx]) // Normalize for case insensitivity
-		isBlocked := shotType == "blocked"
-
-		// Consider only deflections, tips, rebounds, and regular shots
-		if !(shotType == "shot" || shotType == "deflection" || shotType == "tip" || shotType == "rebound" || shotType == "wrist" || shotType == "snap" || shotType == "slap" || shotType == "back") {
-			continue
-		}
+		tier := shotDangerTier(shot)
+		isBlocked := isBlockedShot(shot)
 
-		if _, exists := stats[team]; !exists {
-			stats[team] = &DangerZoneStats{Team: team}
-		}
+		shooter.Tiers[tier].ShotsFor++
+		defender.Tiers[tier].ShotsAgainst++
 		if !isBlocked {
-			stats[team].TotalShotsAllowed++
+			shooter.Tiers[tier].XGoalsFor += shot.XGoal
+			defender.Tiers[tier].XGoalsAgainst += shot.XGoal
 		}
-		stats[team].TotalShotsAllowed++
-
-		// Danger zone: distance <= 10 or angle between 30-60
-		// if (shotDistance >= 10 && shotDistance <= 20) || math.Abs(shotAngle) <=22.5 {
-		// 	stats[team].DangerZoneShotsAllowed++
-		// }
-		isDangerZone := false
-
-		switch {
-		case shotDistance <= 20 && math.Abs(shotAngle) <= 45:
-			// Inner slot area - highest danger
-			isDangerZone = true
-		case shotDistance <= 30 && math.Abs(shotAngle) <= 35:
-			// Moderate distance but good angle
-			isDangerZone = true
-		case (shotType == "tip" || shotType == "deflection") && shotDistance <= 25:
-			// Tips and deflections are dangerous from slightly further out
-			isDangerZone = true
+		if shot.Goal {
+			shooter.Tiers[tier].GoalsFor++
+			defender.Tiers[tier].GoalsAgainst++
 		}
 
-		// if isDangerZone {
-		// 	stats[team].DangerZoneShotsAllowed++
-		// }
-		if isDangerZone {
+		if !isBlocked {
+			defender.TotalShotsAllowed++
+		}
+		if tier == dangerHigh {
 			if isBlocked {
-				stats[team].DangerZoneBlocked++
+				defender.DangerZoneBlocked++
 			} else {
-				stats[team].DangerZoneShotsAllowed++
+				defender.DangerZoneShotsAllowed++
 			}
 		}
 	}
 
+	for _, stat := range stats {
+		for _, tier := range stat.Tiers {
+			tier.XGoalsFor = round2(tier.XGoalsFor)
+			tier.XGoalsAgainst = round2(tier.XGoalsAgainst)
+		}
+	}
+
 	var statsList []*DangerZoneStats
 	// Compute percentages
 	// for _, stat := range stats {
@@ -144,18 +126,14 @@ func ProcessDangerZone(c echo.Context) error {
 		stat.Rank = i + 1
 	}
 
-	return c.JSON(http.StatusOK, stats)
-}
-
-// max returns the maximum value of given integers
-func max(nums ...int) int {
-	maxNum := nums[0]
-	for _, num := range nums {
-		if num > maxNum {
-			maxNum = num
+	// Rank against the whole league, then trim to the requested team
+	for team := range stats {
+		if !filter.includesTeam(team) {
+			delete(stats, team)
 		}
 	}
-	return maxNum
+
+	return c.JSON(http.StatusOK, stats)
 }
 
 // According to data most goals(34.3 %) occur within 10 to 20 feet of
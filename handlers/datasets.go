@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultDatasetID selects the shot file the server was started with
+const defaultDatasetID = "default"
+
+// maxDatasetBytes caps an upload; a full MoneyPuck season is a few hundred
+// megabytes
+const maxDatasetBytes = 1 << 30
+
+// requiredDatasetColumns are the MoneyPuck shot columns an upload must
+// have. The rest are optional; xGoal is filled in by the in-repo model
+// when it's missing.
+var requiredDatasetColumns = []string{
+	"game_id", "season", "event", "time", "period", "goal",
+	"teamCode", "homeTeamCode", "awayTeamCode",
+	"shooterPlayerId", "playerPositionThatDidEvent",
+}
+
+// ErrUnknownDataset is returned when a dataset ID isn't registered
+var ErrUnknownDataset = errors.New("unknown dataset")
+
+// Dataset is a shot CSV the NHL routes can read from
+type Dataset struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Rows       int       `json:"rows"`
+	Seasons    []string  `json:"seasons"`
+	Size       int64     `json:"size"` // Bytes
+	UploadedAt time.Time `json:"uploadedAt"`
+	Default    bool      `json:"default,omitempty"`
+
+	// path is where the file lives; it's never taken from a request
+	path string
+}
+
+// datasetDir is where uploads and the registry index are kept:
+// NHL_DATASET_DIR, default data/datasets
+func datasetDir() string {
+	if dir := os.Getenv("NHL_DATASET_DIR"); dir != "" {
+		return dir
+	}
+	return "data/datasets"
+}
+
+// datasetRegistry tracks uploaded datasets in an index file next to them
+var datasetRegistry struct {
+	sync.Mutex
+	loaded   bool
+	datasets map[string]*Dataset
+}
+
+func datasetIndexPath() string {
+	return filepath.Join(datasetDir(), "index.json")
+}
+
+// loadDatasetIndex reads the index on first use. Callers must hold the
+// registry lock.
+func loadDatasetIndex() error {
+	if datasetRegistry.loaded {
+		return nil
+	}
+	datasetRegistry.datasets = make(map[string]*Dataset)
+
+	data, err := os.ReadFile(datasetIndexPath())
+	if errors.Is(err, os.ErrNotExist) {
+		datasetRegistry.loaded = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading dataset index: %v", err)
+	}
+
+	var datasets []*Dataset
+	if err := json.Unmarshal(data, &datasets); err != nil {
+		return fmt.Errorf("error parsing dataset index: %v", err)
+	}
+	for _, dataset := range datasets {
+		dataset.path = filepath.Join(datasetDir(), dataset.ID+".csv")
+		datasetRegistry.datasets[dataset.ID] = dataset
+	}
+	datasetRegistry.loaded = true
+	return nil
+}
+
+// saveDatasetIndex writes the index. Callers must hold the registry lock.
+func saveDatasetIndex() error {
+	datasets := make([]*Dataset, 0, len(datasetRegistry.datasets))
+	for _, dataset := range datasetRegistry.datasets {
+		datasets = append(datasets, dataset)
+	}
+	sort.Slice(datasets, func(i, j int) bool { return datasets[i].UploadedAt.Before(datasets[j].UploadedAt) })
+
+	data, err := json.MarshalIndent(datasets, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := datasetIndexPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing dataset index: %v", err)
+	}
+	return os.Rename(tmp, datasetIndexPath())
+}
+
+// datasetPath returns the shot file for a dataset ID; empty and "default"
+// are the server's default file
+func datasetPath(id string) (string, error) {
+	if id == "" || id == defaultDatasetID {
+		return defaultShotDataPath, nil
+	}
+
+	datasetRegistry.Lock()
+	defer datasetRegistry.Unlock()
+	if err := loadDatasetIndex(); err != nil {
+		return "", err
+	}
+	dataset, ok := datasetRegistry.datasets[id]
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownDataset, id)
+	}
+	return dataset.path, nil
+}
+
+// datasetShots returns the rows of the dataset selected by the dataset
+// query parameter, or the default dataset
+func datasetShots(c echo.Context) ([]ShotData, error) {
+	path, err := datasetPath(strings.TrimSpace(c.QueryParam("dataset")))
+	if err != nil {
+		return nil, err
+	}
+	return loadShots(path)
+}
+
+// shotLoadError responds to a failure from datasetShots
+func shotLoadError(c echo.Context, err error) error {
+	if errors.Is(err, ErrUnknownDataset) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to load shot data"})
+}
+
+// newDatasetID returns a short random ID
+func newDatasetID() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "ds-" + hex.EncodeToString(b), nil
+}
+
+// checkDatasetHeader reads the header row of a CSV and reports any
+// required columns it lacks
+func checkDatasetHeader(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	headers, err := csv.NewReader(file).Read()
+	if err != nil {
+		return fmt.Errorf("file is not a CSV: %v", err)
+	}
+	var missing []string
+	for _, column := range requiredDatasetColumns {
+		if findColumnIndex(headers, column) < 0 {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("not a MoneyPuck shot export, missing columns: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+var datasetNamePattern = regexp.MustCompile(`[^A-Za-z0-9 ._-]`)
+
+// UploadDatasetHandler registers a MoneyPuck shot CSV uploaded as the
+// multipart field file. The header is checked against the expected
+// columns and the whole file parsed before it's accepted.
+//
+// Form fields: file (required) and name (default the uploaded file name).
+func UploadDatasetHandler(c echo.Context) error {
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, maxDatasetBytes)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "A CSV must be uploaded in the file field"})
+	}
+	name := strings.TrimSpace(c.FormValue("name"))
+	if name == "" {
+		name = filepath.Base(header.Filename)
+	}
+	name = datasetNamePattern.ReplaceAllString(name, "")
+
+	upload, err := header.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read upload"})
+	}
+	defer upload.Close()
+
+	id, err := newDatasetID()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create dataset ID"})
+	}
+	if err := os.MkdirAll(datasetDir(), 0o755); err != nil {
+		log.Printf("Error creating %s: %v", datasetDir(), err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to store dataset"})
+	}
+
+	path := filepath.Join(datasetDir(), id+".csv")
+	tmp := path + ".upload"
+	file, err := os.Create(tmp)
+	if err != nil {
+		log.Printf("Error creating %s: %v", tmp, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to store dataset"})
+	}
+	size, err := io.Copy(file, upload)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Failed to read upload"})
+	}
+
+	if err := checkDatasetHeader(tmp); err != nil {
+		os.Remove(tmp)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		log.Printf("Error storing dataset %s: %v", id, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to store dataset"})
+	}
+
+	// Parse it now so a bad file is rejected and the first query is fast
+	store := shotStoreFor(path)
+	if err := store.Load(); err != nil {
+		forgetShotStore(path)
+		os.Remove(path)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	shots, err := store.Shots()
+	if err != nil || len(shots) == 0 {
+		forgetShotStore(path)
+		os.Remove(path)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "The file has no usable shot rows"})
+	}
+
+	seasons := make(map[string]bool)
+	for _, shot := range shots {
+		seasons[shot.Season] = true
+	}
+	dataset := &Dataset{
+		ID:         id,
+		Name:       name,
+		Rows:       len(shots),
+		Seasons:    sortedKeys(seasons),
+		Size:       size,
+		UploadedAt: time.Now().UTC(),
+		path:       path,
+	}
+
+	datasetRegistry.Lock()
+	defer datasetRegistry.Unlock()
+	if err := loadDatasetIndex(); err != nil {
+		log.Printf("Error registering dataset %s: %v", id, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to register dataset"})
+	}
+	datasetRegistry.datasets[id] = dataset
+	if err := saveDatasetIndex(); err != nil {
+		delete(datasetRegistry.datasets, id)
+		log.Printf("Error registering dataset %s: %v", id, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to register dataset"})
+	}
+
+	return c.JSON(http.StatusCreated, dataset)
+}
+
+// ListDatasetsHandler lists the default dataset and every upload, oldest
+// first
+func ListDatasetsHandler(c echo.Context) error {
+	datasets := []*Dataset{{ID: defaultDatasetID, Name: filepath.Base(defaultShotDataPath), Default: true}}
+	if info, err := os.Stat(defaultShotDataPath); err == nil {
+		datasets[0].Size = info.Size()
+		datasets[0].UploadedAt = info.ModTime().UTC()
+		if shots, err := loadShots(defaultShotDataPath); err == nil {
+			seasons := make(map[string]bool)
+			for _, shot := range shots {
+				seasons[shot.Season] = true
+			}
+			datasets[0].Rows = len(shots)
+			datasets[0].Seasons = sortedKeys(seasons)
+		}
+	}
+
+	datasetRegistry.Lock()
+	defer datasetRegistry.Unlock()
+	if err := loadDatasetIndex(); err != nil {
+		log.Printf("Error listing datasets: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to list datasets"})
+	}
+	uploads := make([]*Dataset, 0, len(datasetRegistry.datasets))
+	for _, dataset := range datasetRegistry.datasets {
+		uploads = append(uploads, dataset)
+	}
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].UploadedAt.Before(uploads[j].UploadedAt) })
+
+	return c.JSON(http.StatusOK, append(datasets, uploads...))
+}
+
+// DeleteDatasetHandler removes an uploaded dataset and its file. The
+// default dataset can't be deleted.
+func DeleteDatasetHandler(c echo.Context) error {
+	id := c.Param("id")
+	if id == defaultDatasetID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "The default dataset can't be deleted"})
+	}
+
+	datasetRegistry.Lock()
+	defer datasetRegistry.Unlock()
+	if err := loadDatasetIndex(); err != nil {
+		log.Printf("Error deleting dataset %s: %v", id, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete dataset"})
+	}
+	dataset, ok := datasetRegistry.datasets[id]
+	if !ok {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("%v %q", ErrUnknownDataset, id)})
+	}
+
+	delete(datasetRegistry.datasets, id)
+	if err := saveDatasetIndex(); err != nil {
+		datasetRegistry.datasets[id] = dataset
+		log.Printf("Error deleting dataset %s: %v", id, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to delete dataset"})
+	}
+	forgetShotStore(dataset.path)
+	if err := os.Remove(dataset.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Dataset %s unregistered but its file wasn't removed: %v", id, err)
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
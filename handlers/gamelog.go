@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Rolling windows and EWMA span used by the team game log by default
+var defaultFormWindows = []int{5, 10, 20}
+
+const defaultEWMASpan = 10
+
+// Decisions in a game log
+const (
+	decisionRegulation = "REG"
+	decisionOvertime   = "OT"
+	decisionShootout   = "SO"
+)
+
+// GameTeamTotals is one side's shot counts in a game
+type GameTeamTotals struct {
+	Attempts          int     `json:"attempts"` // Corsi
+	UnblockedAttempts int     `json:"unblockedAttempts"`
+	ShotsOnGoal       int     `json:"shotsOnGoal"`
+	Goals             int     `json:"goals"`
+	XGoals            float64 `json:"xGoals"`
+}
+
+// GameSummary is a game rebuilt from its shots
+type GameSummary struct {
+	GameID   string         `json:"gameId"`
+	Season   string         `json:"season"`
+	Date     string         `json:"date,omitempty"`
+	Playoffs bool           `json:"playoffs"`
+	HomeTeam string         `json:"homeTeam"`
+	AwayTeam string         `json:"awayTeam"`
+	Home     GameTeamTotals `json:"home"`
+	Away     GameTeamTotals `json:"away"`
+	HomeWon  bool           `json:"homeWon"`
+	Decision string         `json:"decision"` // REG, OT or SO
+}
+
+// aggregateGameStats summarizes every game in shotData, keyed by gameKey
+func aggregateGameStats(shotData []ShotData) map[string]*GameSummary {
+	games := make(map[string]*GameSummary)
+	overtime := make(map[string]bool)
+
+	for _, shot := range shotData {
+		key := gameKey(shot)
+		game, ok := games[key]
+		if !ok {
+			game = &GameSummary{
+				GameID:   shot.GameID,
+				Season:   shot.Season,
+				Playoffs: shot.IsPlayoffGame,
+				HomeTeam: shot.HomeTeamCode,
+				AwayTeam: shot.AwayTeamCode,
+			}
+			if !shot.Date.IsZero() {
+				game.Date = shot.Date.Format("2006-01-02")
+			}
+			games[key] = game
+		}
+		if shot.Period > 3 {
+			overtime[key] = true
+		}
+
+		side := &game.Away
+		if shot.TeamCode == shot.HomeTeamCode {
+			side = &game.Home
+		}
+		side.Attempts++
+		if !isBlockedShot(shot) {
+			side.UnblockedAttempts++
+			side.XGoals += shot.XGoal
+		}
+		if isShotOnGoal(shot) {
+			side.ShotsOnGoal++
+		}
+		if shot.Goal {
+			side.Goals++
+		}
+	}
+
+	results := gameResults(shotData)
+	for key, game := range games {
+		game.Home.XGoals = round2(game.Home.XGoals)
+		game.Away.XGoals = round2(game.Away.XGoals)
+		game.HomeWon = results[key].homeWon
+
+		switch {
+		case game.Home.Goals == game.Away.Goals:
+			// Shootout goals aren't in the shot data
+			game.Decision = decisionShootout
+		case overtime[key]:
+			game.Decision = decisionOvertime
+		default:
+			game.Decision = decisionRegulation
+		}
+	}
+	return games
+}
+
+// TeamGame is one game in a team's log
+type TeamGame struct {
+	GameID   string  `json:"gameId"`
+	Season   string  `json:"season"`
+	Date     string  `json:"date,omitempty"`
+	Opponent string  `json:"opponent"`
+	Venue    string  `json:"venue"`  // home or away
+	Result   string  `json:"result"` // W or L
+	Decision string  `json:"decision"`
+	GF       int     `json:"gf"`
+	GA       int     `json:"ga"`
+	XGF      float64 `json:"xgf"`
+	XGA      float64 `json:"xga"`
+	XGFPct   float64 `json:"xgfPct"`
+	CF       int     `json:"cf"`
+	CA       int     `json:"ca"`
+	CFPct    float64 `json:"cfPct"`
+	SF       int     `json:"sf"`
+	SA       int     `json:"sa"`
+
+	// Rolling averages end at this game, keyed by window size. Windows
+	// longer than the games played so far average what there is.
+	Rolling map[string]FormAverages `json:"rolling"`
+	EWMA    FormAverages            `json:"ewma"`
+}
+
+// FormAverages are per-game averages over a stretch of games. The shares
+// are from summed totals, not averaged percentages.
+type FormAverages struct {
+	Games  int     `json:"games"`
+	GF     float64 `json:"gf"`
+	GA     float64 `json:"ga"`
+	XGF    float64 `json:"xgf"`
+	XGA    float64 `json:"xga"`
+	XGFPct float64 `json:"xgfPct"`
+	CFPct  float64 `json:"cfPct"`
+}
+
+// formTotals sums the games behind a FormAverages
+type formTotals struct {
+	games    float64
+	gf, ga   float64
+	xgf, xga float64
+	cf, ca   float64
+}
+
+func (t *formTotals) add(game TeamGame, weight float64) {
+	t.games += weight
+	t.gf += weight * float64(game.GF)
+	t.ga += weight * float64(game.GA)
+	t.xgf += weight * game.XGF
+	t.xga += weight * game.XGA
+	t.cf += weight * float64(game.CF)
+	t.ca += weight * float64(game.CA)
+}
+
+func (t formTotals) averages(games int) FormAverages {
+	return FormAverages{
+		Games:  games,
+		GF:     round2(safeDiv(t.gf, t.games)),
+		GA:     round2(safeDiv(t.ga, t.games)),
+		XGF:    round2(safeDiv(t.xgf, t.games)),
+		XGA:    round2(safeDiv(t.xga, t.games)),
+		XGFPct: round1(safeDiv(t.xgf, t.xgf+t.xga) * 100),
+		CFPct:  round1(safeDiv(t.cf, t.cf+t.ca) * 100),
+	}
+}
+
+// TeamGamesHandler returns a team's game log with rolling and
+// exponentially weighted form.
+//
+// Query params: the shared shot filters (season, from, to, gameType),
+// windows (comma separated rolling window sizes, default 5,10,20) and span
+// (EWMA span in games, default 10).
+func TeamGamesHandler(c echo.Context) error {
+	team := moneyPuckTeamCode(c.Param("code"))
+
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, _, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	windows := defaultFormWindows
+	if value := c.QueryParam("windows"); value != "" {
+		windows = nil
+		for _, part := range strings.Split(value, ",") {
+			window, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || window < 1 {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid window %q", part)})
+			}
+			windows = append(windows, window)
+		}
+	}
+	span := defaultEWMASpan
+	if value := c.QueryParam("span"); value != "" {
+		span, err = strconv.Atoi(value)
+		if err != nil || span < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "span must be a positive number of games"})
+		}
+	}
+
+	order := newGameOrder()
+	for _, shot := range shots {
+		if shot.HomeTeamCode == team || shot.AwayTeamCode == team {
+			order.add(shot)
+		}
+	}
+	summaries := aggregateGameStats(shots)
+
+	keys := make([]string, 0)
+	for key, game := range summaries {
+		if game.HomeTeam == team || game.AwayTeam == team {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No games found for team " + team})
+	}
+	order.sort(keys)
+
+	games := make([]TeamGame, len(keys))
+	for i, key := range keys {
+		games[i] = teamGame(summaries[key], team)
+	}
+	addForm(games, windows, span)
+
+	var season formTotals
+	for _, game := range games {
+		season.add(game, 1)
+	}
+	latest := games[len(games)-1]
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"team":  team,
+		"games": games,
+		"form": map[string]interface{}{
+			"season":  season.averages(len(games)),
+			"rolling": latest.Rolling,
+			"ewma":    latest.EWMA,
+			// Positive when the team is playing above its season level
+			"xgfPctTrend": round1(latest.EWMA.XGFPct - season.averages(len(games)).XGFPct),
+		},
+		"metadata": map[string]interface{}{
+			"windows": windows,
+			"span":    span,
+			"ewma":    fmt.Sprintf("Each game weighted by (1 - alpha)^age with alpha = 2 / (span + 1) = %.3f", 2/float64(span+1)),
+		},
+	})
+}
+
+// teamGame turns a game summary into one line of team's log
+func teamGame(game *GameSummary, team string) TeamGame {
+	us, them := game.Home, game.Away
+	opponent, venue, won := game.AwayTeam, "home", game.HomeWon
+	if game.AwayTeam == team {
+		us, them = game.Away, game.Home
+		opponent, venue, won = game.HomeTeam, "away", !game.HomeWon
+	}
+
+	result := "L"
+	if won {
+		result = "W"
+	}
+	return TeamGame{
+		GameID:   game.GameID,
+		Season:   game.Season,
+		Date:     game.Date,
+		Opponent: opponent,
+		Venue:    venue,
+		Result:   result,
+		Decision: game.Decision,
+		GF:       us.Goals,
+		GA:       them.Goals,
+		XGF:      us.XGoals,
+		XGA:      them.XGoals,
+		XGFPct:   round1(safeDiv(us.XGoals, us.XGoals+them.XGoals) * 100),
+		CF:       us.Attempts,
+		CA:       them.Attempts,
+		CFPct:    round1(safeDiv(float64(us.Attempts), float64(us.Attempts+them.Attempts)) * 100),
+		SF:       us.ShotsOnGoal,
+		SA:       them.ShotsOnGoal,
+	}
+}
+
+// addForm fills in each game's rolling averages and EWMA
+func addForm(games []TeamGame, windows []int, span int) {
+	alpha := 2 / float64(span+1)
+	var ewma formTotals
+
+	for i := range games {
+		games[i].Rolling = make(map[string]FormAverages, len(windows))
+		for _, window := range windows {
+			var totals formTotals
+			start := max(0, i-window+1)
+			for _, game := range games[start : i+1] {
+				totals.add(game, 1)
+			}
+			games[i].Rolling[strconv.Itoa(window)] = totals.averages(i + 1 - start)
+		}
+
+		// Decay what came before and add this game at full weight
+		ewma = formTotals{
+			games: ewma.games * (1 - alpha),
+			gf:    ewma.gf * (1 - alpha),
+			ga:    ewma.ga * (1 - alpha),
+			xgf:   ewma.xgf * (1 - alpha),
+			xga:   ewma.xga * (1 - alpha),
+			cf:    ewma.cf * (1 - alpha),
+			ca:    ewma.ca * (1 - alpha),
+		}
+		ewma.add(games[i], 1)
+		games[i].EWMA = ewma.averages(i + 1)
+	}
+}
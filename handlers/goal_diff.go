@@ -1,10 +1,7 @@
 package handlers
 
 import (
-	"encoding/csv"
 	"net/http"
-	"os"
-	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
@@ -28,71 +25,23 @@ type gameState struct {
 }
 
 func ProcessGoalDifferentialHandler(c echo.Context) error {
-	filePath := c.QueryParam("filePath")
-	if filePath == "" {
-		filePath = "data/march3.csv"
-	}
-
-	file, err := os.Open(filePath)
+	allShots, err := datasetShots(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to open CSV file"})
+		return shotLoadError(c, err)
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	shots, filter, err := filterShotsFromQuery(c, allShots)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to parse CSV"})
-	}
-
-	if len(records) < 2 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV file is empty or invalid"})
-	}
-
-	columns := records[0]
-	seasonIdx := findColumnIndex(columns, "season")
-	teamIdx := findColumnIndex(columns, "teamCode")
-	eventIdx := findColumnIndex(columns, "event")
-	gameIdx := findColumnIndex(columns, "game_id")
-	homeTeamIdx := findColumnIndex(columns, "homeTeamCode")
-	awayTeamIdx := findColumnIndex(columns, "awayTeamCode")
-	homeGoalsIdx := findColumnIndex(columns, "homeTeamGoals")
-	awayGoalsIdx := findColumnIndex(columns, "awayTeamGoals")
-	homeWinIdx := findColumnIndex(columns, "homeTeamWon")
-	periodIdx := findColumnIndex(columns, "period")
-
-	if seasonIdx == -1 || teamIdx == -1 || eventIdx == -1 || gameIdx == -1 || homeTeamIdx == -1 || awayTeamIdx == -1 ||
-		homeGoalsIdx == -1 || awayGoalsIdx == -1 || homeWinIdx == -1 || periodIdx == -1 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV does not contain required columns"})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
 	teamStats := make(map[string]*GoalDifferentialStats)
 	gameStates := make(map[string][]gameState) // gameID -> []gameState
 
-	for _, record := range records[1:] {
-		if record[seasonIdx] != "2024" {
-			continue
-		}
-		gameID := record[gameIdx]
-		homeTeam := record[homeTeamIdx]
-		awayTeam := record[awayTeamIdx]
-		homeGoals, err := strconv.Atoi(record[homeGoalsIdx])
-		if err != nil {
-			continue
-		}
-		awayGoals, err := strconv.Atoi(record[awayGoalsIdx])
-		if err != nil {
-			continue
-		}
-
-		homeWin, err := strconv.ParseBool(record[homeWinIdx])
-		if err != nil {
-			continue
-		}
-		period, err := strconv.Atoi(record[periodIdx])
-		if err != nil {
-			continue
-		}
+	for _, shot := range shots {
+		gameID := shot.GameID
+		homeTeam := shot.HomeTeamCode
+		awayTeam := shot.AwayTeamCode
 
 		if _, exists := gameStates[gameID]; !exists {
 			gameStates[gameID] = []gameState{}
@@ -101,10 +50,10 @@ func ProcessGoalDifferentialHandler(c echo.Context) error {
 		gameStates[gameID] = append(gameStates[gameID], gameState{
 			homeTeam:  homeTeam,
 			awayTeam:  awayTeam,
-			homeGoals: homeGoals,
-			awayGoals: awayGoals,
-			homeWin:   homeWin,
-			period:    period,
+			homeGoals: shot.HomeTeamGoals,
+			awayGoals: shot.AwayTeamGoals,
+			homeWin:   shot.HomeTeamWon,
+			period:    shot.Period,
 		})
 
 		if _, exists := teamStats[homeTeam]; !exists {
@@ -187,6 +136,9 @@ func ProcessGoalDifferentialHandler(c echo.Context) error {
 
 	teamStatsWithNA := make(map[string]*GoalDifferentialStatsWithNA)
 	for team, stats := range teamStats {
+		if !filter.includesTeam(team) {
+			continue
+		}
 		winProbs := make(map[int]interface{})
 		for diff := -4; diff <= 4; diff++ {
 			if stats.WinProbabilityByDifferential[diff] == -1000 {
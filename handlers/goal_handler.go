@@ -1,9 +1,7 @@
 package handlers
 
 import (
-	"encoding/csv"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/labstack/echo/v4"
@@ -17,47 +15,23 @@ type GoalsStats struct {
 }
 
 func ProcessGoalsHandler(c echo.Context) error {
-
-	filePath := c.QueryParam("filePath")
-	if filePath == "" {
-		filePath = "data/march3.csv"
-	}
-
-	file, err := os.Open(filePath)
+	allShots, err := datasetShots(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to open CSV file"})
+		return shotLoadError(c, err)
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	shots, filter, err := filterShotsFromQuery(c, allShots)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to parse CSV"})
-	}
-
-	if len(records) < 2 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV file is empty or invalid"})
-	}
-
-	// Column indices
-	columns := records[0]
-	positionIdx := findColumnIndex(columns, "playerPositionThatDidEvent")
-	teamIdx := findColumnIndex(columns, "teamCode")
-	eventIdx := findColumnIndex(columns, "event")
-	gameIdx := findColumnIndex(columns, "game_id")
-
-	if positionIdx == -1 || teamIdx == -1 || eventIdx == -1 || gameIdx == -1 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV does not contain required columns"})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
 	teamStats := make(map[string]*GoalsStats)
 	gameCount := make(map[string]map[string]bool)
 
-	for _, record := range records[1:] {
-		position := record[positionIdx]
-		team := record[teamIdx]
-		event := record[eventIdx]
-		gameID := record[gameIdx]
+	for _, shot := range shots {
+		position := shot.PlayerPosition
+		team := shot.TeamCode
+		gameID := gameKey(shot)
 
 		if _, ok := teamStats[team]; !ok {
 			teamStats[team] = &GoalsStats{
@@ -75,13 +49,17 @@ func ProcessGoalsHandler(c echo.Context) error {
 			stats.TotalGames++
 		}
 
-		if strings.ToLower(event) == "goal" {
+		if strings.ToLower(shot.Event) == "goal" {
 			stats.TotalGoals[position]++
 		}
 	}
 
 	// Calculate goals per game for each position
-	for _, stats := range teamStats {
+	for team, stats := range teamStats {
+		if !filter.includesTeam(team) {
+			delete(teamStats, team)
+			continue
+		}
 		for pos, totalGoals := range stats.TotalGoals {
 			stats.GoalsPerGame[pos] = float64(totalGoals) / float64(stats.TotalGames)
 		}
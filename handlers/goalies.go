@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultRollingGames is the window for the rolling GSAx series
+const defaultRollingGames = 5
+
+// GoalieStats summarizes the shots a goalie faced. Empty-net shots are left
+// out since no goalie faced them.
+type GoalieStats struct {
+	GoalieID    string `json:"goalieId"`
+	GoalieName  string `json:"goalieName"`
+	Team        string `json:"team"`
+	GamesPlayed int    `json:"gamesPlayed"`
+
+	// Unblocked attempts includes misses; xGA and GSAx are measured on them
+	UnblockedAttempts int     `json:"unblockedAttempts"`
+	ShotsFaced        int     `json:"shotsFaced"` // Shots on goal
+	Saves             int     `json:"saves"`
+	GoalsAgainst      int     `json:"goalsAgainst"`
+	SavePct           float64 `json:"savePct"`
+	XGoalsAgainst     float64 `json:"xGoalsAgainst"`
+	ExpectedSavePct   float64 `json:"expectedSavePct"`
+	// GSAx is goals saved above expected: xGA minus goals allowed
+	GSAx        float64 `json:"gsax"`
+	GSAxPerGame float64 `json:"gsaxPerGame"`
+
+	HighDangerShotsFaced   int     `json:"highDangerShotsFaced"`
+	HighDangerGoalsAgainst int     `json:"highDangerGoalsAgainst"`
+	HighDangerSavePct      float64 `json:"highDangerSavePct"`
+
+	// ReboundsAllowed counts saves that gave up a rebound; a lower rate
+	// means better rebound control
+	ReboundsAllowed int     `json:"reboundsAllowed"`
+	ReboundRate     float64 `json:"reboundRate"`
+
+	Rank   int               `json:"rank"`
+	Series []GoalieGameStats `json:"series"`
+}
+
+// GoalieGameStats is one game in a goalie's rolling GSAx series
+type GoalieGameStats struct {
+	GameID        string  `json:"gameId"`
+	Season        string  `json:"season"`
+	Date          string  `json:"date,omitempty"`
+	Opponent      string  `json:"opponent"`
+	ShotsFaced    int     `json:"shotsFaced"`
+	GoalsAgainst  int     `json:"goalsAgainst"`
+	XGoalsAgainst float64 `json:"xGoalsAgainst"`
+	GSAx          float64 `json:"gsax"`
+	// RollingGSAx sums GSAx over this game and the ones before it in the
+	// window
+	RollingGSAx float64 `json:"rollingGsax"`
+}
+
+// goalieTotals accumulates the counts behind GoalieStats
+type goalieTotals struct {
+	stats GoalieStats
+	xga   float64
+	// xgaOnGoal only counts shots on goal, for the expected save %
+	xgaOnGoal float64
+	games     map[string]*GoalieGameStats
+}
+
+// ProcessGoaliesHandler reports goals saved above expected and related
+// goaltending metrics per goalie.
+//
+// Query params: the shared shot filters (season, from, to, gameType,
+// team), rolling (games in the GSAx window, default 5) and minShots (hide
+// goalies who faced fewer shots on goal).
+func ProcessGoaliesHandler(c echo.Context) error {
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	rolling := defaultRollingGames
+	if value := c.QueryParam("rolling"); value != "" {
+		rolling, err = strconv.Atoi(value)
+		if err != nil || rolling < 1 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "rolling must be a positive number of games"})
+		}
+	}
+	minShots := 0
+	if value := c.QueryParam("minShots"); value != "" {
+		minShots, err = strconv.Atoi(value)
+		if err != nil || minShots < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "minShots must be a non-negative number"})
+		}
+	}
+
+	goalies := aggregateGoalieStats(shots, rolling)
+
+	// Rank against every goalie, then trim to the requested team
+	results := make([]GoalieStats, 0, len(goalies))
+	for _, goalie := range goalies {
+		if goalie.ShotsFaced >= minShots {
+			results = append(results, goalie)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].GSAx != results[j].GSAx {
+			return results[i].GSAx > results[j].GSAx
+		}
+		return results[i].GoalieID < results[j].GoalieID
+	})
+
+	filtered := results[:0]
+	for i, goalie := range results {
+		goalie.Rank = i + 1
+		if filter.includesTeam(goalie.Team) {
+			filtered = append(filtered, goalie)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"goalies":      filtered,
+		"rollingGames": rolling,
+	})
+}
+
+// aggregateGoalieStats builds the stats for every goalie in shots. Each
+// goalie's team is the one they faced their most recent shot for.
+func aggregateGoalieStats(shots []ShotData, rolling int) []GoalieStats {
+	totals := make(map[string]*goalieTotals)
+	order := newGameOrder()
+
+	for _, shot := range shots {
+		if shot.GoalieIDForShot == "" || shot.ShotOnEmptyNet || isBlockedShot(shot) {
+			continue
+		}
+		order.add(shot)
+
+		goalie, ok := totals[shot.GoalieIDForShot]
+		if !ok {
+			goalie = &goalieTotals{
+				stats: GoalieStats{
+					GoalieID:   shot.GoalieIDForShot,
+					GoalieName: shot.GoalieNameForShot,
+				},
+				games: make(map[string]*GoalieGameStats),
+			}
+			totals[shot.GoalieIDForShot] = goalie
+		}
+		goalie.stats.Team = defendingTeam(shot)
+
+		key := gameKey(shot)
+		game, ok := goalie.games[key]
+		if !ok {
+			game = &GoalieGameStats{
+				GameID:   shot.GameID,
+				Season:   shot.Season,
+				Opponent: shot.TeamCode,
+			}
+			if !shot.Date.IsZero() {
+				game.Date = shot.Date.Format("2006-01-02")
+			}
+			goalie.games[key] = game
+		}
+
+		stats := &goalie.stats
+		stats.UnblockedAttempts++
+		goalie.xga += shot.XGoal
+		game.XGoalsAgainst += shot.XGoal
+
+		if !isShotOnGoal(shot) {
+			continue
+		}
+		stats.ShotsFaced++
+		game.ShotsFaced++
+		goalie.xgaOnGoal += shot.XGoal
+
+		highDanger := shotDangerTier(shot) == dangerHigh
+		if highDanger {
+			stats.HighDangerShotsFaced++
+		}
+
+		if shot.Goal {
+			stats.GoalsAgainst++
+			game.GoalsAgainst++
+			if highDanger {
+				stats.HighDangerGoalsAgainst++
+			}
+			continue
+		}
+
+		stats.Saves++
+		if shot.ShotGeneratedRebound {
+			stats.ReboundsAllowed++
+		}
+	}
+
+	results := make([]GoalieStats, 0, len(totals))
+	for _, goalie := range totals {
+		stats := goalie.stats
+		stats.GamesPlayed = len(goalie.games)
+		stats.SavePct = round3(safeDiv(float64(stats.Saves), float64(stats.ShotsFaced)))
+		stats.XGoalsAgainst = round2(goalie.xga)
+		stats.GSAx = round2(goalie.xga - float64(stats.GoalsAgainst))
+		stats.GSAxPerGame = round2(safeDiv(goalie.xga-float64(stats.GoalsAgainst), float64(stats.GamesPlayed)))
+		stats.ExpectedSavePct = round3(1 - safeDiv(goalie.xgaOnGoal, float64(stats.ShotsFaced)))
+		stats.HighDangerSavePct = round3(safeDiv(
+			float64(stats.HighDangerShotsFaced-stats.HighDangerGoalsAgainst), float64(stats.HighDangerShotsFaced)))
+		stats.ReboundRate = round3(safeDiv(float64(stats.ReboundsAllowed), float64(stats.Saves)))
+		stats.Series = goalieSeries(goalie.games, order, rolling)
+		results = append(results, stats)
+	}
+	return results
+}
+
+// goalieSeries orders a goalie's games and adds the rolling GSAx
+func goalieSeries(games map[string]*GoalieGameStats, order *gameOrder, rolling int) []GoalieGameStats {
+	keys := make([]string, 0, len(games))
+	for key := range games {
+		keys = append(keys, key)
+	}
+	order.sort(keys)
+
+	series := make([]GoalieGameStats, 0, len(keys))
+	gsax := make([]float64, len(keys))
+	window := 0.0
+	for i, key := range keys {
+		game := *games[key]
+		gsax[i] = game.XGoalsAgainst - float64(game.GoalsAgainst)
+		window += gsax[i]
+		if i >= rolling {
+			window -= gsax[i-rolling]
+		}
+
+		game.XGoalsAgainst = round2(game.XGoalsAgainst)
+		game.GSAx = round2(gsax[i])
+		game.RollingGSAx = round2(window)
+		series = append(series, game)
+	}
+	return series
+}
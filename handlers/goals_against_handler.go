@@ -1,9 +1,7 @@
 package handlers
 
 import (
-	"encoding/csv"
 	"net/http"
-	"os"
 	"sort"
 	"strings"
 
@@ -24,57 +22,23 @@ type TeamRanking struct {
 }
 
 func ProcessGoalsAgainstHandler(c echo.Context) error {
-	file, err := os.Open("data/march3.csv")
+	allShots, err := datasetShots(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to open CSV file"})
+		return shotLoadError(c, err)
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	shots, filter, err := filterShotsFromQuery(c, allShots)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to parse CSV"})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	if len(records) < 2 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV file is empty or invalid"})
-	}
-
-	// Column indices
-	columns := records[0]
-	positionIdx := findColumnIndex(columns, "playerPositionThatDidEvent")
-	teamIdx := findColumnIndex(columns, "teamCode")
-	eventIdx := findColumnIndex(columns, "event")
-	gameIdx := findColumnIndex(columns, "game_id")
-	isHomeTeamIdx := findColumnIndex(columns, "isHomeTeam")
-	homeTeamCodeIdx := findColumnIndex(columns, "homeTeamCode")
-	awayTeamCodeIdx := findColumnIndex(columns, "awayTeamCode")
-	seasonIdx := findColumnIndex(columns, "season")
-
-	if positionIdx == -1 || teamIdx == -1 || eventIdx == -1 || gameIdx == -1 ||
-		isHomeTeamIdx == -1 || homeTeamCodeIdx == -1 || awayTeamCodeIdx == -1 || seasonIdx == -1 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV does not contain required columns"})
-	}
-
-	// Define the current season
-	currentSeason := "2024"
-
 	teamStats := make(map[string]*GoalsAgainst)
 	gameCount := make(map[string]map[string]bool)
 
-	for _, record := range records[1:] {
-		season := record[seasonIdx]
-		if season != currentSeason {
-			continue // Skip records that are not from the current season
-		}
-
-		position := record[positionIdx]
-		team := record[teamIdx]
-		event := record[eventIdx]
-		gameID := record[gameIdx]
-		isHomeTeam := record[isHomeTeamIdx] == "true"
-		homeTeam := record[homeTeamCodeIdx]
-		awayTeam := record[awayTeamCodeIdx]
+	for _, shot := range shots {
+		position := shot.PlayerPosition
+		team := shot.TeamCode
+		gameID := gameKey(shot)
 
 		if _, ok := teamStats[team]; !ok {
 			teamStats[team] = &GoalsAgainst{
@@ -92,12 +56,9 @@ func ProcessGoalsAgainstHandler(c echo.Context) error {
 			stats.TotalGames++
 		}
 
-		if strings.ToLower(event) == "goal" {
-			// Identify the defending team
-			defendingTeam := awayTeam
-			if isHomeTeam {
-				defendingTeam = homeTeam
-			}
+		if strings.ToLower(shot.Event) == "goal" {
+			// The defending team is whichever side didn't take the shot
+			defendingTeam := defendingTeam(shot)
 
 			if _, ok := teamStats[defendingTeam]; !ok {
 				teamStats[defendingTeam] = &GoalsAgainst{
@@ -145,6 +106,17 @@ func ProcessGoalsAgainstHandler(c echo.Context) error {
 		teamRankings[i].Rank = i + 1
 	}
 
+	// Keep league-wide ranks when only one team was requested
+	if filter.Team != "" {
+		filtered := []TeamRanking{}
+		for _, ranking := range teamRankings {
+			if filter.includesTeam(ranking.Team) {
+				filtered = append(filtered, ranking)
+			}
+		}
+		teamRankings = filtered
+	}
+
 	// Return the response
 	return c.JSON(http.StatusOK, teamRankings)
 }
@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// The heatmap covers one attacking zone on the adjusted coordinates, from
+// the blue line to the end boards
+const (
+	heatmapMinX = 25.0
+	heatmapMaxX = 100.0
+	heatmapMinY = -42.5
+	heatmapMaxY = 42.5
+
+	defaultHeatmapBin = 5.0
+	minHeatmapBin     = 1.0
+	maxHeatmapBin     = 25.0
+)
+
+// HeatmapGrid describes the bins. Surfaces are indexed [row][column]: row 0
+// is the bin at MinY and column 0 the bin at the blue line (MinX).
+type HeatmapGrid struct {
+	BinSize float64 `json:"binSize"`
+	MinX    float64 `json:"minX"`
+	MaxX    float64 `json:"maxX"`
+	MinY    float64 `json:"minY"`
+	MaxY    float64 `json:"maxY"`
+	Columns int     `json:"columns"`
+	Rows    int     `json:"rows"`
+}
+
+func newHeatmapGrid(binSize float64) HeatmapGrid {
+	return HeatmapGrid{
+		BinSize: binSize,
+		MinX:    heatmapMinX,
+		MaxX:    heatmapMaxX,
+		MinY:    heatmapMinY,
+		MaxY:    heatmapMaxY,
+		Columns: int(math.Ceil((heatmapMaxX - heatmapMinX) / binSize)),
+		Rows:    int(math.Ceil((heatmapMaxY - heatmapMinY) / binSize)),
+	}
+}
+
+// bin returns the row and column for a location, or false outside the zone
+func (g HeatmapGrid) bin(x, y float64) (int, int, bool) {
+	if x < g.MinX || x > g.MaxX || y < g.MinY || y > g.MaxY {
+		return 0, 0, false
+	}
+	col := int((x - g.MinX) / g.BinSize)
+	row := int((y - g.MinY) / g.BinSize)
+	// The far edges belong to the last bin
+	if col == g.Columns {
+		col--
+	}
+	if row == g.Rows {
+		row--
+	}
+	return row, col, true
+}
+
+// HeatmapSubject is whose shots the heatmap shows
+type HeatmapSubject struct {
+	Type    string `json:"type"` // league, team, player or goalie
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Against bool   `json:"against,omitempty"` // Team heatmaps of shots allowed
+}
+
+// HeatmapSurfaces holds the binned counts for one set of shots
+type HeatmapSurfaces struct {
+	Games       int         `json:"games"`
+	Shots       int         `json:"shots"`
+	Goals       int         `json:"goals"`
+	XGoals      float64     `json:"xGoals"`
+	OutsideZone int         `json:"outsideZone"` // Shots from beyond the blue line, not binned
+	ShotGrid    [][]int     `json:"shotGrid"`
+	GoalGrid    [][]int     `json:"goalGrid"`
+	XGoalGrid   [][]float64 `json:"xGoalGrid"`
+}
+
+func newHeatmapSurfaces(grid HeatmapGrid) *HeatmapSurfaces {
+	s := &HeatmapSurfaces{
+		ShotGrid:  make([][]int, grid.Rows),
+		GoalGrid:  make([][]int, grid.Rows),
+		XGoalGrid: make([][]float64, grid.Rows),
+	}
+	for row := 0; row < grid.Rows; row++ {
+		s.ShotGrid[row] = make([]int, grid.Columns)
+		s.GoalGrid[row] = make([]int, grid.Columns)
+		s.XGoalGrid[row] = make([]float64, grid.Columns)
+	}
+	return s
+}
+
+func (s *HeatmapSurfaces) add(grid HeatmapGrid, shot ShotData) {
+	xGoal := 0.0
+	if !isBlockedShot(shot) {
+		xGoal = shot.XGoal
+	}
+	s.Shots++
+	s.XGoals += xGoal
+	if shot.Goal {
+		s.Goals++
+	}
+
+	row, col, ok := grid.bin(shotLocation(shot))
+	if !ok {
+		s.OutsideZone++
+		return
+	}
+	s.ShotGrid[row][col]++
+	s.XGoalGrid[row][col] += xGoal
+	if shot.Goal {
+		s.GoalGrid[row][col]++
+	}
+}
+
+func (s *HeatmapSurfaces) finish() {
+	s.XGoals = round2(s.XGoals)
+	for _, row := range s.XGoalGrid {
+		for col := range row {
+			row[col] = round3(row[col])
+		}
+	}
+}
+
+// HeatmapComparison is the subject's per-game rate in each bin divided by
+// the league's rate for the same kind of game: 1 is league average, 2 is
+// twice as many. Teams, goalies and the league are measured per team-game;
+// players per skater-game, a game a skater took at least one attempt in,
+// which is how a player's own games are counted. Bins the league never
+// shot from are null.
+type HeatmapComparison struct {
+	Baseline          string       `json:"baseline"` // team-game or skater-game
+	LeagueTeamGames   int          `json:"leagueTeamGames"`
+	LeagueSkaterGames int          `json:"leagueSkaterGames,omitempty"`
+	ShotRatio         [][]*float64 `json:"shotRatio"`
+	XGoalRatio        [][]*float64 `json:"xGoalRatio"`
+}
+
+// ProcessHeatmapHandler bins shot locations on a grid over the attacking
+// zone for a team, player or goalie, or the whole league.
+//
+// Query params: the shared shot filters, one of team, player (shooter ID)
+// or goalie (goalie ID), against (with team: shots allowed), binSize (feet,
+// default 5) and compare (add ratio surfaces against the league average).
+func ProcessHeatmapHandler(c echo.Context) error {
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	binSize := defaultHeatmapBin
+	if value := c.QueryParam("binSize"); value != "" {
+		binSize, err = strconv.ParseFloat(value, 64)
+		if err != nil || binSize < minHeatmapBin || binSize > maxHeatmapBin {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("binSize must be between %g and %g feet", minHeatmapBin, maxHeatmapBin),
+			})
+		}
+	}
+
+	subject, err := heatmapSubjectFromQuery(c, filter)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	grid := newHeatmapGrid(binSize)
+	surfaces := newHeatmapSurfaces(grid)
+	league := newHeatmapSurfaces(grid)
+	subjectGames := make(map[string]bool)
+	leagueGames := make(map[string]bool)
+	leagueSkaterGames := make(map[string]bool)
+
+	for _, shot := range shots {
+		if shot.TeamCode == "" {
+			continue
+		}
+		league.add(grid, shot)
+		leagueGames[gameKey(shot)] = true
+		if shot.ShooterPlayerID != "" {
+			leagueSkaterGames[gameKey(shot)+"|"+shot.ShooterPlayerID] = true
+		}
+
+		if subject.plays(shot) {
+			subjectGames[gameKey(shot)] = true
+		}
+		if subject.matches(shot) {
+			surfaces.add(grid, shot)
+			if subject.Name == "" {
+				subject.Name = subject.nameFrom(shot)
+			}
+		}
+	}
+	surfaces.Games = len(subjectGames)
+	league.Games = len(leagueGames)
+
+	response := map[string]interface{}{
+		"subject":  subject,
+		"grid":     grid,
+		"surfaces": surfaces,
+	}
+	if parseBool(c.QueryParam("compare"), false) {
+		// Each game has two teams' worth of shots, so the league rate is
+		// per team-game. A league heatmap is measured the same way. One
+		// shooter is a small slice of a team, so players are compared
+		// with the league per skater-game instead.
+		comparison := &HeatmapComparison{Baseline: "team-game", LeagueTeamGames: 2 * league.Games}
+		games, baselineGames := surfaces.Games, comparison.LeagueTeamGames
+		switch subject.Type {
+		case "league":
+			games = comparison.LeagueTeamGames
+		case "player":
+			comparison.Baseline = "skater-game"
+			comparison.LeagueSkaterGames = len(leagueSkaterGames)
+			baselineGames = comparison.LeagueSkaterGames
+		}
+		compareHeatmaps(comparison, surfaces, league, games, baselineGames)
+		response["comparison"] = comparison
+	}
+	surfaces.finish()
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func heatmapSubjectFromQuery(c echo.Context, filter ShotFilter) (HeatmapSubject, error) {
+	player := strings.TrimSpace(c.QueryParam("player"))
+	goalie := strings.TrimSpace(c.QueryParam("goalie"))
+	against := parseBool(c.QueryParam("against"), false)
+
+	set := 0
+	for _, value := range []string{filter.Team, player, goalie} {
+		if value != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return HeatmapSubject{}, fmt.Errorf("use only one of team, player or goalie")
+	}
+	if against && filter.Team == "" {
+		return HeatmapSubject{}, fmt.Errorf("against needs a team")
+	}
+
+	switch {
+	case filter.Team != "":
+		return HeatmapSubject{Type: "team", ID: filter.Team, Against: against}, nil
+	case player != "":
+		return HeatmapSubject{Type: "player", ID: player}, nil
+	case goalie != "":
+		return HeatmapSubject{Type: "goalie", ID: goalie}, nil
+	default:
+		return HeatmapSubject{Type: "league"}, nil
+	}
+}
+
+// matches reports whether a shot belongs on the subject's heatmap
+func (s HeatmapSubject) matches(shot ShotData) bool {
+	switch s.Type {
+	case "team":
+		if s.Against {
+			return defendingTeam(shot) == s.ID
+		}
+		return shot.TeamCode == s.ID
+	case "player":
+		return shot.ShooterPlayerID == s.ID
+	case "goalie":
+		return shot.GoalieIDForShot == s.ID && !shot.ShotOnEmptyNet
+	default:
+		return true
+	}
+}
+
+// plays reports whether the subject took part in the shot's game, for the
+// per-game rates
+func (s HeatmapSubject) plays(shot ShotData) bool {
+	switch s.Type {
+	case "team":
+		return shot.TeamCode == s.ID || defendingTeam(shot) == s.ID
+	default:
+		return s.matches(shot)
+	}
+}
+
+func (s HeatmapSubject) nameFrom(shot ShotData) string {
+	switch s.Type {
+	case "player":
+		return shot.ShooterName
+	case "goalie":
+		return shot.GoalieNameForShot
+	default:
+		return ""
+	}
+}
+
+// compareHeatmaps fills comparison with the subject's per-game rates
+// divided by the league's rates per baseline game
+func compareHeatmaps(comparison *HeatmapComparison, subject, league *HeatmapSurfaces, subjectGames, leagueGames int) {
+	comparison.ShotRatio = make([][]*float64, len(subject.ShotGrid))
+	comparison.XGoalRatio = make([][]*float64, len(subject.ShotGrid))
+
+	ratio := func(value, leagueValue float64) *float64 {
+		if leagueValue == 0 || leagueGames == 0 || subjectGames == 0 {
+			return nil
+		}
+		r := round2((value / float64(subjectGames)) / (leagueValue / float64(leagueGames)))
+		return &r
+	}
+
+	for row := range subject.ShotGrid {
+		comparison.ShotRatio[row] = make([]*float64, len(subject.ShotGrid[row]))
+		comparison.XGoalRatio[row] = make([]*float64, len(subject.ShotGrid[row]))
+		for col := range subject.ShotGrid[row] {
+			comparison.ShotRatio[row][col] = ratio(float64(subject.ShotGrid[row][col]), float64(league.ShotGrid[row][col]))
+			comparison.XGoalRatio[row][col] = ratio(subject.XGoalGrid[row][col], league.XGoalGrid[row][col])
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/KPWithCode/statpad2/jobs"
+	"github.com/labstack/echo/v4"
+)
+
+// GetJobs lists the background jobs with their schedule and last run
+func GetJobs(c echo.Context) error {
+	return c.JSON(http.StatusOK, jobs.Default().Statuses())
+}
+
+// RunJob starts a job immediately. It returns as soon as the run has
+// started; poll GET /jobs for the outcome.
+func RunJob(c echo.Context) error {
+	status, err := jobs.Default().Trigger(c.Param("name"))
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	case errors.Is(err, jobs.ErrRunning):
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	case err != nil:
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusAccepted, status)
+}
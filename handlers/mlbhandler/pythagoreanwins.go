@@ -1,71 +1,195 @@
 package mlbhandler
 
 import (
-	"encoding/json"
+	"context"
+	"fmt"
+	"math"
 	"net/http"
-	"io/ioutil"
+	"sort"
 
+	"github.com/KPWithCode/statpad2/seasons"
+	"github.com/KPWithCode/statpad2/sportsdata"
 	"github.com/labstack/echo/v4"
 )
 
-type TeamStatsEntry struct {
-	Team struct {
-		Name string `json:"name"`
-	} `json:"team"`
-	Stats struct {
-		RunsScored   float64 `json:"runsScored"`
-		RunsAllowed  float64 `json:"runsAllowed"`
-	} `json:"stats"`
+const (
+	// classicExponent is Bill James' original Pythagorean exponent
+	classicExponent = 2.0
+	// pythagenpatPower sets the Pythagenpat exponent: runs per game ^ 0.287
+	pythagenpatPower = 0.287
+	winPctMultiplier = 100.0
+)
+
+// Formulas accepted by the formula parameter
+const (
+	formulaPythagenpat = "pythagenpat"
+	formulaClassic     = "classic"
+)
+
+type PythagoreanTeam struct {
+	Team               string  `json:"team"`
+	Abbreviation       string  `json:"abbreviation"`
+	GamesPlayed        float64 `json:"gamesPlayed"`
+	RunsScored         float64 `json:"runsScored"`
+	RunsAllowed        float64 `json:"runsAllowed"`
+	RunDifferential    float64 `json:"runDifferential"`
+	Exponent           float64 `json:"exponent"`
+	ExpectedWinPct     float64 `json:"expectedWinPct"`
+	ClassicWinPct      float64 `json:"classicWinPct"`
+	PythagenpatWinPct  float64 `json:"pythagenpatWinPct"`
+	ActualWinPct       float64 `json:"actualWinPct"`
+	WinPctDifferential float64 `json:"winPctDifferential"`
+	ActualWins         float64 `json:"actualWins"`
+	ExpectedWins       float64 `json:"expectedWins"`
+	// LuckDifferential is actual minus expected wins; positive means the
+	// team has won more than its run differential suggests
+	LuckDifferential float64 `json:"luckDifferential"`
 }
 
-type TeamStatsResponse struct {
-	TeamStatsTotals []TeamStatsEntry `json:"teamStatsTotals"`
+func fetchTeamStats(ctx context.Context, season string) ([]sportsdata.TeamStatsTotal, error) {
+	return sportsdata.Default().TeamTotals(ctx, sportsdata.Query{
+		League: "mlb",
+		Season: season,
+	})
 }
 
-func fetchTeamStats() ([]TeamStatsEntry, error) {
-	// Fetching data from external MLB API
-	resp, err := http.Get("https://api.mysportsfeeds.com/v2.1/pull/mlb/current/team_stats_totals.json")
-	if err != nil {
-		return nil, err
+// pythagenpatExponent is the Pythagenpat exponent for a run environment.
+// High-scoring teams need a bigger exponent, since each run is worth
+// fewer wins.
+func pythagenpatExponent(runsScored, runsAllowed, gamesPlayed float64) float64 {
+	if gamesPlayed <= 0 {
+		return classicExponent
 	}
-	defer resp.Body.Close()
+	return math.Pow((runsScored+runsAllowed)/gamesPlayed, pythagenpatPower)
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+// calculatePythagoreanWinPct returns the expected win percentage (0-100)
+// for the given runs and exponent
+func calculatePythagoreanWinPct(runsScored, runsAllowed, exponent float64) float64 {
+	// Guard against division by zero or negative numbers
+	if runsScored <= 0 || runsAllowed <= 0 {
+		return 0.0
 	}
 
-	var teamStatsResponse TeamStatsResponse
-	err = json.Unmarshal(body, &teamStatsResponse)
-	if err != nil {
-		return nil, err
-	}
+	runsForExp := math.Pow(runsScored, exponent)
+	runsAgainstExp := math.Pow(runsAllowed, exponent)
 
-	return teamStatsResponse.TeamStatsTotals, nil
+	return runsForExp / (runsForExp + runsAgainstExp) * winPctMultiplier
 }
 
-type PythagoreanTeam struct {
-	Team           string  `json:"team"`
-	ExpectedWinPct float64 `json:"expectedWinPct"`
+// teamRuns reads runs scored and allowed, falling back to the standings
+// when the batting or pitching totals are missing
+func teamRuns(stats sportsdata.TeamStats) (float64, float64) {
+	runsScored := stats.Batting.Runs
+	if runsScored == 0 {
+		runsScored = stats.Standings.RunsFor
+	}
+	runsAllowed := stats.Pitching.RunsAllowed
+	if runsAllowed == 0 {
+		runsAllowed = stats.Standings.RunsAgainst
+	}
+	return runsScored, runsAllowed
 }
 
 func PythagoreanHandler(c echo.Context) error {
-	teamStats, err := fetchTeamStats()
+	season, err := seasons.Resolve("mlb", c.QueryParam("season"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status": "error",
+			"error":  err.Error(),
+		})
+	}
+
+	formula := c.QueryParam("formula")
+	if formula == "" {
+		formula = formulaPythagenpat
+	}
+	if formula != formulaPythagenpat && formula != formulaClassic {
+		return c.JSON(http.StatusBadRequest, map[string]interface{}{
+			"status": "error",
+			"error":  fmt.Sprintf("Invalid formula %q, expected %s or %s", formula, formulaPythagenpat, formulaClassic),
+		})
+	}
+
+	teamStats, err := fetchTeamStats(c.Request().Context(), season.Key)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch team stats"})
+		return c.JSON(http.StatusInternalServerError, map[string]interface{}{
+			"status": "error",
+			"error":  fmt.Sprintf("Failed to fetch team stats: %v", err),
+		})
 	}
 
-	var results []PythagoreanTeam
-	for _, entry := range teamStats {
-		// Applying the Pythagorean win expectancy formula
-		denominator := (entry.Stats.RunsScored * entry.Stats.RunsScored) + (entry.Stats.RunsAllowed * entry.Stats.RunsAllowed)
-		expectedWinPct := entry.Stats.RunsScored * entry.Stats.RunsScored / denominator
+	results := make([]PythagoreanTeam, 0, len(teamStats))
+
+	for _, team := range teamStats {
+		runsScored, runsAllowed := teamRuns(team.Stats)
+		gamesPlayed := team.Stats.Standings.Wins + team.Stats.Standings.Losses
+
+		exponent := pythagenpatExponent(runsScored, runsAllowed, gamesPlayed)
+		classicWinPct := calculatePythagoreanWinPct(runsScored, runsAllowed, classicExponent)
+		pythagenpatWinPct := calculatePythagoreanWinPct(runsScored, runsAllowed, exponent)
+
+		expectedWinPct := pythagenpatWinPct
+		if formula == formulaClassic {
+			exponent = classicExponent
+			expectedWinPct = classicWinPct
+		}
+
+		actualWinPct := 0.0
+		if gamesPlayed > 0 {
+			actualWinPct = team.Stats.Standings.Wins / gamesPlayed * winPctMultiplier
+		}
+		expectedWins := (expectedWinPct / winPctMultiplier) * gamesPlayed
 
 		results = append(results, PythagoreanTeam{
-			Team:           entry.Team.Name,
-			ExpectedWinPct: expectedWinPct,
+			Team:               fmt.Sprintf("%s %s", team.Team.City, team.Team.Name),
+			Abbreviation:       team.Team.Abbreviation,
+			GamesPlayed:        gamesPlayed,
+			RunsScored:         runsScored,
+			RunsAllowed:        runsAllowed,
+			RunDifferential:    runsScored - runsAllowed,
+			Exponent:           roundToThreeDecimals(exponent),
+			ExpectedWinPct:     roundToTwoDecimals(expectedWinPct),
+			ClassicWinPct:      roundToTwoDecimals(classicWinPct),
+			PythagenpatWinPct:  roundToTwoDecimals(pythagenpatWinPct),
+			ActualWinPct:       roundToTwoDecimals(actualWinPct),
+			WinPctDifferential: roundToTwoDecimals(actualWinPct - expectedWinPct),
+			ActualWins:         team.Stats.Standings.Wins,
+			ExpectedWins:       roundToTwoDecimals(expectedWins),
+			LuckDifferential:   roundToTwoDecimals(team.Stats.Standings.Wins - expectedWins),
 		})
 	}
 
-	return c.JSON(http.StatusOK, results)
+	// Sort by expected win percentage
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ExpectedWinPct > results[j].ExpectedWinPct
+	})
+
+	metadata := map[string]interface{}{
+		"season":  season.Key,
+		"formula": formula,
+		"note":    "Luck differential is actual wins minus expected wins",
+	}
+	if formula == formulaClassic {
+		metadata["pythagoreanExponent"] = classicExponent
+		metadata["formulaDescription"] = "Win% = Runs Scored^2 / (Runs Scored^2 + Runs Allowed^2)"
+	} else {
+		metadata["formulaDescription"] = "Win% = Runs Scored^x / (Runs Scored^x + Runs Allowed^x), x = ((Runs Scored + Runs Allowed) / Games)^0.287"
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"teams":    results,
+			"metadata": metadata,
+		},
+	})
+}
+
+func roundToTwoDecimals(num float64) float64 {
+	return math.Round(num*100) / 100
+}
+
+func roundToThreeDecimals(num float64) float64 {
+	return math.Round(num*1000) / 1000
 }
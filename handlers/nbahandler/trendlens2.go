@@ -1,288 +1,63 @@
 package nbahandler
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"math"
 	"net/http"
 	"os"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/algolia/algoliasearch-client-go/v4/algolia/search"
+	"github.com/KPWithCode/statpad2/searchindex"
+	"github.com/KPWithCode/statpad2/sportsdata"
 	"github.com/labstack/echo/v4"
 )
 
-type TLPlayerStats struct {
-	Player struct {
-		ID               int    `json:"id"`
-		FirstName        string `json:"firstName"`
-		LastName         string `json:"lastName"`
-		PrimaryPosition  string `json:"primaryPosition"`
-		OfficialImageSrc string `json:"officialImageSrc"`
-		CurrentTeam      struct {
-			ID           int    `json:"id"`
-			Abbreviation string `json:"abbreviation"`
-		} `json:"currentTeam"`
-	} `json:"player"`
-	Stats struct {
-		GamesPlayed int `json:"gamesPlayed"`
-		Offense     struct {
-			Pts        int     `json:"pts"`
-			PtsPerGame float64 `json:"ptsPerGame"`
-			Ast        int     `json:"ast"`
-			AstPerGame float64 `json:"astPerGame"`
-		} `json:"offense"`
-		Rebounds struct {
-			Reb        int     `json:"reb"`
-			RebPerGame float64 `json:"rebPerGame"`
-		} `json:"rebounds"`
-		FreeThrows struct {
-			FtAtt  int `json:"ftAtt"`
-			FtMade int `json:"ftMade"`
-		} `json:"freeThrows"`
-		Defense struct {
-			Blk        int     `json:"blk"`
-			BlkPerGame float64 `json:"blkPerGame"`
-			Stl        int     `json:"stl"`
-			StlPerGame float64 `json:"stlPerGame"`
-			Tov        int     `json:"tov"`
-			TovPerGame float64 `json:"tovPerGame"`
-		} `json:"defense"`
-		FieldGoals struct {
-			Fg2PtAtt  int     `json:"fg2PtAtt"`
-			Fg2PtMade int     `json:"fg2PtMade"`
-			Fg3PtAtt  int     `json:"fg3PtAtt"`
-			Fg3PtMade int     `json:"fg3PtMade"`
-			FgAtt     int     `json:"fgAtt"`
-			FgMade    int     `json:"fgMade"`
-			Fg3PtPct  float64 `json:"fg3PtPct"`
-		} `json:"fieldGoals"`
-		Miscellaneous struct {
-			PlusMinus        int     `json:"plusMinus"`
-			PlusMinusPerGame float64 `json:"plusMinusPerGame"`
-			MinSecondsPerGame float64    `json:"minSecondsPerGame"`
-		} `json:"miscellaneous"`
-	} `json:"stats"`
+// TrendLensSyncResult summarizes a TrendLens index refresh
+type TrendLensSyncResult struct {
+	Status       string `json:"status"`
+	Index        string `json:"index"`
+	RecordsSaved int    `json:"recordsSaved"`
+	TaskID       int64  `json:"taskID"`
+	Date         string `json:"date"`
+	// Diff is set on dry runs in place of saving
+	Diff *searchindex.DiffResult `json:"diff,omitempty"`
 }
 
-type TLPlayerStatsResponse struct {
-	PlayerStatsTotals []TLPlayerStats `json:"playerStatsTotals"`
-}
-
-type RateLimitedClient struct {
-	apiKey          string
-	lastRequestTime map[string]time.Time
-	requestCount    int
-	minuteStart     time.Time
-	mu              sync.Mutex
-}
-
-func NewRateLimitedClient(apiKey string) *RateLimitedClient {
-	return &RateLimitedClient{
-		apiKey:          apiKey,
-		lastRequestTime: make(map[string]time.Time),
-		minuteStart:     time.Now(),
-	}
-}
-func (c *RateLimitedClient) DoRequest(endpoint string, backoffSeconds int) ([]byte, error) {
-    c.mu.Lock()
-
-    // Check if we need to reset the minute counter
-    now := time.Now()
-    if now.Sub(c.minuteStart) >= time.Minute {
-        c.requestCount = 0
-        c.minuteStart = now
-    }
-
-    // Add extra buffer to backoff
-    actualBackoff := backoffSeconds + 1
-
-    // Calculate new request cost (1 + backoff seconds)
-    newCost := 1 + actualBackoff
-
-    // More conservative limit
-    if c.requestCount+newCost > 90 { // Lowered from 100 to give some buffer
-        c.mu.Unlock()
-        return nil, fmt.Errorf("rate limit would be exceeded (current count: %d, new cost: %d)",
-            c.requestCount, newCost)
-    }
-
-    // Always wait a minimum time between requests
-    minWaitTime := 2 * time.Second
-    if lastRequest, exists := c.lastRequestTime[endpoint]; exists {
-        timeSinceLastRequest := now.Sub(lastRequest)
-        if timeSinceLastRequest < minWaitTime {
-            waitTime := minWaitTime - timeSinceLastRequest
-            c.mu.Unlock()
-            time.Sleep(waitTime)
-            c.mu.Lock()
-        }
-    }
-
-    // Additional backoff if specified
-    if actualBackoff > 0 {
-        if lastRequest, exists := c.lastRequestTime[endpoint]; exists {
-            timeSinceLastRequest := now.Sub(lastRequest)
-            if timeSinceLastRequest < time.Duration(actualBackoff)*time.Second {
-                waitTime := time.Duration(actualBackoff)*time.Second - timeSinceLastRequest
-                c.mu.Unlock()
-                time.Sleep(waitTime)
-                c.mu.Lock()
-            }
-        }
-    }
-
-    // Update counters and timestamps
-    c.requestCount += newCost
-    c.lastRequestTime[endpoint] = time.Now()
-    c.mu.Unlock()
-
-    // Make the actual request with longer timeout
-    req, err := http.NewRequest("GET", endpoint, nil)
-    if err != nil {
-        return nil, fmt.Errorf("error creating request: %v", err)
-    }
-
-    req.SetBasicAuth(c.apiKey, "MYSPORTSFEEDS")
-
-    client := &http.Client{Timeout: 45 * time.Second} // Increased timeout
-    resp, err := client.Do(req)
-    if err != nil {
-        return nil, fmt.Errorf("error making request: %v", err)
-    }
-    defer resp.Body.Close()
-
-    if resp.StatusCode != http.StatusOK {
-        bodyBytes, _ := io.ReadAll(resp.Body)
-        return nil, fmt.Errorf("API returned non-200 status code: %d, body: %s",
-            resp.StatusCode, string(bodyBytes))
-    }
-
-    return io.ReadAll(resp.Body)
-}
-
-
-// func fetchTodaysScheduleIII() ([]string, error) {
-// 	apiKey := os.Getenv("MYSPORTSFEEDS_API_KEY")
-// 	if apiKey == "" {
-// 		return nil, fmt.Errorf("API key not found in environment variables")
-// 	}
-
-// 	client := NewRateLimitedClient(apiKey)
-
-// 	today := time.Now().AddDate(0, 0, 0).Format("20060102")
-// 	endpoint := fmt.Sprintf("https://api.mysportsfeeds.com/v2.1/pull/nba/2024-2025-regular/games.json?date=%s", today)
-
-// 	// Games endpoint doesn't require backoff
-// 	data, err := client.DoRequest(endpoint, 0)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-
-// 	var response struct {
-// 		Games []struct {
-// 			Schedule struct {
-// 				AwayTeam struct {
-// 					Abbreviation string `json:"abbreviation"`
-// 				} `json:"awayTeam"`
-// 				HomeTeam struct {
-// 					Abbreviation string `json:"abbreviation"`
-// 				} `json:"homeTeam"`
-// 			} `json:"schedule"`
-// 		} `json:"games"`
-// 	}
-
-// 	if err := json.Unmarshal(data, &response); err != nil {
-// 		return nil, fmt.Errorf("error parsing JSON: %v", err)
-// 	}
-
-// 	var teams []string
-// 	for _, game := range response.Games {
-// 		teams = append(teams, game.Schedule.AwayTeam.Abbreviation, game.Schedule.HomeTeam.Abbreviation)
-// 	}
-
-// 	return teams, nil
-// }
-func fetchTodaysScheduleIII() ([]string, error) {
-	apiKey := os.Getenv("MYSPORTSFEEDS_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("API key not found in environment variables")
-	}
-
-	client := NewRateLimitedClient(apiKey)
-
-	today := time.Now().AddDate(0, 0, 0).Format("20060102")
-	endpoint := fmt.Sprintf("https://api.mysportsfeeds.com/v2.1/pull/nba/2024-2025-regular/games.json?date=%s", today)
-
-	// Implement retry logic
-	maxRetries := 3
-	var data []byte
-	var err error
-	
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		data, err = client.DoRequest(endpoint, 0)
-		if err == nil {
-			break // Success, exit retry loop
-		}
-		
-		// If this is not the last attempt, wait before retrying
-		if attempt < maxRetries {
-			retryWait := time.Duration(attempt*2) * time.Second
-			fmt.Printf("Request attempt %d failed: %v. Retrying in %v...\n", 
-				attempt, err, retryWait)
-			time.Sleep(retryWait)
-		}
-	}
-	
-	// If all retries failed, return the last error
+func TrendLensHandler(c echo.Context) error {
+	season, err := seasonParam(c)
 	if err != nil {
-		return nil, fmt.Errorf("failed after %d attempts: %v", maxRetries, err)
-	}
-
-	var response struct {
-		Games []struct {
-			Schedule struct {
-				AwayTeam struct {
-					Abbreviation string `json:"abbreviation"`
-				} `json:"awayTeam"`
-				HomeTeam struct {
-					Abbreviation string `json:"abbreviation"`
-				} `json:"homeTeam"`
-			} `json:"schedule"`
-		} `json:"games"`
-	}
-
-	if err := json.Unmarshal(data, &response); err != nil {
-		return nil, fmt.Errorf("error parsing JSON: %v", err)
-	}
-
-	var teams []string
-	for _, game := range response.Games {
-		teams = append(teams, game.Schedule.AwayTeam.Abbreviation, game.Schedule.HomeTeam.Abbreviation)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	// Handle case where no games are scheduled for today
-	if len(teams) == 0 {
-		fmt.Println("No games scheduled for today")
+	dryRun := c.QueryParam("dryRun") == "true"
+	result, err := SyncTrendLens(c.Request().Context(), season, dryRun)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
-
-	return teams, nil
+	return c.JSON(http.StatusOK, result)
 }
 
-func TrendLensHandler(c echo.Context) error {
-
+// SyncTrendLens pushes player documents for the teams playing today to the
+// NBA TrendLens search index. With dryRun set nothing is written; the
+// result carries a diff against the documents already in the index.
+func SyncTrendLens(ctx context.Context, season string, dryRun bool) (*TrendLensSyncResult, error) {
 	// Get today's games
-	schedule, err := fetchTodaysScheduleIII()
+	schedule, err := fetchTodaysSchedule(ctx)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Error fetching schedule: %v", err),
-		})
+		return nil, fmt.Errorf("Error fetching schedule: %v", err)
+	}
+	// No games today (the offseason, the All-Star break) means no teams to
+	// filter on, which would pull the whole league
+	if len(schedule) == 0 {
+		return &TrendLensSyncResult{
+			Status: "no-games",
+			Index:  searchindex.IndexName(os.Getenv("ALGOLIA_INDEX_NAME"), "nba-trendlens"),
+			Date:   time.Now().Format(time.RFC3339),
+		}, nil
 	}
-	time.Sleep(6 * time.Second)
-	// Create unique team list and join with commas
+	// Create unique team list
 	teamsMap := make(map[string]bool)
 	for _, team := range schedule {
 		teamsMap[strings.ToLower(team)] = true
@@ -292,59 +67,32 @@ func TrendLensHandler(c echo.Context) error {
 	for team := range teamsMap {
 		teams = append(teams, team)
 	}
-	teamsList := strings.Join(teams, ",")
 
 	// Calculate date range
 	lastMonth := time.Now().AddDate(0, -1, 0).Format("20060102")
 	today := time.Now().Format("20060102")
 
 	// Fetch player stats from MySportsFeeds
-	playerStats, err := fetchTLStats(lastMonth, today, teamsList)
+	playerStats, err := fetchTLStats(ctx, season, lastMonth, today, teams)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Error fetching player stats: %v", err),
-		})
+		return nil, fmt.Errorf("Error fetching player stats: %v", err)
 	}
-	time.Sleep(6 * time.Second)
-	currentStats, err := fetchCurrentTLStats(teamsList)
+	currentStats, err := fetchCurrentTLStats(ctx, season, teams)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Error fetching current stats: %v", err),
-		})
+		return nil, fmt.Errorf("Error fetching current stats: %v", err)
 	}
 
-	// Initialize Algolia client
-	algoliaAppID := os.Getenv("ALGOLIA_APP_ID")
-	algoliaAPIKey := os.Getenv("ALGOLIA_API_KEY")
-	algoliaIndexName := os.Getenv("ALGOLIA_INDEX_NAME")
-
-	if algoliaAppID == "" || algoliaAPIKey == "" || algoliaIndexName == "" {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Missing Algolia credentials",
-		})
-	}
-
-	client, err := search.NewClient(algoliaAppID, algoliaAPIKey)
+	indexer, err := searchindex.Open(searchindex.IndexName(os.Getenv("ALGOLIA_INDEX_NAME"), "nba-trendlens"))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Failed to initialize Algolia client: %v", err),
-		})
+		return nil, err
 	}
-	// index, err := client.SearchSingleIndex(
-	// 	client.NewApiSearchSingleIndexRequest(algoliaIndexName),
-	// )
-	// if err != nil {
-	// 	return c.JSON(http.StatusInternalServerError, map[string]string{
-	// 		"error": fmt.Sprintf("Failed to initialize Algolia index: %v", err),
-	// 	})
-	// }
-	currentStatsMap := make(map[int]TLPlayerStats)
-    for _, stat := range currentStats.PlayerStatsTotals {
+	currentStatsMap := make(map[int]sportsdata.PlayerStatsTotal)
+    for _, stat := range currentStats {
         currentStatsMap[stat.Player.ID] = stat
     }
 
-	var batchRequests []search.BatchRequest
-	for _, stats := range playerStats.PlayerStatsTotals {
+	var docs []searchindex.Document
+	for _, stats := range playerStats {
 
 		var simplifiedPER float64
 		if stats.Stats.GamesPlayed > 0 {
@@ -375,163 +123,127 @@ func TrendLensHandler(c echo.Context) error {
 			eFGPct = 0.0
 		}
 
-		var recentStats TLPlayerStats
+		var recentStats sportsdata.PlayerStatsTotal
         var recentMetrics map[string]float64
         if currentStat, exists := currentStatsMap[stats.Player.ID]; exists {
             recentStats = calculateRecentPeriodStats(currentStat, stats)
             recentMetrics = calculateRecentPeriodMetrics(recentStats)
         }
-		batchBody := map[string]interface{}{
-            "objectID":         fmt.Sprintf("player_%d", stats.Player.ID),
-            "playerID":         stats.Player.ID,
-            "firstName":        stats.Player.FirstName,
-            "lastName":         stats.Player.LastName,
-            "fullName":         fmt.Sprintf("%s %s", stats.Player.FirstName, stats.Player.LastName),
-            "position":         stats.Player.PrimaryPosition,
-            "teamID":           stats.Player.CurrentTeam.ID,
-            "teamAbbrev":       stats.Player.CurrentTeam.Abbreviation,
-            "officialImageSrc": stats.Player.OfficialImageSrc,
-            "gamesPlayed":      stats.Stats.GamesPlayed,
-            "points":           stats.Stats.Offense.Pts,
-            "rebounds":         stats.Stats.Rebounds.Reb,
-            "assists":          stats.Stats.Offense.Ast,
-            "pointsPerGame":    stats.Stats.Offense.PtsPerGame,
-            "rebPerGame":       stats.Stats.Rebounds.RebPerGame,
-            "astPerGame":       stats.Stats.Offense.AstPerGame,
-            "blkPerGame":       stats.Stats.Defense.BlkPerGame,
-            "stlPerGame":       stats.Stats.Defense.StlPerGame,
-            "tovPerGame":       stats.Stats.Defense.TovPerGame,
-            "fg3ptPct":         stats.Stats.FieldGoals.Fg3PtPct,
-            "plusMinus":        stats.Stats.Miscellaneous.PlusMinus,
-            "plusMinusPerGame": stats.Stats.Miscellaneous.PlusMinusPerGame,
-			"minPerGame": 		roundToOneDecimal(minPerGame),
-            "lastUpdated":      time.Now().Format(time.RFC3339),
-            "simplifiedPER":    roundToOneDecimal(simplifiedPER),
-            "tsPct":            roundToOneDecimal(tsPct),
-            "eFGPct":           roundToOneDecimal(eFGPct),
-        }
+		doc := NBAPlayerDocument{
+			ObjectID:         fmt.Sprintf("player_%d", stats.Player.ID),
+			SchemaVersion:    NBAPlayerDocumentSchemaVersion,
+			PlayerID:         stats.Player.ID,
+			FirstName:        stats.Player.FirstName,
+			LastName:         stats.Player.LastName,
+			FullName:         fmt.Sprintf("%s %s", stats.Player.FirstName, stats.Player.LastName),
+			Position:         stats.Player.PrimaryPosition,
+			TeamID:           stats.Player.CurrentTeam.ID,
+			TeamAbbrev:       stats.Player.CurrentTeam.Abbreviation,
+			OfficialImageSrc: stats.Player.OfficialImageSrc,
+			GamesPlayed:      stats.Stats.GamesPlayed,
+			Points:           stats.Stats.Offense.Pts,
+			Rebounds:         stats.Stats.Rebounds.Reb,
+			Assists:          stats.Stats.Offense.Ast,
+			PointsPerGame:    stats.Stats.Offense.PtsPerGame,
+			RebPerGame:       stats.Stats.Rebounds.RebPerGame,
+			AstPerGame:       stats.Stats.Offense.AstPerGame,
+			BlkPerGame:       stats.Stats.Defense.BlkPerGame,
+			StlPerGame:       stats.Stats.Defense.StlPerGame,
+			TovPerGame:       stats.Stats.Defense.TovPerGame,
+			Fg3ptPct:         stats.Stats.FieldGoals.Fg3PtPct,
+			PlusMinus:        stats.Stats.Miscellaneous.PlusMinus,
+			PlusMinusPerGame: stats.Stats.Miscellaneous.PlusMinusPerGame,
+			MinPerGame:       roundToOneDecimal(minPerGame),
+			LastUpdated:      time.Now().Format(time.RFC3339),
+			SimplifiedPER:    roundToOneDecimal(simplifiedPER),
+			TsPct:            roundToOneDecimal(tsPct),
+			EFGPct:           roundToOneDecimal(eFGPct),
+		}
 
-        // Add recent period stats if they exist
-        if recentStats.Stats.GamesPlayed > 0 {
-			roundToOneDecimal := func(val float64) float64 {
-				return math.Round(val*10) / 10
-			}
+		// Add recent period stats if they exist
+		if recentStats.Stats.GamesPlayed > 0 {
 			recentMinPerGame := float64(recentStats.Stats.Miscellaneous.MinSecondsPerGame) / 60.0
 
-            recentStatsMap := map[string]interface{}{
-                "recentGamesPlayed":      recentStats.Stats.GamesPlayed,
-                "recentPoints":           recentStats.Stats.Offense.Pts,
-                "recentPointsPerGame":    recentStats.Stats.Offense.PtsPerGame,
-                "recentAssists":          recentStats.Stats.Offense.Ast,
-                "recentAstPerGame":       recentStats.Stats.Offense.AstPerGame,
-                "recentRebounds":         recentStats.Stats.Rebounds.Reb,
-                "recentRebPerGame":       recentStats.Stats.Rebounds.RebPerGame,
-                "recentBlkPerGame":       recentStats.Stats.Defense.BlkPerGame,
-                "recentStlPerGame":       recentStats.Stats.Defense.StlPerGame,
-                "recentTovPerGame":       recentStats.Stats.Defense.TovPerGame,
-                "recentFg3ptPct":         recentStats.Stats.FieldGoals.Fg3PtPct,
-                "recentPlusMinus":        recentStats.Stats.Miscellaneous.PlusMinus,
-                "recentPlusMinusPerGame": roundToOneDecimal(recentStats.Stats.Miscellaneous.PlusMinusPerGame),
-				"recentMinPerGame":       roundToOneDecimal(recentMinPerGame),
-                "recentSimplifiedPER":    roundToOneDecimal(recentMetrics["simplifiedPER"]),
-                "recentTsPct":            roundToOneDecimal(recentMetrics["tsPct"]),
-                "recentEFGPct":           roundToOneDecimal(recentMetrics["eFGPct"]),
-            }
-            
-            // Merge recent stats into batch body
-            for k, v := range recentStatsMap {
-                batchBody[k] = v
-            }
-        }
-		
-		batchRequests = append(batchRequests, *search.NewEmptyBatchRequest().
-			SetAction(search.Action("updateObject")).
-			SetBody(batchBody))
+			doc.NBARecentStats = &NBARecentStats{
+				RecentGamesPlayed:      recentStats.Stats.GamesPlayed,
+				RecentPoints:           recentStats.Stats.Offense.Pts,
+				RecentPointsPerGame:    recentStats.Stats.Offense.PtsPerGame,
+				RecentAssists:          recentStats.Stats.Offense.Ast,
+				RecentAstPerGame:       recentStats.Stats.Offense.AstPerGame,
+				RecentRebounds:         recentStats.Stats.Rebounds.Reb,
+				RecentRebPerGame:       recentStats.Stats.Rebounds.RebPerGame,
+				RecentBlkPerGame:       recentStats.Stats.Defense.BlkPerGame,
+				RecentStlPerGame:       recentStats.Stats.Defense.StlPerGame,
+				RecentTovPerGame:       recentStats.Stats.Defense.TovPerGame,
+				RecentFg3ptPct:         recentStats.Stats.FieldGoals.Fg3PtPct,
+				RecentPlusMinus:        recentStats.Stats.Miscellaneous.PlusMinus,
+				RecentPlusMinusPerGame: roundToOneDecimal(recentStats.Stats.Miscellaneous.PlusMinusPerGame),
+				RecentMinPerGame:       roundToOneDecimal(recentMinPerGame),
+				RecentSimplifiedPER:    roundToOneDecimal(recentMetrics["simplifiedPER"]),
+				RecentTsPct:            roundToOneDecimal(recentMetrics["tsPct"]),
+				RecentEFGPct:           roundToOneDecimal(recentMetrics["eFGPct"]),
+			}
+		}
+
+		indexDoc, err := searchindex.ToDocument(doc)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, indexDoc)
+	}
+
+	if dryRun {
+		diff, err := searchindex.Diff(ctx, indexer, docs, "lastUpdated")
+		if err != nil {
+			return nil, err
+		}
+		return &TrendLensSyncResult{
+			Status: "dry-run",
+			Index:  indexer.Name(),
+			Date:   time.Now().Format(time.RFC3339),
+			Diff:   diff,
+		}, nil
 	}
 
-	// Perform batch update
-	response, err := client.Batch(client.NewApiBatchRequest(
-		algoliaIndexName,
-		search.NewEmptyBatchWriteParams().SetRequests(batchRequests),
-	))
+	saved, err := indexer.Save(ctx, docs)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Failed to save to Algolia: %v", err),
-		})
+		return nil, err
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"status":       "success",
-		"recordsSaved": len(batchRequests),
-		"taskID":       response.TaskID,
-		"date":         time.Now().Format(time.RFC3339),
-	})
+	return &TrendLensSyncResult{
+		Status:       "success",
+		Index:        indexer.Name(),
+		RecordsSaved: saved.Saved,
+		TaskID:       saved.TaskID,
+		Date:         time.Now().Format(time.RFC3339),
+	}, nil
 }
 
-func fetchTLStats(lastMonth, today, teamsList string) (*TLPlayerStatsResponse, error) {
-    apiKey := os.Getenv("MYSPORTSFEEDS_API_KEY")
-    if apiKey == "" {
-        return nil, fmt.Errorf("MySportsFeeds API key not found")
-    }
-
-    client := NewRateLimitedClient(apiKey)  // Create rate-limited client
-
-    endpoint := fmt.Sprintf(
-        "https://api.mysportsfeeds.com/v2.1/pull/nba/2024-2025-regular/player_stats_totals.json?date=%s-%s&team=%s",
-        lastMonth,
-        today,
-        teamsList,
-    )
-
-    // Use DoRequest instead of making HTTP request directly
-    body, err := client.DoRequest(endpoint, 5)
-    if err != nil {
-        return nil, fmt.Errorf("error making request: %v", err)
-    }
-
-    var response TLPlayerStatsResponse
-    if err := json.Unmarshal(body, &response); err != nil {
-        return nil, fmt.Errorf("error parsing JSON: %v", err)
-    }
-
-    return &response, nil
+func fetchTLStats(ctx context.Context, season, lastMonth, today string, teams []string) ([]sportsdata.PlayerStatsTotal, error) {
+	return sportsdata.Default().PlayerTotals(ctx, sportsdata.Query{
+		League: "nba",
+		Season: season,
+		Teams:  teams,
+		Date:   lastMonth + "-" + today,
+	})
 }
 
-func fetchCurrentTLStats(teamsList string) (*TLPlayerStatsResponse, error) {
-    apiKey := os.Getenv("MYSPORTSFEEDS_API_KEY")
-    if apiKey == "" {
-        return nil, fmt.Errorf("MySportsFeeds API key not found")
-    }
-
-    client := NewRateLimitedClient(apiKey)  // Create rate-limited client
-
-    endpoint := fmt.Sprintf(
-        "https://api.mysportsfeeds.com/v2.1/pull/nba/2024-2025-regular/player_stats_totals.json?team=%s",
-        teamsList,
-    )
-
-    // Use DoRequest instead of making HTTP request directly
-    body, err := client.DoRequest(endpoint, 5)
-    if err != nil {
-        return nil, fmt.Errorf("error making request: %v", err)
-    }
-
-    var response TLPlayerStatsResponse
-    if err := json.Unmarshal(body, &response); err != nil {
-        return nil, fmt.Errorf("error parsing JSON: %v", err)
-    }
-
-    return &response, nil
+func fetchCurrentTLStats(ctx context.Context, season string, teams []string) ([]sportsdata.PlayerStatsTotal, error) {
+	return sportsdata.Default().PlayerTotals(ctx, sportsdata.Query{
+		League: "nba",
+		Season: season,
+		Teams:  teams,
+	})
 }
 
-func calculateRecentPeriodStats(currentStats, upToDateStats TLPlayerStats) TLPlayerStats {
+func calculateRecentPeriodStats(currentStats, upToDateStats sportsdata.PlayerStatsTotal) sportsdata.PlayerStatsTotal {
 	// Calculate games in recent period
 	totalGames := currentStats.Stats.GamesPlayed
 	earlierGames := upToDateStats.Stats.GamesPlayed
 	recentGames := totalGames - earlierGames
 
 	if recentGames <= 0 {
-		return TLPlayerStats{} // Return empty stats if no recent games
+		return sportsdata.PlayerStatsTotal{} // Return empty stats if no recent games
 	}
 
 	// Helper function to calculate recent period totals and per game stats
@@ -542,7 +254,7 @@ func calculateRecentPeriodStats(currentStats, upToDateStats TLPlayerStats) TLPla
 	}
 
 	// Create new stats object for recent period
-	recentStats := TLPlayerStats{
+	recentStats := sportsdata.PlayerStatsTotal{
 		Player: currentStats.Player, // Keep player info the same
 	}
 
@@ -612,7 +324,7 @@ func calculateRecentPeriodStats(currentStats, upToDateStats TLPlayerStats) TLPla
 	return recentStats
 }
 
-func calculateRecentPeriodMetrics(stats TLPlayerStats) map[string]float64 {
+func calculateRecentPeriodMetrics(stats sportsdata.PlayerStatsTotal) map[string]float64 {
 	metrics := make(map[string]float64)
 
 	// Calculate simplified PER for recent period
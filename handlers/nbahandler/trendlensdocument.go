@@ -0,0 +1,79 @@
+package nbahandler
+
+import (
+	"net/http"
+
+	"github.com/KPWithCode/statpad2/searchindex"
+	"github.com/labstack/echo/v4"
+)
+
+// NBAPlayerDocumentSchemaVersion is bumped whenever a field is added,
+// removed or changes meaning, so consumers can tell which shape they have
+const NBAPlayerDocumentSchemaVersion = 1
+
+// NBAPlayerDocument is the NBA TrendLens search record for one player
+type NBAPlayerDocument struct {
+	ObjectID      string `json:"objectID"`
+	SchemaVersion int    `json:"schemaVersion"`
+
+	PlayerID         int    `json:"playerID"`
+	FirstName        string `json:"firstName"`
+	LastName         string `json:"lastName"`
+	FullName         string `json:"fullName"`
+	Position         string `json:"position"`
+	TeamID           int    `json:"teamID"`
+	TeamAbbrev       string `json:"teamAbbrev"`
+	OfficialImageSrc string `json:"officialImageSrc"`
+
+	GamesPlayed      int     `json:"gamesPlayed"`
+	Points           int     `json:"points"`
+	Rebounds         int     `json:"rebounds"`
+	Assists          int     `json:"assists"`
+	PointsPerGame    float64 `json:"pointsPerGame"`
+	RebPerGame       float64 `json:"rebPerGame"`
+	AstPerGame       float64 `json:"astPerGame"`
+	BlkPerGame       float64 `json:"blkPerGame"`
+	StlPerGame       float64 `json:"stlPerGame"`
+	TovPerGame       float64 `json:"tovPerGame"`
+	Fg3ptPct         float64 `json:"fg3ptPct"`
+	PlusMinus        int     `json:"plusMinus"`
+	PlusMinusPerGame float64 `json:"plusMinusPerGame"`
+	MinPerGame       float64 `json:"minPerGame"`
+
+	SimplifiedPER float64 `json:"simplifiedPER" doc:"(PTS + REB + AST + STL + BLK - TOV) per game"`
+	TsPct         float64 `json:"tsPct" doc:"True shooting percentage"`
+	EFGPct        float64 `json:"eFGPct" doc:"Effective field goal percentage"`
+
+	LastUpdated string `json:"lastUpdated" doc:"RFC 3339 time the document was built"`
+
+	// Recent stats cover the last month; left out when the player has no
+	// games in that window
+	*NBARecentStats
+}
+
+// NBARecentStats are the last-month fields of an NBAPlayerDocument
+type NBARecentStats struct {
+	RecentGamesPlayed      int     `json:"recentGamesPlayed"`
+	RecentPoints           int     `json:"recentPoints"`
+	RecentPointsPerGame    float64 `json:"recentPointsPerGame"`
+	RecentAssists          int     `json:"recentAssists"`
+	RecentAstPerGame       float64 `json:"recentAstPerGame"`
+	RecentRebounds         int     `json:"recentRebounds"`
+	RecentRebPerGame       float64 `json:"recentRebPerGame"`
+	RecentBlkPerGame       float64 `json:"recentBlkPerGame"`
+	RecentStlPerGame       float64 `json:"recentStlPerGame"`
+	RecentTovPerGame       float64 `json:"recentTovPerGame"`
+	RecentFg3ptPct         float64 `json:"recentFg3ptPct"`
+	RecentPlusMinus        int     `json:"recentPlusMinus"`
+	RecentPlusMinusPerGame float64 `json:"recentPlusMinusPerGame"`
+	RecentMinPerGame       float64 `json:"recentMinPerGame"`
+	RecentSimplifiedPER    float64 `json:"recentSimplifiedPER"`
+	RecentTsPct            float64 `json:"recentTsPct"`
+	RecentEFGPct           float64 `json:"recentEFGPct"`
+}
+
+// TrendLensSchemaHandler serves the JSON Schema for NBAPlayerDocument
+func TrendLensSchemaHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, searchindex.JSONSchema(
+		"NBAPlayerDocument", NBAPlayerDocumentSchemaVersion, NBAPlayerDocument{}))
+}
@@ -1,13 +1,10 @@
 package nbahandler
 
 import (
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 
+	"github.com/KPWithCode/statpad2/sportsdata"
 	"github.com/labstack/echo/v4"
 )
 
@@ -31,24 +28,11 @@ type FilteredStats struct {
 	FG3PtPct float64 `json:"fg3PtPct"`
 }
 
-type internalStatsData struct {
-	FieldGoals FieldGoals `json:"fieldGoals"`
-	FreeThrows FreeThrows `json:"freeThrows"`
-}
-
-type internalStatsTS struct {
-	Team  TeamTS            `json:"team"`
-	Stats internalStatsData `json:"stats"`
-}
-
-type internalResponse struct {
-	TeamStats []internalStatsTS `json:"teamStatsTotals"`
-}
 type Response struct {
 	TeamStats []StatsTS `json:"teamStatsTotals"`
 }
 
-func calculateTSPercentage(fieldGoals FieldGoals, freeThrows FreeThrows) float64 {
+func calculateTSPercentage(fieldGoals sportsdata.FieldGoals, freeThrows sportsdata.FreeThrows) float64 {
 	pointsScored := fieldGoals.FG2PtMade*2 + fieldGoals.FG3PtMade*3 + freeThrows.FTMade
 	totalAttempts := fieldGoals.FG2PtAtt + fieldGoals.FG3PtAtt + 0.44*freeThrows.FTAtt
 	// safety check for division by 0
@@ -60,73 +44,36 @@ func calculateTSPercentage(fieldGoals FieldGoals, freeThrows FreeThrows) float64
 }
 
 func TrueShootingHandler(c echo.Context) error {
-	if err := loadEnvVars(); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Error loading environment variables: %v", err),
-		})
-	}
-
-	apiKey := os.Getenv("MYSPORTSFEEDS_API_KEY")
-	if apiKey == "" {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "API key not found in environment variables",
-		})
-	}
-
-	authString := fmt.Sprintf("%s:%s", apiKey, "MYSPORTSFEEDS")
-	encodedAuth := base64.StdEncoding.EncodeToString([]byte(authString))
-
-	url := "https://api.mysportsfeeds.com/v2.1/pull/nba/2024-2025-regular/team_stats_totals.json"
-	req, err := http.NewRequest("GET", url, nil)
+	season, err := seasonParam(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Error creating request: %v", err),
-		})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	req.Header.Set("Authorization", "Basic "+encodedAuth)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	teamStats, err := fetchTeamStats(c.Request().Context(), season)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Error making request: %v", err),
-		})
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("API request failed with status code: %d", resp.StatusCode),
-		})
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Error reading response body: %v", err),
-		})
-	}
-
-	var internalResp internalResponse
-	if err := json.Unmarshal(body, &internalResp); err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Error unmarshalling response: %v", err),
+			"error": fmt.Sprintf("Failed to fetch team stats: %v", err),
 		})
 	}
 
 	response := Response{
-		TeamStats: make([]StatsTS, len(internalResp.TeamStats)),
+		TeamStats: make([]StatsTS, len(teamStats)),
 	}
 
-	for i, team := range internalResp.TeamStats {
+	for i, team := range teamStats {
 		tsPercentage := calculateTSPercentage(
 			team.Stats.FieldGoals,
 			team.Stats.FreeThrows,
 		)
 
 		response.TeamStats[i] = StatsTS{
-			Team: team.Team,
+			Team: TeamTS{
+				ID:      team.Team.ID,
+				City:    team.Team.City,
+				Name:    team.Team.Name,
+				Abbr:    team.Team.Abbreviation,
+				LogoURL: team.Team.LogoURL,
+			},
 			Stats: FilteredStats{
 				FTPct:    team.Stats.FreeThrows.FTPct,
 				FG2PtPct: team.Stats.FieldGoals.FG2PtPct,
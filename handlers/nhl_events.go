@@ -2,9 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 
 	"github.com/joho/godotenv"
@@ -43,25 +43,19 @@ func GetHockeyEvents(c echo.Context) error {
 	}
 
 	// Define query parameters for the request
-	dateFormat := "iso" // Optional, could be 'iso' or 'unix'
+	params := url.Values{}
+	params.Set("dateFormat", "iso") // Optional, could be 'iso' or 'unix'
 
-	// Construct the request URL for event list
-	url := fmt.Sprintf(
-		"https://api.the-odds-api.com/v4/sports/icehockey_nhl/events?apiKey=%s&dateFormat=%s",
-		apiKey, dateFormat,
-	)
-
-	// Make the API request to fetch events
-	resp, err := http.Get(url)
+	// Fetch the event list, served from the cache when recent enough
+	body, err := fetchOddsAPI(c.Request().Context(), apiKey, "/sports/icehockey_nhl/events", params, oddsEventsTTL, oddsEventsStaleFor)
 	if err != nil {
 		log.Printf("Error making API request: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch events"})
 	}
-	defer resp.Body.Close()
 
 	// Parse the response
 	var events []Event
-	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+	if err := json.Unmarshal(body, &events); err != nil {
 		log.Printf("Error parsing API response: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to parse events"})
 	}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Helpers shared by the NHL analytics handlers that work off ShotData rows
+
+// gameKey identifies a game across seasons. MoneyPuck game IDs restart
+// every season, so the ID alone collides once more than one season is loaded.
+func gameKey(shot ShotData) string {
+	return shot.Season + "-" + shot.GameID
+}
+
+// defendingTeam returns the team the shot was taken against
+func defendingTeam(shot ShotData) string {
+	if shot.TeamCode == shot.HomeTeamCode {
+		return shot.AwayTeamCode
+	}
+	return shot.HomeTeamCode
+}
+
+// isShotOnGoal reports whether the shot reached the goalie: saves and goals.
+// ShotWasOnGoal only marks SHOT events, so goals are added here.
+func isShotOnGoal(shot ShotData) bool {
+	return shot.ShotWasOnGoal || shot.Goal
+}
+
+// isBlockedShot reports whether the row is a blocked shot attempt. The
+// MoneyPuck shot file only has unblocked attempts, but other exports include
+// blocks.
+func isBlockedShot(shot ShotData) bool {
+	return shot.Event == "BLOCK" || shot.Event == "BLOCKED_SHOT"
+}
+
+// gameOrder sorts game keys by date, then season and game ID, so per-game
+// series come out in the order the games were played
+type gameOrder struct {
+	date   map[string]int64
+	season map[string]string
+	id     map[string]int
+}
+
+func newGameOrder() *gameOrder {
+	return &gameOrder{
+		date:   make(map[string]int64),
+		season: make(map[string]string),
+		id:     make(map[string]int),
+	}
+}
+
+// add records the game a shot belongs to
+func (o *gameOrder) add(shot ShotData) {
+	key := gameKey(shot)
+	if _, ok := o.season[key]; ok {
+		return
+	}
+	if !shot.Date.IsZero() {
+		o.date[key] = shot.Date.Unix()
+	}
+	o.season[key] = shot.Season
+	o.id[key], _ = strconv.Atoi(shot.GameID)
+}
+
+// sort orders keys in place
+func (o *gameOrder) sort(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if o.date[a] != o.date[b] {
+			return o.date[a] < o.date[b]
+		}
+		if o.season[a] != o.season[b] {
+			return o.season[a] < o.season[b]
+		}
+		return o.id[a] < o.id[b]
+	})
+}
+
+// safeDiv returns 0 instead of NaN or Inf when den is 0
+func safeDiv(num, den float64) float64 {
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
+
+func round1(val float64) float64 {
+	return math.Round(val*10) / 10
+}
+
+func round2(val float64) float64 {
+	return math.Round(val*100) / 100
+}
+
+func round3(val float64) float64 {
+	return math.Round(val*1000) / 1000
+}
+
+// rankBy sets each item's rank by value, 1 being the highest, or the
+// lowest with ascending set. Ties share a rank. items keeps its order.
+func rankBy[T any](items []T, ascending bool, value func(T) float64, set func(T, int)) {
+	sorted := append([]T(nil), items...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if ascending {
+			return value(sorted[i]) < value(sorted[j])
+		}
+		return value(sorted[i]) > value(sorted[j])
+	})
+	rank := 0
+	for i, item := range sorted {
+		if i == 0 || value(item) != value(sorted[i-1]) {
+			rank = i + 1
+		}
+		set(item, rank)
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import "strings"
+
+// nhlTeamNames maps team codes to the names the Odds API uses. MoneyPuck
+// writes some codes with a dot (L.A, N.J, S.J, T.B), so both forms are
+// listed.
+var nhlTeamNames = map[string]string{
+	"ANA": "Anaheim Ducks",
+	"ARI": "Arizona Coyotes",
+	"BOS": "Boston Bruins",
+	"BUF": "Buffalo Sabres",
+	"CAR": "Carolina Hurricanes",
+	"CBJ": "Columbus Blue Jackets",
+	"CGY": "Calgary Flames",
+	"CHI": "Chicago Blackhawks",
+	"COL": "Colorado Avalanche",
+	"DAL": "Dallas Stars",
+	"DET": "Detroit Red Wings",
+	"EDM": "Edmonton Oilers",
+	"FLA": "Florida Panthers",
+	"L.A": "Los Angeles Kings",
+	"LAK": "Los Angeles Kings",
+	"MIN": "Minnesota Wild",
+	"MTL": "Montréal Canadiens",
+	"N.J": "New Jersey Devils",
+	"NJD": "New Jersey Devils",
+	"NSH": "Nashville Predators",
+	"NYI": "New York Islanders",
+	"NYR": "New York Rangers",
+	"OTT": "Ottawa Senators",
+	"PHI": "Philadelphia Flyers",
+	"PIT": "Pittsburgh Penguins",
+	"S.J": "San Jose Sharks",
+	"SJS": "San Jose Sharks",
+	"SEA": "Seattle Kraken",
+	"STL": "St Louis Blues",
+	"T.B": "Tampa Bay Lightning",
+	"TBL": "Tampa Bay Lightning",
+	"TOR": "Toronto Maple Leafs",
+	"UTA": "Utah Hockey Club",
+	"VAN": "Vancouver Canucks",
+	"VGK": "Vegas Golden Knights",
+	"WPG": "Winnipeg Jets",
+	"WSH": "Washington Capitals",
+}
+
+// nhlTeamName returns the Odds API name for a team code, or the code
+// itself when it isn't known
+func nhlTeamName(code string) string {
+	if name, ok := nhlTeamNames[code]; ok {
+		return name
+	}
+	return code
+}
+
+// moneyPuckTeamCodes are the NHL API codes MoneyPuck writes differently
+var moneyPuckTeamCodes = map[string]string{
+	"LAK": "L.A",
+	"NJD": "N.J",
+	"SJS": "S.J",
+	"TBL": "T.B",
+}
+
+// moneyPuckTeamCode returns a team code in MoneyPuck's form, so codes from
+// NHL feeds (LAK) compare equal to the shot data's (L.A)
+func moneyPuckTeamCode(code string) string {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if mp, ok := moneyPuckTeamCodes[code]; ok {
+		return mp
+	}
+	return code
+}
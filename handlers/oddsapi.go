@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/KPWithCode/statpad2/cache"
+	"github.com/KPWithCode/statpad2/upstream"
+)
+
+const oddsAPIBaseURL = "https://api.the-odds-api.com/v4"
+
+// How long Odds API responses are cached, and how long past that they may
+// still be served while refreshing. Event lists are schedules and change
+// rarely; prices move, so odds are kept for less time.
+const (
+	oddsEventsTTL      = 10 * time.Minute
+	oddsEventsStaleFor = 10 * time.Minute
+	oddsPricesTTL      = 5 * time.Minute
+	oddsPricesStaleFor = time.Minute
+)
+
+// fetchOddsAPI requests path from the Odds API through the response cache
+// and the shared Odds API client.
+// The API key is sent with the request but kept out of the cache key.
+func fetchOddsAPI(ctx context.Context, apiKey, path string, params url.Values, ttl, staleFor time.Duration) ([]byte, error) {
+	key := oddsAPIBaseURL + path + "?" + params.Encode()
+
+	return cache.Default().Fetch(ctx, key, ttl, staleFor, func(ctx context.Context) ([]byte, error) {
+		return requestOddsAPI(ctx, apiKey, path, params)
+	})
+}
+
+// requestOddsAPI requests path from the Odds API, skipping the cache
+func requestOddsAPI(ctx context.Context, apiKey, path string, params url.Values) ([]byte, error) {
+	query := url.Values{}
+	for name, values := range params {
+		query[name] = values
+	}
+	query.Set("apiKey", apiKey)
+
+	resp, err := upstream.OddsAPI.Get(ctx, oddsAPIBaseURL+path+"?"+query.Encode(), 1)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API responded with status code: %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// SnapshotOdds saves the current upcoming odds to ODDS_SNAPSHOT_DIR
+// (default data/odds) as a timestamped JSON file, building a price history
+// the live endpoints don't keep. It returns the number of events saved.
+func SnapshotOdds(ctx context.Context) (int, error) {
+	apiKey := os.Getenv("ODDS_API_KEY")
+	if apiKey == "" {
+		return 0, fmt.Errorf("ODDS_API_KEY is not set in the environment")
+	}
+
+	params := url.Values{}
+	params.Set("regions", "us")
+	params.Set("markets", "h2h,spreads,totals")
+	params.Set("oddsFormat", "american")
+
+	// Straight from the API: a cached response could be older than the
+	// snapshot's timestamp says
+	body, err := requestOddsAPI(ctx, apiKey, "/sports/upcoming/odds", params)
+	if err != nil {
+		return 0, err
+	}
+
+	var events []SportsEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return 0, fmt.Errorf("error parsing odds: %v", err)
+	}
+
+	dir := os.Getenv("ODDS_SNAPSHOT_DIR")
+	if dir == "" {
+		dir = "data/odds"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, fmt.Errorf("error creating %s: %v", dir, err)
+	}
+
+	name := fmt.Sprintf("odds-%s.json", time.Now().UTC().Format("20060102T150405Z"))
+	if err := os.WriteFile(filepath.Join(dir, name), body, 0o644); err != nil {
+		return 0, fmt.Errorf("error writing snapshot: %v", err)
+	}
+	return len(events), nil
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KPWithCode/statpad2/cache"
+	"github.com/KPWithCode/statpad2/upstream"
+)
+
+const nhlAPIBaseURL = "https://api-web.nhle.com/v1"
+
+// playByPlayTTL is how long a game's goals are cached. Final games rarely
+// change after the morning-after scoring review, and it's well past the
+// nhl-playbyplay job's daily period, so the job only has to fetch new
+// games and the cache never goes cold between runs.
+const playByPlayTTL = 30 * 24 * time.Hour
+
+// playByPlayStaleFor is how long past expiry cached goals are served while
+// they're refreshed
+const playByPlayStaleFor = time.Hour
+
+// playByPlayWorkers bounds concurrent play-by-play requests; the shared
+// NHL client's rate limit still applies on top
+const playByPlayWorkers = 6
+
+// GameGoal is one goal from the NHL play-by-play with its scorer and
+// assists keyed by NHL player ID. Assist IDs are empty when unassisted.
+type GameGoal struct {
+	Period       int    `json:"period"`
+	GameSeconds  int    `json:"gameSeconds"`
+	HomeTeamGoal bool   `json:"homeTeamGoal"`
+	ScorerID     string `json:"scorerId"`
+	PrimaryID    string `json:"primaryAssistId,omitempty"`
+	SecondaryID  string `json:"secondaryAssistId,omitempty"`
+}
+
+// GamePlayer is a roster entry from the play-by-play
+type GamePlayer struct {
+	Name     string `json:"name"`
+	Position string `json:"position"`
+	Home     bool   `json:"home"`
+}
+
+// GameGoals is the part of a game's play-by-play the handlers use. It is
+// what gets cached, so the full feed isn't held in memory.
+type GameGoals struct {
+	GameID  string                 `json:"gameId"` // NHL form, e.g. 2024020001
+	Goals   []GameGoal             `json:"goals"`
+	Players map[string]*GamePlayer `json:"players"`
+}
+
+// nhlPlayByPlay is the subset of api-web.nhle.com's play-by-play we read
+type nhlPlayByPlay struct {
+	HomeTeam struct {
+		ID int `json:"id"`
+	} `json:"homeTeam"`
+	Plays []struct {
+		TypeDescKey      string `json:"typeDescKey"`
+		TimeInPeriod     string `json:"timeInPeriod"`
+		PeriodDescriptor struct {
+			Number     int    `json:"number"`
+			PeriodType string `json:"periodType"`
+		} `json:"periodDescriptor"`
+		Details struct {
+			EventOwnerTeamID int `json:"eventOwnerTeamId"`
+			ScoringPlayerID  int `json:"scoringPlayerId"`
+			Assist1PlayerID  int `json:"assist1PlayerId"`
+			Assist2PlayerID  int `json:"assist2PlayerId"`
+		} `json:"details"`
+	} `json:"plays"`
+	RosterSpots []struct {
+		TeamID    int `json:"teamId"`
+		PlayerID  int `json:"playerId"`
+		FirstName struct {
+			Default string `json:"default"`
+		} `json:"firstName"`
+		LastName struct {
+			Default string `json:"default"`
+		} `json:"lastName"`
+		PositionCode string `json:"positionCode"`
+	} `json:"rosterSpots"`
+}
+
+// nhlGameID converts a gameKey to the NHL's 10 digit game ID: the season
+// followed by MoneyPuck's game_id zero padded to 6 digits. It is the
+// inverse of shiftGameKey.
+func nhlGameID(key string) (string, bool) {
+	season, id, ok := strings.Cut(key, "-")
+	if !ok || len(season) != 4 {
+		return "", false
+	}
+	number, err := strconv.Atoi(id)
+	if err != nil || number <= 0 {
+		return "", false
+	}
+	return fmt.Sprintf("%s%06d", season, number), true
+}
+
+// fetchGameGoals returns the goals in an NHL game, from the response cache
+// when possible
+func fetchGameGoals(ctx context.Context, gameID string) (*GameGoals, error) {
+	url := nhlAPIBaseURL + "/gamecenter/" + gameID + "/play-by-play"
+
+	body, err := cache.Default().Fetch(ctx, url, playByPlayTTL, playByPlayStaleFor, func(ctx context.Context) ([]byte, error) {
+		resp, err := upstream.NHL.Get(ctx, url, 1)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("NHL API responded with status code: %d", resp.StatusCode)
+		}
+		goals, err := parsePlayByPlay(gameID, resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(goals)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var goals GameGoals
+	if err := json.Unmarshal(body, &goals); err != nil {
+		return nil, fmt.Errorf("error parsing cached play-by-play for %s: %v", gameID, err)
+	}
+	return &goals, nil
+}
+
+// parsePlayByPlay keeps the goals and roster from a play-by-play feed.
+// Shootout goals aren't real goals and are dropped.
+func parsePlayByPlay(gameID string, body []byte) (*GameGoals, error) {
+	var feed nhlPlayByPlay
+	if err := json.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("error parsing play-by-play for %s: %v", gameID, err)
+	}
+
+	id := func(n int) string {
+		if n == 0 {
+			return ""
+		}
+		return strconv.Itoa(n)
+	}
+
+	goals := &GameGoals{GameID: gameID, Players: make(map[string]*GamePlayer, len(feed.RosterSpots))}
+	for _, spot := range feed.RosterSpots {
+		goals.Players[id(spot.PlayerID)] = &GamePlayer{
+			Name:     strings.TrimSpace(spot.FirstName.Default + " " + spot.LastName.Default),
+			Position: spot.PositionCode,
+			Home:     spot.TeamID == feed.HomeTeam.ID,
+		}
+	}
+
+	for _, play := range feed.Plays {
+		if play.TypeDescKey != "goal" || play.PeriodDescriptor.PeriodType == "SO" {
+			continue
+		}
+		elapsed, err := parseClock(play.TimeInPeriod)
+		if err != nil {
+			continue
+		}
+		period := play.PeriodDescriptor.Number
+		goals.Goals = append(goals.Goals, GameGoal{
+			Period:       period,
+			GameSeconds:  (period-1)*periodSeconds + elapsed,
+			HomeTeamGoal: play.Details.EventOwnerTeamID == feed.HomeTeam.ID,
+			ScorerID:     id(play.Details.ScoringPlayerID),
+			PrimaryID:    id(play.Details.Assist1PlayerID),
+			SecondaryID:  id(play.Details.Assist2PlayerID),
+		})
+	}
+	return goals, nil
+}
+
+// loadGameGoals fetches the play-by-play goals for every game in shots,
+// keyed by gameKey. Games the feed couldn't provide are returned
+// separately rather than failing the whole request.
+func loadGameGoals(ctx context.Context, shots []ShotData) (map[string]*GameGoals, []string, error) {
+	seen := make(map[string]bool)
+	keys := make([]string, 0)
+	for _, shot := range shots {
+		key := gameKey(shot)
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		games   = make(map[string]*GameGoals, len(keys))
+		missing []string
+		work    = make(chan string)
+	)
+	for i := 0; i < playByPlayWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range work {
+				gameID, ok := nhlGameID(key)
+				var goals *GameGoals
+				err := fmt.Errorf("no NHL game ID for %s", key)
+				if ok {
+					goals, err = fetchGameGoals(ctx, gameID)
+				}
+
+				mu.Lock()
+				if err != nil {
+					if ctx.Err() == nil {
+						log.Printf("Play-by-play for %s unavailable: %v", key, err)
+					}
+					missing = append(missing, key)
+				} else {
+					games[key] = goals
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, key := range keys {
+		select {
+		case work <- key:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(missing)
+	return games, missing, nil
+}
+
+// WarmNHLPlayByPlay fetches the play-by-play for every game in the latest
+// season of the shot data so the playmaking endpoints don't wait on the
+// NHL API. It returns the number of games cached.
+func WarmNHLPlayByPlay(ctx context.Context) (int, error) {
+	allShots, err := loadShots("")
+	if err != nil {
+		return 0, err
+	}
+	shots, err := ShotFilter{Season: latestSeason(allShots)}.Apply(allShots)
+	if err != nil {
+		return 0, err
+	}
+
+	games, missing, err := loadGameGoals(ctx, shots)
+	if err != nil {
+		return 0, err
+	}
+	if len(missing) > 0 {
+		log.Printf("Play-by-play missing for %d games", len(missing))
+	}
+	return len(games), nil
+}
@@ -0,0 +1,378 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// goalMatchWindow is how far apart, in seconds, a play-by-play goal and a
+// shot file goal by the same shooter can be and still be the same goal
+const goalMatchWindow = 3
+
+// defaultMinPlaymakingGames is the games a player needs to be listed
+const defaultMinPlaymakingGames = 10
+
+// PlaymakingStats are the goals a team or player set up
+type PlaymakingStats struct {
+	Assists                int     `json:"assists"`
+	PrimaryAssists         int     `json:"primaryAssists"`
+	SecondaryAssists       int     `json:"secondaryAssists"`
+	AssistedXG             float64 `json:"assistedXG"` // xG of the goals assisted on
+	PrimaryAssistXG        float64 `json:"primaryAssistXG"`
+	AssistsPerGame         float64 `json:"assistsPerGame"`
+	PrimaryAssistsPerGame  float64 `json:"primaryAssistsPerGame"`
+	AssistedXGPerGame      float64 `json:"assistedXGPerGame"`
+	PrimaryAssistShare     float64 `json:"primaryAssistShare"` // Percent of assists that were primary
+	AssistedGoalsUnmatched int     `json:"assistedGoalsUnmatched,omitempty"`
+}
+
+// playmakingTotals accumulates a PlaymakingStats
+type playmakingTotals struct {
+	assists, primary, secondary int
+	assistedXG, primaryXG       float64
+	unmatched                   int
+}
+
+// add credits one assist on a goal worth xGoal. matched is false when the
+// goal couldn't be found in the shot file, so its xG is unknown.
+func (t *playmakingTotals) add(primary bool, xGoal float64, matched bool) {
+	t.assists++
+	t.assistedXG += xGoal
+	if primary {
+		t.primary++
+		t.primaryXG += xGoal
+	} else {
+		t.secondary++
+	}
+	if !matched {
+		t.unmatched++
+	}
+}
+
+func (t *playmakingTotals) result(games int) PlaymakingStats {
+	return PlaymakingStats{
+		Assists:                t.assists,
+		PrimaryAssists:         t.primary,
+		SecondaryAssists:       t.secondary,
+		AssistedXG:             round2(t.assistedXG),
+		PrimaryAssistXG:        round2(t.primaryXG),
+		AssistsPerGame:         round2(safeDiv(float64(t.assists), float64(games))),
+		PrimaryAssistsPerGame:  round2(safeDiv(float64(t.primary), float64(games))),
+		AssistedXGPerGame:      round2(safeDiv(t.assistedXG, float64(games))),
+		PrimaryAssistShare:     round1(safeDiv(float64(t.primary), float64(t.assists)) * 100),
+		AssistedGoalsUnmatched: t.unmatched,
+	}
+}
+
+// TeamPlaymaking is a team's assists, with the goals they came on
+type TeamPlaymaking struct {
+	Team            string  `json:"team"`
+	GamesPlayed     int     `json:"gamesPlayed"`
+	Goals           int     `json:"goals"`
+	AssistedGoals   int     `json:"assistedGoals"`
+	UnassistedGoals int     `json:"unassistedGoals"`
+	AssistedRate    float64 `json:"assistedRate"` // Percent of goals with an assist
+	PlaymakingStats
+	AssistsByPosition map[string]int `json:"assistsByPosition"` // C, L, R, D, G
+}
+
+// PlayerPlaymaking is a player's assists
+type PlayerPlaymaking struct {
+	PlayerID    string `json:"playerId"`
+	PlayerName  string `json:"playerName"`
+	Position    string `json:"position"`
+	TeamCode    string `json:"teamCode"`
+	GamesPlayed int    `json:"gamesPlayed"` // Games dressed
+	Goals       int    `json:"goals"`
+	Points      int    `json:"points"`
+	PlaymakingStats
+}
+
+// playmakingResult is the league's assists over a set of games
+type playmakingResult struct {
+	teams   map[string]*TeamPlaymaking
+	players map[string]*PlayerPlaymaking
+	missing []string
+	// teamPlayerAssists are assists by team then player ID, so traded
+	// players' assists stay with the team they were made for
+	teamPlayerAssists map[string]map[string]int
+}
+
+// goalXG finds the shot file row for a play-by-play goal and returns its
+// xG. Goals are matched on shooter ID and game time, allowing for the few
+// seconds the two sources sometimes disagree by.
+func goalXG(goal GameGoal, shots []ShotData) (float64, bool) {
+	best, bestDiff := -1, goalMatchWindow+1
+	for i, shot := range shots {
+		if shot.ShooterPlayerID != goal.ScorerID {
+			continue
+		}
+		diff := shot.GameSeconds - goal.GameSeconds
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff < bestDiff {
+			best, bestDiff = i, diff
+		}
+	}
+	if best < 0 {
+		return 0, false
+	}
+	return shots[best].XGoal, true
+}
+
+// buildPlaymaking credits assists from the play-by-play for the games in
+// shots. Teams come from the shot file so codes match the other NHL
+// endpoints; xG comes from the matching goal row.
+func buildPlaymaking(shots []ShotData, games map[string]*GameGoals, missing []string) *playmakingResult {
+	type gameTeams struct{ home, away string }
+	teamsByGame := make(map[string]gameTeams)
+	goalShots := make(map[string][]ShotData)
+	for _, shot := range shots {
+		key := gameKey(shot)
+		if _, ok := teamsByGame[key]; !ok {
+			teamsByGame[key] = gameTeams{home: shot.HomeTeamCode, away: shot.AwayTeamCode}
+		}
+		if shot.Goal {
+			goalShots[key] = append(goalShots[key], shot)
+		}
+	}
+
+	result := &playmakingResult{
+		teams:             make(map[string]*TeamPlaymaking),
+		players:           make(map[string]*PlayerPlaymaking),
+		missing:           missing,
+		teamPlayerAssists: make(map[string]map[string]int),
+	}
+	teamTotals := make(map[string]*playmakingTotals)
+	teamGames := make(map[string]map[string]bool)
+	playerTotals := make(map[string]*playmakingTotals)
+	playerGames := make(map[string]map[string]bool)
+
+	team := func(code string) *TeamPlaymaking {
+		t, ok := result.teams[code]
+		if !ok {
+			t = &TeamPlaymaking{Team: code, AssistsByPosition: make(map[string]int)}
+			result.teams[code] = t
+			result.teamPlayerAssists[code] = make(map[string]int)
+			teamTotals[code] = &playmakingTotals{}
+			teamGames[code] = make(map[string]bool)
+		}
+		return t
+	}
+	player := func(id string, info *GamePlayer, code string) *PlayerPlaymaking {
+		p, ok := result.players[id]
+		if !ok {
+			p = &PlayerPlaymaking{PlayerID: id}
+			result.players[id] = p
+			playerTotals[id] = &playmakingTotals{}
+			playerGames[id] = make(map[string]bool)
+		}
+		// Keep the most recent team for players who were traded
+		if info != nil {
+			p.PlayerName, p.Position = info.Name, info.Position
+		}
+		p.TeamCode = code
+		return p
+	}
+
+	keys := make([]string, 0, len(games))
+	for key := range games {
+		keys = append(keys, key)
+	}
+	order := newGameOrder()
+	for _, shot := range shots {
+		order.add(shot)
+	}
+	order.sort(keys)
+
+	for _, key := range keys {
+		game, codes := games[key], teamsByGame[key]
+		if codes.home == "" || codes.away == "" {
+			continue
+		}
+		codeFor := func(home bool) string {
+			if home {
+				return codes.home
+			}
+			return codes.away
+		}
+		for _, code := range []string{codes.home, codes.away} {
+			team(code)
+			teamGames[code][key] = true
+		}
+		for id, info := range game.Players {
+			if id == "" {
+				continue
+			}
+			player(id, info, codeFor(info.Home))
+			playerGames[id][key] = true
+		}
+
+		for _, goal := range game.Goals {
+			code := codeFor(goal.HomeTeamGoal)
+			t := team(code)
+			t.Goals++
+			if goal.ScorerID != "" {
+				player(goal.ScorerID, game.Players[goal.ScorerID], code).Goals++
+			}
+			if goal.PrimaryID == "" {
+				t.UnassistedGoals++
+				continue
+			}
+			t.AssistedGoals++
+
+			xGoal, matched := goalXG(goal, goalShots[key])
+			for _, assist := range []struct {
+				id      string
+				primary bool
+			}{{goal.PrimaryID, true}, {goal.SecondaryID, false}} {
+				if assist.id == "" {
+					continue
+				}
+				info := game.Players[assist.id]
+				player(assist.id, info, code)
+				playerTotals[assist.id].add(assist.primary, xGoal, matched)
+				teamTotals[code].add(assist.primary, xGoal, matched)
+				result.teamPlayerAssists[code][assist.id]++
+				if info != nil && info.Position != "" {
+					t.AssistsByPosition[info.Position]++
+				}
+			}
+		}
+	}
+
+	for code, t := range result.teams {
+		t.GamesPlayed = len(teamGames[code])
+		t.AssistedRate = round1(safeDiv(float64(t.AssistedGoals), float64(t.Goals)) * 100)
+		t.PlaymakingStats = teamTotals[code].result(t.GamesPlayed)
+	}
+	for id, p := range result.players {
+		p.GamesPlayed = len(playerGames[id])
+		p.Points = p.Goals + playerTotals[id].assists
+		p.PlaymakingStats = playerTotals[id].result(p.GamesPlayed)
+	}
+	return result
+}
+
+// loadPlaymaking filters the request's dataset by the query and credits
+// the assists in those games
+func loadPlaymaking(c echo.Context, allShots []ShotData) (*playmakingResult, ShotFilter, int, map[string]string) {
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return nil, filter, http.StatusBadRequest, map[string]string{"error": err.Error()}
+	}
+
+	games, missing, err := loadGameGoals(c.Request().Context(), shots)
+	if err != nil {
+		return nil, filter, http.StatusServiceUnavailable, map[string]string{"error": "Request cancelled while loading play-by-play"}
+	}
+	if len(games) == 0 && len(missing) > 0 {
+		return nil, filter, http.StatusBadGateway, map[string]string{"error": "NHL play-by-play is unavailable"}
+	}
+	return buildPlaymaking(shots, games, missing), filter, http.StatusOK, nil
+}
+
+var playmakingMetadata = map[string]interface{}{
+	"source":                 "Assists come from the NHL play-by-play, keyed by NHL player ID; games and team codes come from the shot data",
+	"assistedXG":             "xG of the shot each assisted goal was scored on, credited in full to both assisters",
+	"assistedGoalsUnmatched": "Assists on goals that couldn't be matched to a shot row, so they add no xG",
+	"gamesPlayed":            "Teams: games in the shot data. Players: games dressed",
+	"shootouts":              "Shootout goals aren't counted",
+}
+
+// ProcessPlaymakingHandler reports primary and secondary assists and
+// assisted xG for every team.
+//
+// Query params: the shared shot filters.
+func ProcessPlaymakingHandler(c echo.Context) error {
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+	result, filter, status, errBody := loadPlaymaking(c, allShots)
+	if errBody != nil {
+		return c.JSON(status, errBody)
+	}
+
+	teams := make([]*TeamPlaymaking, 0, len(result.teams))
+	for _, team := range result.teams {
+		if filter.includesTeam(team.Team) {
+			teams = append(teams, team)
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		if teams[i].AssistsPerGame != teams[j].AssistsPerGame {
+			return teams[i].AssistsPerGame > teams[j].AssistsPerGame
+		}
+		return teams[i].Team < teams[j].Team
+	})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"teams":        teams,
+		"gamesMissing": result.missing,
+		"metadata":     playmakingMetadata,
+	})
+}
+
+// PlayerPlaymakingHandler reports primary and secondary assists and
+// assisted xG for players, sorted by assists.
+//
+// Query params: the shared shot filters, player (NHL player ID), position
+// (C, L, R, D or G) and minGames (games dressed to be listed, default 10).
+func PlayerPlaymakingHandler(c echo.Context) error {
+	minGames := defaultMinPlaymakingGames
+	if value := c.QueryParam("minGames"); value != "" {
+		var err error
+		minGames, err = strconv.Atoi(value)
+		if err != nil || minGames < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "minGames must be a non-negative number"})
+		}
+	}
+	playerID := strings.TrimSpace(c.QueryParam("player"))
+	position := strings.ToUpper(strings.TrimSpace(c.QueryParam("position")))
+
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+	result, filter, status, errBody := loadPlaymaking(c, allShots)
+	if errBody != nil {
+		return c.JSON(status, errBody)
+	}
+
+	players := make([]*PlayerPlaymaking, 0, len(result.players))
+	for _, player := range result.players {
+		switch {
+		case player.GamesPlayed < minGames && player.PlayerID != playerID:
+		case !filter.includesTeam(player.TeamCode):
+		case playerID != "" && player.PlayerID != playerID:
+		case position != "" && player.Position != position:
+		default:
+			players = append(players, player)
+		}
+	}
+	sort.Slice(players, func(i, j int) bool {
+		if players[i].Assists != players[j].Assists {
+			return players[i].Assists > players[j].Assists
+		}
+		if players[i].PrimaryAssists != players[j].PrimaryAssists {
+			return players[i].PrimaryAssists > players[j].PrimaryAssists
+		}
+		return players[i].PlayerID < players[j].PlayerID
+	})
+	if playerID != "" && len(players) == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No player " + playerID + " in these games"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"players":      players,
+		"minGames":     minGames,
+		"gamesMissing": result.missing,
+		"metadata":     playmakingMetadata,
+	})
+}
@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Skater positions the splits are broken down by, as MoneyPuck writes them
+var splitPositions = []string{"C", "L", "R", "D"}
+
+// PositionSplit is one position's scoring for or against a team. Adjusted
+// rates scale the raw per-game rate by how the opponents faced compare to
+// the league: allowing 0.5 defenseman goals a game against opponents whose
+// defensemen average 0.4 a game, when the league averages 0.45, adjusts to
+// 0.5 * 0.45 / 0.4.
+type PositionSplit struct {
+	Goals        int     `json:"goals"`
+	ShotsOnGoal  int     `json:"shotsOnGoal"`
+	XGoals       float64 `json:"xGoals"`
+	GoalShare    float64 `json:"goalShare"` // Percent of the team's goals for or against
+	GoalsPerGame float64 `json:"goalsPerGame"`
+	ShotsPerGame float64 `json:"shotsPerGame"`
+	XGPerGame    float64 `json:"xgPerGame"`
+	GoalsPer60   float64 `json:"goalsPer60"`
+	ShotsPer60   float64 `json:"shotsPer60"`
+	XGPer60      float64 `json:"xgPer60"`
+
+	AdjGoalsPerGame float64 `json:"adjGoalsPerGame"`
+	AdjShotsPerGame float64 `json:"adjShotsPerGame"`
+	AdjXGPerGame    float64 `json:"adjXGPerGame"`
+
+	// Ranks are by adjusted rate, 1 being the most for or the most allowed
+	GoalsRank int `json:"goalsRank,omitempty"`
+	ShotsRank int `json:"shotsRank,omitempty"`
+	XGRank    int `json:"xgRank,omitempty"`
+}
+
+// TeamPositionSplits are a team's scoring by position for and against
+type TeamPositionSplits struct {
+	Team        string                    `json:"team"`
+	GamesPlayed int                       `json:"gamesPlayed"`
+	Minutes     float64                   `json:"minutes"`
+	For         map[string]*PositionSplit `json:"for"`
+	Against     map[string]*PositionSplit `json:"against"`
+}
+
+// positionTotals are raw counts for one position
+type positionTotals struct {
+	goals, shots int
+	xGoals       float64
+}
+
+// perGame returns the totals divided by games
+func (t positionTotals) perGame(games int) [3]float64 {
+	g := float64(games)
+	return [3]float64{safeDiv(float64(t.goals), g), safeDiv(float64(t.shots), g), safeDiv(t.xGoals, g)}
+}
+
+// teamSplitTotals accumulates a team's splits and schedule
+type teamSplitTotals struct {
+	games     map[string]bool
+	seconds   int
+	opponents []string // One entry per game
+	forPos    map[string]*positionTotals
+	against   map[string]*positionTotals
+}
+
+func newTeamSplitTotals() *teamSplitTotals {
+	t := &teamSplitTotals{
+		games:   make(map[string]bool),
+		forPos:  make(map[string]*positionTotals, len(splitPositions)),
+		against: make(map[string]*positionTotals, len(splitPositions)),
+	}
+	for _, pos := range splitPositions {
+		t.forPos[pos] = &positionTotals{}
+		t.against[pos] = &positionTotals{}
+	}
+	return t
+}
+
+// gameLengths returns how long each game in shots ran, in seconds: 60
+// minutes plus however far into overtime the last event came
+func gameLengths(shots []ShotData) map[string]int {
+	lengths := make(map[string]int)
+	for _, shot := range shots {
+		key := gameKey(shot)
+		lengths[key] = max(lengths[key], regulationSeconds, shot.GameSeconds)
+	}
+	return lengths
+}
+
+// buildPositionSplits works out every team's splits, raw and opponent
+// adjusted, and ranks them
+func buildPositionSplits(shots []ShotData) ([]*TeamPositionSplits, map[string]*PositionSplit) {
+	lengths := gameLengths(shots)
+	teams := make(map[string]*teamSplitTotals)
+	team := func(code string) *teamSplitTotals {
+		t, ok := teams[code]
+		if !ok {
+			t = newTeamSplitTotals()
+			teams[code] = t
+		}
+		return t
+	}
+
+	for _, shot := range shots {
+		if shot.HomeTeamCode == "" || shot.AwayTeamCode == "" {
+			continue
+		}
+		key := gameKey(shot)
+		for _, side := range [][2]string{{shot.HomeTeamCode, shot.AwayTeamCode}, {shot.AwayTeamCode, shot.HomeTeamCode}} {
+			t := team(side[0])
+			if !t.games[key] {
+				t.games[key] = true
+				t.seconds += lengths[key]
+				t.opponents = append(t.opponents, side[1])
+			}
+		}
+
+		shooting, ok := team(shot.TeamCode).forPos[shot.PlayerPosition]
+		if !ok {
+			continue
+		}
+		defending := team(defendingTeam(shot)).against[shot.PlayerPosition]
+		for _, totals := range []*positionTotals{shooting, defending} {
+			if isShotOnGoal(shot) {
+				totals.shots++
+			}
+			if shot.Goal {
+				totals.goals++
+			}
+			if !isBlockedShot(shot) {
+				totals.xGoals += shot.XGoal
+			}
+		}
+	}
+
+	// League per team-game rates, the same for and against
+	league := make(map[string]positionTotals, len(splitPositions))
+	teamGames, teamSeconds := 0, 0
+	for _, t := range teams {
+		teamGames += len(t.games)
+		teamSeconds += t.seconds
+		for _, pos := range splitPositions {
+			l := league[pos]
+			l.goals += t.forPos[pos].goals
+			l.shots += t.forPos[pos].shots
+			l.xGoals += t.forPos[pos].xGoals
+			league[pos] = l
+		}
+	}
+	leagueRates := make(map[string][3]float64, len(splitPositions))
+	leagueSplits := make(map[string]*PositionSplit, len(splitPositions))
+	for _, pos := range splitPositions {
+		leagueRates[pos] = league[pos].perGame(teamGames)
+		leagueSplits[pos] = positionSplit(league[pos], teamGames, teamSeconds, 0, leagueRates[pos], leagueRates[pos])
+	}
+
+	// Each team's raw per-game rates, used as opponent strength
+	forRates := make(map[string]map[string][3]float64, len(teams))
+	againstRates := make(map[string]map[string][3]float64, len(teams))
+	for code, t := range teams {
+		forRates[code] = make(map[string][3]float64, len(splitPositions))
+		againstRates[code] = make(map[string][3]float64, len(splitPositions))
+		for _, pos := range splitPositions {
+			forRates[code][pos] = t.forPos[pos].perGame(len(t.games))
+			againstRates[code][pos] = t.against[pos].perGame(len(t.games))
+		}
+	}
+
+	result := make([]*TeamPositionSplits, 0, len(teams))
+	for code, t := range teams {
+		splits := &TeamPositionSplits{
+			Team:        code,
+			GamesPlayed: len(t.games),
+			Minutes:     round1(float64(t.seconds) / 60),
+			For:         make(map[string]*PositionSplit, len(splitPositions)),
+			Against:     make(map[string]*PositionSplit, len(splitPositions)),
+		}
+
+		goalsFor, goalsAgainst := 0, 0
+		for _, pos := range splitPositions {
+			goalsFor += t.forPos[pos].goals
+			goalsAgainst += t.against[pos].goals
+		}
+
+		for _, pos := range splitPositions {
+			// What this team's opponents usually allow and create
+			var oppAllow, oppCreate [3]float64
+			for _, opp := range t.opponents {
+				for i := range oppAllow {
+					oppAllow[i] += againstRates[opp][pos][i] / float64(len(t.opponents))
+					oppCreate[i] += forRates[opp][pos][i] / float64(len(t.opponents))
+				}
+			}
+			splits.For[pos] = positionSplit(*t.forPos[pos], len(t.games), t.seconds, goalsFor, oppAllow, leagueRates[pos])
+			splits.Against[pos] = positionSplit(*t.against[pos], len(t.games), t.seconds, goalsAgainst, oppCreate, leagueRates[pos])
+		}
+		result = append(result, splits)
+	}
+
+	for _, pos := range splitPositions {
+		rankSplits(result, func(s *TeamPositionSplits) *PositionSplit { return s.For[pos] })
+		rankSplits(result, func(s *TeamPositionSplits) *PositionSplit { return s.Against[pos] })
+	}
+	return result, leagueSplits
+}
+
+// positionSplit turns raw totals into rates. schedule is the opponents'
+// average rate on the other side of the puck; league is the league's.
+func positionSplit(t positionTotals, games, seconds, teamGoals int, schedule, league [3]float64) *PositionSplit {
+	raw := t.perGame(games)
+	per60 := func(n float64) float64 { return safeDiv(n*3600, float64(seconds)) }
+	adjust := func(i int) float64 {
+		if schedule[i] == 0 {
+			return raw[i]
+		}
+		return raw[i] * league[i] / schedule[i]
+	}
+	return &PositionSplit{
+		Goals:           t.goals,
+		ShotsOnGoal:     t.shots,
+		XGoals:          round2(t.xGoals),
+		GoalShare:       round1(safeDiv(float64(t.goals), float64(teamGoals)) * 100),
+		GoalsPerGame:    round3(raw[0]),
+		ShotsPerGame:    round2(raw[1]),
+		XGPerGame:       round3(raw[2]),
+		GoalsPer60:      round3(per60(float64(t.goals))),
+		ShotsPer60:      round2(per60(float64(t.shots))),
+		XGPer60:         round3(per60(t.xGoals)),
+		AdjGoalsPerGame: round3(adjust(0)),
+		AdjShotsPerGame: round2(adjust(1)),
+		AdjXGPerGame:    round3(adjust(2)),
+	}
+}
+
+// rankSplits ranks one position's splits across teams by adjusted rate,
+// 1 being the highest. Ties share a rank.
+func rankSplits(teams []*TeamPositionSplits, split func(s *TeamPositionSplits) *PositionSplit) {
+	splits := make([]*PositionSplit, len(teams))
+	for i, team := range teams {
+		splits[i] = split(team)
+	}
+	rank := func(value func(s *PositionSplit) float64, set func(s *PositionSplit, rank int)) {
+		rankBy(splits, false, value, set)
+	}
+	rank(func(s *PositionSplit) float64 { return s.AdjGoalsPerGame }, func(s *PositionSplit, r int) { s.GoalsRank = r })
+	rank(func(s *PositionSplit) float64 { return s.AdjShotsPerGame }, func(s *PositionSplit, r int) { s.ShotsRank = r })
+	rank(func(s *PositionSplit) float64 { return s.AdjXGPerGame }, func(s *PositionSplit, r int) { s.XGRank = r })
+}
+
+var positionSplitsMetadata = map[string]interface{}{
+	"positions": "C, L, R and D, from the shooter's position; goals by goalies and rows without a position are left out",
+	"adjusted":  "Raw per-game rate scaled by the league rate over the schedule's rate: what opponents usually allow (for) or create (against) at that position",
+	"per60":     "Per 60 minutes of game time, counting overtime",
+	"ranks":     "By adjusted per-game rate; 1 scores the most, or on the against side allows the most",
+	"league":    "Per team-game, so it lines up with a team's for or against",
+	"xGoals":    "Unblocked attempts only",
+}
+
+// ProcessPositionSplitsHandler reports goals, shots on goal and xG for and
+// against every team by shooter position, per game, per 60 and adjusted
+// for opponents, ranked across the league.
+//
+// Query params: the shared shot filters and position (C, L, R or D),
+// which sorts teams by the adjusted goals they allow to that position.
+func ProcessPositionSplitsHandler(c echo.Context) error {
+	position := strings.ToUpper(strings.TrimSpace(c.QueryParam("position")))
+	if position != "" {
+		valid := false
+		for _, pos := range splitPositions {
+			valid = valid || pos == position
+		}
+		if !valid {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "position must be C, L, R or D"})
+		}
+	}
+
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	teams, league := buildPositionSplits(shots)
+	sort.Slice(teams, func(i, j int) bool {
+		if position != "" {
+			a, b := teams[i].Against[position], teams[j].Against[position]
+			if a.AdjGoalsPerGame != b.AdjGoalsPerGame {
+				return a.AdjGoalsPerGame > b.AdjGoalsPerGame
+			}
+		}
+		return teams[i].Team < teams[j].Team
+	})
+
+	filtered := make([]*TeamPositionSplits, 0, len(teams))
+	for _, team := range teams {
+		if filter.includesTeam(team.Team) {
+			filtered = append(filtered, team)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"teams":    filtered,
+		"league":   league,
+		"metadata": positionSplitsMetadata,
+	})
+}
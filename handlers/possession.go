@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Possession splits. Score states are 5v5 only, the usual convention,
+// since special teams time would otherwise swamp them.
+const (
+	splitAll         = "all"
+	splitFiveOnFive  = "5v5"
+	splitPowerPlay   = "powerPlay"
+	splitPenaltyKill = "penaltyKill"
+	splitLeading     = "leading"
+	splitTied        = "tied"
+	splitTrailing    = "trailing"
+)
+
+var possessionSplits = []string{
+	splitAll, splitFiveOnFive, splitPowerPlay, splitPenaltyKill, splitLeading, splitTied, splitTrailing,
+}
+
+// maxScoreDiff caps the score states used for adjustment; anything beyond
+// a three-goal lead plays the same
+const maxScoreDiff = 3
+
+// PossessionSplit holds the shot-share metrics for one team in one split.
+// Corsi counts every shot attempt, Fenwick leaves out blocked shots. The
+// MoneyPuck file has no blocked shots, so there the two match.
+type PossessionSplit struct {
+	CF     int     `json:"cf"`
+	CA     int     `json:"ca"`
+	CFPct  float64 `json:"cfPct"`
+	FF     int     `json:"ff"`
+	FA     int     `json:"fa"`
+	FFPct  float64 `json:"ffPct"`
+	SF     int     `json:"sf"`
+	SA     int     `json:"sa"`
+	GF     int     `json:"gf"`
+	GA     int     `json:"ga"`
+	XGF    float64 `json:"xgf"`
+	XGA    float64 `json:"xga"`
+	XGFPct float64 `json:"xgfPct"`
+
+	// PDO is on-ice shooting % plus save %, around 100 for an average team
+	ShootingPct float64 `json:"shootingPct"`
+	SavePct     float64 `json:"savePct"`
+	PDO         float64 `json:"pdo"`
+
+	// Score- and venue-adjusted shares weight each attempt by how often
+	// teams in that score state and venue generate attempts league-wide
+	AdjCFPct  float64 `json:"adjCfPct"`
+	AdjFFPct  float64 `json:"adjFfPct"`
+	AdjXGFPct float64 `json:"adjXgfPct"`
+
+	adjCF, adjCA, adjFF, adjFA, adjXGF, adjXGA float64
+}
+
+// TeamPossession is a team's possession metrics in every split
+type TeamPossession struct {
+	Team        string                      `json:"team"`
+	GamesPlayed int                         `json:"gamesPlayed"`
+	Rank        int                         `json:"rank"` // By 5v5 adjusted xGF%
+	Splits      map[string]*PossessionSplit `json:"splits"`
+}
+
+// scoreState is a team's venue and score differential at the time of a shot
+type scoreState struct {
+	home bool
+	diff int
+}
+
+func (s scoreState) opponent() scoreState {
+	return scoreState{home: !s.home, diff: -s.diff}
+}
+
+// adjustmentWeights holds the league share of attempts (or xG) taken by a
+// team in each score state
+type adjustmentWeights map[scoreState]float64
+
+// forWeight scales an attempt taken in state s so that an average team
+// comes out at 50% in every state
+func (w adjustmentWeights) forWeight(s scoreState) float64 {
+	share, ok := w[s]
+	if !ok || share == 0 {
+		return 1
+	}
+	return 0.5 / share
+}
+
+// againstWeight scales an attempt allowed while the team was in state s
+func (w adjustmentWeights) againstWeight(s scoreState) float64 {
+	share, ok := w[s]
+	if !ok || share == 1 {
+		return 1
+	}
+	return 0.5 / (1 - share)
+}
+
+// ProcessPossessionHandler reports Corsi, Fenwick, xG share and PDO per
+// team, split by strength and score state, with score- and venue-adjusted
+// versions.
+func ProcessPossessionHandler(c echo.Context) error {
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	teams := aggregatePossession(shots)
+
+	results := make([]*TeamPossession, 0, len(teams))
+	for _, team := range teams {
+		results = append(results, team)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i].Splits[splitFiveOnFive].AdjXGFPct, results[j].Splits[splitFiveOnFive].AdjXGFPct
+		if a != b {
+			return a > b
+		}
+		return results[i].Team < results[j].Team
+	})
+
+	// Rank against the whole league, then trim to the requested team
+	filtered := make([]*TeamPossession, 0, len(results))
+	for i, team := range results {
+		team.Rank = i + 1
+		if filter.includesTeam(team.Team) {
+			filtered = append(filtered, team)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"teams": filtered,
+		"metadata": map[string]interface{}{
+			"splits":      possessionSplits,
+			"scoreStates": "Leading, tied and trailing are 5v5 only",
+			"adjustment":  "Attempts are weighted by 0.5 / league share of attempts taken in the same venue and score state (capped at +/-3)",
+			"pdo":         "On-ice shooting % + save %",
+		},
+	})
+}
+
+// aggregatePossession builds every team's splits from shots
+func aggregatePossession(shots []ShotData) map[string]*TeamPossession {
+	corsiWeights, fenwickWeights, xgWeights := possessionWeights(shots)
+
+	teams := make(map[string]*TeamPossession)
+	games := make(map[string]map[string]bool)
+	team := func(code string) *TeamPossession {
+		t, ok := teams[code]
+		if !ok {
+			t = &TeamPossession{Team: code, Splits: make(map[string]*PossessionSplit)}
+			for _, split := range possessionSplits {
+				t.Splits[split] = &PossessionSplit{}
+			}
+			teams[code] = t
+			games[code] = make(map[string]bool)
+		}
+		return t
+	}
+
+	for _, shot := range shots {
+		if shot.TeamCode == "" || shot.HomeTeamCode == "" || shot.AwayTeamCode == "" {
+			continue
+		}
+		shooter := team(shot.TeamCode)
+		defender := team(defendingTeam(shot))
+		games[shooter.Team][gameKey(shot)] = true
+		games[defender.Team][gameKey(shot)] = true
+
+		state := shooterScoreState(shot)
+		blocked := isBlockedShot(shot)
+		onGoal := isShotOnGoal(shot)
+
+		for _, split := range shotSplits(shot, true) {
+			s := shooter.Splits[split]
+			s.CF++
+			s.adjCF += corsiWeights.forWeight(state)
+			if !blocked {
+				s.FF++
+				s.adjFF += fenwickWeights.forWeight(state)
+				s.XGF += shot.XGoal
+				s.adjXGF += shot.XGoal * xgWeights.forWeight(state)
+			}
+			if onGoal {
+				s.SF++
+			}
+			if shot.Goal {
+				s.GF++
+			}
+		}
+
+		opponentState := state.opponent()
+		for _, split := range shotSplits(shot, false) {
+			s := defender.Splits[split]
+			s.CA++
+			s.adjCA += corsiWeights.againstWeight(opponentState)
+			if !blocked {
+				s.FA++
+				s.adjFA += fenwickWeights.againstWeight(opponentState)
+				s.XGA += shot.XGoal
+				s.adjXGA += shot.XGoal * xgWeights.againstWeight(opponentState)
+			}
+			if onGoal {
+				s.SA++
+			}
+			if shot.Goal {
+				s.GA++
+			}
+		}
+	}
+
+	for code, t := range teams {
+		t.GamesPlayed = len(games[code])
+		for _, s := range t.Splits {
+			s.finish()
+		}
+	}
+	return teams
+}
+
+func (s *PossessionSplit) finish() {
+	s.CFPct = round1(safeDiv(float64(s.CF), float64(s.CF+s.CA)) * 100)
+	s.FFPct = round1(safeDiv(float64(s.FF), float64(s.FF+s.FA)) * 100)
+	s.XGFPct = round1(safeDiv(s.XGF, s.XGF+s.XGA) * 100)
+	s.XGF = round2(s.XGF)
+	s.XGA = round2(s.XGA)
+
+	shootingPct := safeDiv(float64(s.GF), float64(s.SF)) * 100
+	savePct := (1 - safeDiv(float64(s.GA), float64(s.SA))) * 100
+	s.ShootingPct = round1(shootingPct)
+	s.SavePct = round1(savePct)
+	if s.SF > 0 && s.SA > 0 {
+		s.PDO = round1(shootingPct + savePct)
+	}
+
+	s.AdjCFPct = round1(safeDiv(s.adjCF, s.adjCF+s.adjCA) * 100)
+	s.AdjFFPct = round1(safeDiv(s.adjFF, s.adjFF+s.adjFA) * 100)
+	s.AdjXGFPct = round1(safeDiv(s.adjXGF, s.adjXGF+s.adjXGA) * 100)
+}
+
+// shooterScoreState returns the shooting team's venue and score state
+func shooterScoreState(shot ShotData) scoreState {
+	home := shot.TeamCode == shot.HomeTeamCode
+	diff := shot.HomeTeamGoals - shot.AwayTeamGoals
+	if !home {
+		diff = -diff
+	}
+	if diff > maxScoreDiff {
+		diff = maxScoreDiff
+	} else if diff < -maxScoreDiff {
+		diff = -maxScoreDiff
+	}
+	return scoreState{home: home, diff: diff}
+}
+
+// teamSkaters returns the shooting team's and the defending team's skaters
+func teamSkaters(shot ShotData) (int, int) {
+	if shot.TeamCode == shot.HomeTeamCode {
+		return shot.HomeSkatersOnIce, shot.AwaySkatersOnIce
+	}
+	return shot.AwaySkatersOnIce, shot.HomeSkatersOnIce
+}
+
+// shotSplits lists the splits a shot counts toward, from the shooting
+// team's side when forShooter is set and the defending team's otherwise
+func shotSplits(shot ShotData, forShooter bool) []string {
+	splits := []string{splitAll}
+
+	skaters, opponents := teamSkaters(shot)
+	diff := shooterScoreState(shot).diff
+	if !forShooter {
+		skaters, opponents = opponents, skaters
+		diff = -diff
+	}
+
+	switch {
+	case skaters == 5 && opponents == 5:
+		splits = append(splits, splitFiveOnFive)
+		switch {
+		case diff > 0:
+			splits = append(splits, splitLeading)
+		case diff < 0:
+			splits = append(splits, splitTrailing)
+		default:
+			splits = append(splits, splitTied)
+		}
+	case skaters > opponents:
+		splits = append(splits, splitPowerPlay)
+	case skaters < opponents:
+		splits = append(splits, splitPenaltyKill)
+	}
+	return splits
+}
+
+// possessionWeights measures the league share of attempts, unblocked
+// attempts and xG taken by teams in each score state
+func possessionWeights(shots []ShotData) (adjustmentWeights, adjustmentWeights, adjustmentWeights) {
+	corsi := make(map[scoreState]float64)
+	fenwick := make(map[scoreState]float64)
+	xg := make(map[scoreState]float64)
+
+	for _, shot := range shots {
+		if shot.TeamCode == "" || shot.HomeTeamCode == "" {
+			continue
+		}
+		state := shooterScoreState(shot)
+		corsi[state]++
+		if !isBlockedShot(shot) {
+			fenwick[state]++
+			xg[state] += shot.XGoal
+		}
+	}
+
+	share := func(totals map[scoreState]float64) adjustmentWeights {
+		weights := make(adjustmentWeights)
+		for state, taken := range totals {
+			// A state only one side ever reached has no share to compare
+			allowed := totals[state.opponent()]
+			if taken > 0 && allowed > 0 {
+				weights[state] = taken / (taken + allowed)
+			}
+		}
+		return weights
+	}
+	return share(corsi), share(fenwick), share(xg)
+}
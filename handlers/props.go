@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+)
+
+// earlyGoalSeconds is the window for the goal-in-the-first-10-minutes prop
+const earlyGoalSeconds = 600
+
+// Periods tracked by the props, overtime last. Period winner and totals
+// props are only offered on regulation periods.
+var propPeriods = []string{"1", "2", "3", "OT"}
+
+const regulationPeriods = 3
+
+// propGame is what the props need from a game: who scored first and when,
+// and each side's goals by period. Shootout goals aren't in the shot data,
+// so they never count.
+type propGame struct {
+	home, away   string
+	firstTeam    string // Empty when nobody scored
+	firstSeconds int
+	// Each side's first goal, -1 when it didn't score
+	homeFirst, awayFirst   int
+	homePeriod, awayPeriod [4]int
+}
+
+// propGames rebuilds every game in shots, keyed by gameKey
+func propGames(shots []ShotData) map[string]*propGame {
+	games := make(map[string]*propGame)
+	for _, shot := range shots {
+		key := gameKey(shot)
+		game, ok := games[key]
+		if !ok {
+			game = &propGame{home: shot.HomeTeamCode, away: shot.AwayTeamCode, homeFirst: -1, awayFirst: -1}
+			games[key] = game
+		}
+		if !shot.Goal {
+			continue
+		}
+
+		// Use the exact second rather than file order, and credit the
+		// first goal per game rather than per game ID
+		if game.firstTeam == "" || shot.GameSeconds < game.firstSeconds {
+			game.firstTeam, game.firstSeconds = shot.TeamCode, shot.GameSeconds
+		}
+
+		period := shot.Period
+		if period == 0 {
+			period = shot.GameSeconds/periodSeconds + 1
+		}
+		idx := min(period, len(propPeriods)) - 1
+		side, first := &game.awayPeriod, &game.awayFirst
+		if shot.TeamCode == game.home {
+			side, first = &game.homePeriod, &game.homeFirst
+		}
+		side[idx]++
+		if *first < 0 || shot.GameSeconds < *first {
+			*first = shot.GameSeconds
+		}
+	}
+	return games
+}
+
+// PropRate is how often a prop hit, with the sample it's from
+type PropRate struct {
+	Count       int     `json:"count"`
+	Games       int     `json:"games"`
+	Probability float64 `json:"probability"`
+}
+
+func newPropRate(count, games int) PropRate {
+	return PropRate{Count: count, Games: games, Probability: round3(safeDiv(float64(count), float64(games)))}
+}
+
+// PeriodProps are one period's scoring from a team's side. Win, Tie and
+// Loss are who outscored whom in the period alone.
+type PeriodProps struct {
+	Period       string    `json:"period"`
+	GoalsFor     float64   `json:"goalsFor"` // Per game
+	GoalsAgainst float64   `json:"goalsAgainst"`
+	Win          *PropRate `json:"win,omitempty"`
+	Tie          *PropRate `json:"tie,omitempty"`
+	Loss         *PropRate `json:"loss,omitempty"`
+	Over05       *PropRate `json:"over0_5,omitempty"` // Both teams' goals in the period
+	Over15       *PropRate `json:"over1_5,omitempty"`
+}
+
+// TeamProps are a team's first-goal and period scoring props. For the
+// league they're from the home team's side.
+type TeamProps struct {
+	Team                   string        `json:"team,omitempty"`
+	Games                  int           `json:"games"`
+	ScoresFirst            PropRate      `json:"scoresFirst"`
+	OpponentScoresFirst    PropRate      `json:"opponentScoresFirst"`
+	NoGoals                PropRate      `json:"noGoals"`
+	GoalInFirst10          PropRate      `json:"goalInFirst10"` // By either team
+	TeamGoalInFirst10      PropRate      `json:"teamGoalInFirst10"`
+	BothTeamsScore         PropRate      `json:"bothTeamsScore"`
+	AverageFirstGoalMinute float64       `json:"averageFirstGoalMinute"` // Either team, games with a goal
+	Periods                []PeriodProps `json:"periods"`
+}
+
+// propTotals accumulates a TeamProps
+type propTotals struct {
+	games                 int
+	scoredFirst, oppFirst int
+	noGoals               int
+	early, teamEarly      int
+	btts                  int
+	firstGoalSeconds      int
+	periodGF, periodGA    [4]int
+	win, tie, loss        [regulationPeriods]int
+	over05, over15        [regulationPeriods]int
+}
+
+// add counts a game from one side. home says which side.
+func (t *propTotals) add(game *propGame, home bool) {
+	team, us, them, ourFirst := game.home, game.homePeriod, game.awayPeriod, game.homeFirst
+	if !home {
+		team, us, them, ourFirst = game.away, game.awayPeriod, game.homePeriod, game.awayFirst
+	}
+
+	t.games++
+	switch game.firstTeam {
+	case "":
+		t.noGoals++
+	case team:
+		t.scoredFirst++
+	default:
+		t.oppFirst++
+	}
+	if game.firstTeam != "" {
+		t.firstGoalSeconds += game.firstSeconds
+		if game.firstSeconds < earlyGoalSeconds {
+			t.early++
+		}
+	}
+	if ourFirst >= 0 && ourFirst < earlyGoalSeconds {
+		t.teamEarly++
+	}
+
+	usTotal, themTotal := 0, 0
+	for p := range propPeriods {
+		t.periodGF[p] += us[p]
+		t.periodGA[p] += them[p]
+		usTotal += us[p]
+		themTotal += them[p]
+	}
+	if usTotal > 0 && themTotal > 0 {
+		t.btts++
+	}
+	for p := 0; p < regulationPeriods; p++ {
+		switch {
+		case us[p] > them[p]:
+			t.win[p]++
+		case us[p] < them[p]:
+			t.loss[p]++
+		default:
+			t.tie[p]++
+		}
+		if goals := us[p] + them[p]; goals > 0 {
+			t.over05[p]++
+			if goals > 1 {
+				t.over15[p]++
+			}
+		}
+	}
+}
+
+func (t *propTotals) result(team string) *TeamProps {
+	props := &TeamProps{
+		Team:                   team,
+		Games:                  t.games,
+		ScoresFirst:            newPropRate(t.scoredFirst, t.games),
+		OpponentScoresFirst:    newPropRate(t.oppFirst, t.games),
+		NoGoals:                newPropRate(t.noGoals, t.games),
+		GoalInFirst10:          newPropRate(t.early, t.games),
+		TeamGoalInFirst10:      newPropRate(t.teamEarly, t.games),
+		BothTeamsScore:         newPropRate(t.btts, t.games),
+		AverageFirstGoalMinute: round2(safeDiv(float64(t.firstGoalSeconds), float64(t.games-t.noGoals)*60)),
+		Periods:                make([]PeriodProps, len(propPeriods)),
+	}
+	for p, name := range propPeriods {
+		period := PeriodProps{
+			Period:       name,
+			GoalsFor:     round3(safeDiv(float64(t.periodGF[p]), float64(t.games))),
+			GoalsAgainst: round3(safeDiv(float64(t.periodGA[p]), float64(t.games))),
+		}
+		if p < regulationPeriods {
+			win, tie, loss := newPropRate(t.win[p], t.games), newPropRate(t.tie[p], t.games), newPropRate(t.loss[p], t.games)
+			over05, over15 := newPropRate(t.over05[p], t.games), newPropRate(t.over15[p], t.games)
+			period.Win, period.Tie, period.Loss = &win, &tie, &loss
+			period.Over05, period.Over15 = &over05, &over15
+		}
+		props.Periods[p] = period
+	}
+	return props
+}
+
+// PropOutcome is one side of a prop market priced at our probability.
+// Names and points follow the Odds API so the two can be lined up.
+type PropOutcome struct {
+	Name        string   `json:"name"`
+	Point       *float64 `json:"point,omitempty"`
+	Probability float64  `json:"probability"`
+	FairPrice   int      `json:"fairPrice,omitempty"` // American odds with no vig
+}
+
+// PropMarket is a market in the Odds API's shape
+type PropMarket struct {
+	Key      string        `json:"key"`
+	Outcomes []PropOutcome `json:"outcomes"`
+}
+
+// americanOdds converts a probability to fair American odds. Certainties
+// have no price and return 0.
+func americanOdds(p float64) int {
+	if p <= 0 || p >= 1 {
+		return 0
+	}
+	if p >= 0.5 {
+		return -int(math.Round(100 * p / (1 - p)))
+	}
+	return int(math.Round(100 * (1 - p) / p))
+}
+
+func propOutcome(name string, point *float64, p float64) PropOutcome {
+	return PropOutcome{Name: name, Point: point, Probability: round3(p), FairPrice: americanOdds(p)}
+}
+
+// normalize scales probabilities to sum to 1
+func normalize(ps ...float64) []float64 {
+	total := 0.0
+	for _, p := range ps {
+		total += p
+	}
+	out := make([]float64, len(ps))
+	for i, p := range ps {
+		out[i] = safeDiv(p, total)
+	}
+	return out
+}
+
+// matchupMarkets estimates a game's props from both teams' rates: each
+// side's rate averaged with the opponent's matching rate allowed, then
+// normalized across outcomes.
+func matchupMarkets(home, away *TeamProps) []PropMarket {
+	homeName, awayName := nhlTeamName(home.Team), nhlTeamName(away.Team)
+	avg := func(a, b float64) float64 { return (a + b) / 2 }
+	yesNo := func(key string, p float64) PropMarket {
+		return PropMarket{Key: key, Outcomes: []PropOutcome{propOutcome("Yes", nil, p), propOutcome("No", nil, 1-p)}}
+	}
+
+	first := normalize(
+		avg(home.ScoresFirst.Probability, away.OpponentScoresFirst.Probability),
+		avg(away.ScoresFirst.Probability, home.OpponentScoresFirst.Probability),
+		avg(home.NoGoals.Probability, away.NoGoals.Probability),
+	)
+	markets := []PropMarket{
+		{Key: "first_team_to_score", Outcomes: []PropOutcome{
+			propOutcome(homeName, nil, first[0]),
+			propOutcome(awayName, nil, first[1]),
+			propOutcome("No Goal", nil, first[2]),
+		}},
+		yesNo("btts", avg(home.BothTeamsScore.Probability, away.BothTeamsScore.Probability)),
+		yesNo("goal_first_10_minutes", avg(home.GoalInFirst10.Probability, away.GoalInFirst10.Probability)),
+	}
+
+	for p := 0; p < regulationPeriods; p++ {
+		h, a := home.Periods[p], away.Periods[p]
+		suffix := "_p" + propPeriods[p]
+		result := normalize(
+			avg(h.Win.Probability, a.Loss.Probability),
+			avg(a.Win.Probability, h.Loss.Probability),
+			avg(h.Tie.Probability, a.Tie.Probability),
+		)
+		markets = append(markets, PropMarket{Key: "h2h_3_way" + suffix, Outcomes: []PropOutcome{
+			propOutcome(homeName, nil, result[0]),
+			propOutcome(awayName, nil, result[1]),
+			propOutcome("Draw", nil, result[2]),
+		}})
+
+		totals := PropMarket{Key: "totals" + suffix}
+		for _, line := range []struct {
+			point float64
+			over  float64
+		}{
+			{0.5, avg(h.Over05.Probability, a.Over05.Probability)},
+			{1.5, avg(h.Over15.Probability, a.Over15.Probability)},
+		} {
+			point := line.point
+			totals.Outcomes = append(totals.Outcomes,
+				propOutcome("Over", &point, line.over),
+				propOutcome("Under", &point, 1-line.over))
+		}
+		markets = append(markets, totals)
+	}
+	return markets
+}
+
+var propsMetadata = map[string]interface{}{
+	"probability":   "Share of games the prop hit; games is the sample size",
+	"periods":       "Win, tie and loss compare the two teams' goals in that period alone; overtime has goals only",
+	"shootouts":     "Shootout goals aren't in the shot data, so a 0-0 game decided in a shootout counts as no goals",
+	"goalInFirst10": "A goal in the first 10 minutes of the game by either team; teamGoalInFirst10 is the team's own",
+	"league":        "League props are from the home team's side",
+}
+
+// teamPropTotals counts every game in games for both teams, plus the
+// league from the home side
+func teamPropTotals(games map[string]*propGame) (map[string]*propTotals, *propTotals) {
+	teams := make(map[string]*propTotals)
+	league := &propTotals{}
+	for _, game := range games {
+		if game.home == "" || game.away == "" {
+			continue
+		}
+		for _, side := range []struct {
+			team string
+			home bool
+		}{{game.home, true}, {game.away, false}} {
+			t, ok := teams[side.team]
+			if !ok {
+				t = &propTotals{}
+				teams[side.team] = t
+			}
+			t.add(game, side.home)
+		}
+		league.add(game, true)
+	}
+	return teams, league
+}
+
+// ProcessPropsHandler reports first-goal, early goal, both-teams-score
+// and period scoring props for every team with league baselines.
+//
+// Query params: the shared shot filters.
+func ProcessPropsHandler(c echo.Context) error {
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	totals, league := teamPropTotals(propGames(shots))
+	teams := make([]*TeamProps, 0, len(totals))
+	for team, t := range totals {
+		if filter.includesTeam(team) {
+			teams = append(teams, t.result(team))
+		}
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		if teams[i].ScoresFirst.Probability != teams[j].ScoresFirst.Probability {
+			return teams[i].ScoresFirst.Probability > teams[j].ScoresFirst.Probability
+		}
+		return teams[i].Team < teams[j].Team
+	})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"teams":    teams,
+		"league":   league.result(""),
+		"metadata": propsMetadata,
+	})
+}
+
+// MatchupPropsHandler estimates a game's props from both teams' rates and
+// prices them as Odds API markets, next to the teams' head-to-head games.
+//
+// Query params: home and away (team codes, required) and the shared shot
+// filters.
+func MatchupPropsHandler(c echo.Context) error {
+	home := moneyPuckTeamCode(c.QueryParam("home"))
+	away := moneyPuckTeamCode(c.QueryParam("away"))
+	if home == "" || away == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "home and away are required"})
+	}
+	if home == away {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "home and away must be different teams"})
+	}
+
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, _, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	games := propGames(shots)
+	totals, league := teamPropTotals(games)
+	for _, team := range []string{home, away} {
+		if _, ok := totals[team]; !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "No games found for team " + team})
+		}
+	}
+	homeProps, awayProps := totals[home].result(home), totals[away].result(away)
+
+	// Head-to-head from the home team's side, at either venue
+	h2h := &propTotals{}
+	for _, game := range games {
+		switch {
+		case game.home == home && game.away == away:
+			h2h.add(game, true)
+		case game.home == away && game.away == home:
+			h2h.add(game, false)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"home":       homeProps,
+		"away":       awayProps,
+		"headToHead": h2h.result(home),
+		"league":     league.result(""),
+		"markets":    matchupMarkets(homeProps, awayProps),
+		"metadata":   propsMetadata,
+	})
+}
@@ -0,0 +1,321 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/KPWithCode/statpad2/ratings"
+	"github.com/labstack/echo/v4"
+)
+
+// TeamRating is a team's Elo next to its xG strength rating
+type TeamRating struct {
+	Team         string  `json:"team"`
+	Elo          float64 `json:"elo"`
+	EloRank      int     `json:"eloRank"`
+	GamesPlayed  int     `json:"gamesPlayed"` // Games behind the xG rating
+	Offense      float64 `json:"offense"`     // xG per game created above average
+	Defense      float64 `json:"defense"`     // xG per game prevented above average
+	Net          float64 `json:"net"`
+	StrengthRank int     `json:"strengthRank"` // By net
+}
+
+// teamRatings are both rating systems fit to the shot data
+type teamRatings struct {
+	elo        *ratings.Elo
+	eloFit     ratings.EloFit
+	strength   *ratings.StrengthModel
+	goalsPerXG float64
+	// restAvailable is false when the shot data has no game dates, so
+	// rest can't be worked out or fit
+	restAvailable bool
+}
+
+// ratingGames rebuilds the games in shots in the order they were played
+func ratingGames(shots []ShotData) []ratings.Game {
+	summaries := aggregateGameStats(shots)
+	order := newGameOrder()
+	for _, shot := range shots {
+		order.add(shot)
+	}
+	keys := make([]string, 0, len(summaries))
+	for key, game := range summaries {
+		if game.HomeTeam != "" && game.AwayTeam != "" {
+			keys = append(keys, key)
+		}
+	}
+	order.sort(keys)
+
+	games := make([]ratings.Game, len(keys))
+	for i, key := range keys {
+		summary := summaries[key]
+		var date time.Time
+		if summary.Date != "" {
+			date, _ = time.Parse("2006-01-02", summary.Date)
+		}
+		games[i] = ratings.Game{
+			Season:    summary.Season,
+			Date:      date,
+			Home:      summary.HomeTeam,
+			Away:      summary.AwayTeam,
+			HomeGoals: summary.Home.Goals,
+			AwayGoals: summary.Away.Goals,
+			HomeWon:   summary.HomeWon,
+			HomeXG:    summary.Home.XGoals,
+			AwayXG:    summary.Away.XGoals,
+		}
+	}
+	return games
+}
+
+// loadTeamRatings fits both rating systems for a request. Elo runs through
+// every season in the data, up to the to date when one is given, since it
+// carries ratings from one season to the next; the xG ratings are fit to
+// the filtered games only.
+func loadTeamRatings(c echo.Context, allShots []ShotData) (*teamRatings, ShotFilter, int, map[string]string) {
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return nil, filter, http.StatusBadRequest, map[string]string{"error": err.Error()}
+	}
+
+	lambda := ratings.DefaultRidgeLambda
+	if value := c.QueryParam("lambda"); value != "" {
+		lambda, err = strconv.ParseFloat(value, 64)
+		// Without a penalty the intercept, offense and defense columns are
+		// collinear and there's no unique fit
+		if err != nil || lambda <= 0 {
+			return nil, filter, http.StatusBadRequest, map[string]string{"error": "lambda must be a positive number"}
+		}
+	}
+
+	eloShots, err := ShotFilter{To: filter.To, GameType: filter.GameType}.Apply(allShots)
+	if err != nil {
+		return nil, filter, http.StatusBadRequest, map[string]string{"error": err.Error()}
+	}
+	eloGames := ratingGames(eloShots)
+	if len(eloGames) == 0 {
+		return nil, filter, http.StatusNotFound, map[string]string{"error": "No games to rate"}
+	}
+	fit := ratings.Tune(eloGames)
+	elo, _ := ratings.Evaluate(fit.Config, eloGames, nil)
+
+	games := ratingGames(shots)
+	strength, err := ratings.FitStrength(games, lambda)
+	if err != nil {
+		return nil, filter, http.StatusUnprocessableEntity, map[string]string{"error": err.Error()}
+	}
+
+	goals, xGoals := 0, 0.0
+	for _, game := range games {
+		goals += game.HomeGoals + game.AwayGoals
+		xGoals += game.HomeXG + game.AwayXG
+	}
+
+	return &teamRatings{
+		elo:           elo,
+		eloFit:        fit,
+		strength:      strength,
+		goalsPerXG:    safeDiv(float64(goals), xGoals),
+		restAvailable: ratings.HasDates(eloGames),
+	}, filter, http.StatusOK, nil
+}
+
+func (r *teamRatings) metadata() map[string]interface{} {
+	rest := "Days since each team's previous game, from the game dates; homeRest and awayRest override it"
+	if !r.restAvailable {
+		rest = "Unavailable: the shot data has no game dates, so Elo ignores rest and homeRest and awayRest aren't accepted"
+	}
+	return map[string]interface{}{
+		"rest": rest,
+		"elo": map[string]interface{}{
+			"config":      r.eloFit.Config,
+			"tunedOn":     r.eloFit.Games,
+			"logLoss":     round3(r.eloFit.LogLoss),
+			"brier":       round3(r.eloFit.Brier),
+			"description": "Tuned by grid search on every season after the first (or the second half of a single season). K is scaled by margin of victory; ratings regress toward 1500 between seasons.",
+		},
+		"strength": map[string]interface{}{
+			"games":       r.strength.Games,
+			"lambda":      r.strength.Lambda,
+			"intercept":   round3(r.strength.Intercept),
+			"homeIce":     round3(r.strength.HomeIce),
+			"description": "Ridge regression of each side's xG on its offense and the opponent's defense, plus home ice",
+		},
+		"goalsPerXG": round3(r.goalsPerXG),
+	}
+}
+
+// NHLRatingsHandler ranks teams by Elo and by xG strength.
+//
+// Query params: the shared shot filters (season picks the games behind
+// the xG ratings; Elo always runs through every season up to to) and
+// lambda (ridge penalty, default 10).
+func NHLRatingsHandler(c echo.Context) error {
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+	r, filter, status, errBody := loadTeamRatings(c, allShots)
+	if errBody != nil {
+		return c.JSON(status, errBody)
+	}
+
+	teams := make([]*TeamRating, 0, len(r.strength.Teams))
+	for team, strength := range r.strength.Teams {
+		teams = append(teams, &TeamRating{
+			Team:        team,
+			Elo:         round1(r.elo.Rating(team)),
+			GamesPlayed: strength.Games,
+			Offense:     round3(strength.Offense),
+			Defense:     round3(strength.Defense),
+			Net:         round3(strength.Net),
+		})
+	}
+
+	rankBy(teams, false, func(t *TeamRating) float64 { return t.Net }, func(t *TeamRating, r int) { t.StrengthRank = r })
+	rankBy(teams, false, func(t *TeamRating) float64 { return t.Elo }, func(t *TeamRating, r int) { t.EloRank = r })
+	sort.Slice(teams, func(i, j int) bool {
+		if teams[i].EloRank != teams[j].EloRank {
+			return teams[i].EloRank < teams[j].EloRank
+		}
+		return teams[i].Team < teams[j].Team
+	})
+
+	filtered := make([]*TeamRating, 0, len(teams))
+	for _, team := range teams {
+		if filter.includesTeam(team.Team) {
+			filtered = append(filtered, team)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"teams":    filtered,
+		"metadata": r.metadata(),
+	})
+}
+
+// poissonPMF returns P(X = k) for a Poisson with mean lambda
+func poissonPMF(k int, lambda float64) float64 {
+	logFactorial, _ := math.Lgamma(float64(k + 1))
+	return math.Exp(float64(k)*math.Log(lambda) - lambda - logFactorial)
+}
+
+// poissonWinProbability returns the home team's chance of outscoring the
+// away team when each side's goals are Poisson. Ties go to overtime, which
+// is treated as a coin flip.
+func poissonWinProbability(homeGoals, awayGoals float64) float64 {
+	const maxGoals = 15
+	if homeGoals <= 0 || awayGoals <= 0 {
+		return 0.5
+	}
+	win, tie := 0.0, 0.0
+	for h := 0; h <= maxGoals; h++ {
+		ph := poissonPMF(h, homeGoals)
+		for a := 0; a <= maxGoals; a++ {
+			p := ph * poissonPMF(a, awayGoals)
+			switch {
+			case h > a:
+				win += p
+			case h == a:
+				tie += p
+			}
+		}
+	}
+	return win + tie/2
+}
+
+// parseRest reads a rest query parameter: days since the team last
+// played, or -1 to work it out from the data
+func parseRest(value, name string) (int, error) {
+	if value == "" {
+		return -1, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative number of days", name)
+	}
+	return days, nil
+}
+
+// NHLPredictHandler predicts a game from both rating systems: Elo's win
+// probability, the xG ratings' projected score, and a blend of the two.
+//
+// Query params: home and away (team codes, required), date (YYYY-MM-DD,
+// used for rest, default today), homeRest and awayRest (days since each
+// team last played, overriding the data; only when the data has game
+// dates), lambda and the shared shot filters.
+func NHLPredictHandler(c echo.Context) error {
+	home := moneyPuckTeamCode(c.QueryParam("home"))
+	away := moneyPuckTeamCode(c.QueryParam("away"))
+	if home == "" || away == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "home and away are required"})
+	}
+	if home == away {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "home and away must be different teams"})
+	}
+
+	date := time.Now().UTC().Truncate(24 * time.Hour)
+	if value := c.QueryParam("date"); value != "" {
+		t, err := parseFilterDate(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid date %q, expected YYYY-MM-DD", value)})
+		}
+		date = t
+	}
+	homeRest, err := parseRest(c.QueryParam("homeRest"), "homeRest")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	awayRest, err := parseRest(c.QueryParam("awayRest"), "awayRest")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+	r, _, status, errBody := loadTeamRatings(c, allShots)
+	if errBody != nil {
+		return c.JSON(status, errBody)
+	}
+	if !r.restAvailable && (homeRest >= 0 || awayRest >= 0) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "homeRest and awayRest need game dates, which this shot data doesn't have",
+		})
+	}
+	for _, team := range []string{home, away} {
+		if _, ok := r.strength.Teams[team]; !ok {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "No games found for team " + team})
+		}
+	}
+
+	eloProb := r.elo.Predict(home, away, date, homeRest, awayRest)
+	homeXG, awayXG := r.strength.Project(home, away)
+	homeGoals, awayGoals := homeXG*r.goalsPerXG, awayXG*r.goalsPerXG
+	xgProb := poissonWinProbability(homeGoals, awayGoals)
+	winProb := (eloProb + xgProb) / 2
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"home":                  home,
+		"away":                  away,
+		"date":                  date.Format("2006-01-02"),
+		"homeWinProbability":    round3(winProb),
+		"awayWinProbability":    round3(1 - winProb),
+		"eloHomeWinProbability": round3(eloProb),
+		"xgHomeWinProbability":  round3(xgProb),
+		"homeElo":               round1(r.elo.Rating(home)),
+		"awayElo":               round1(r.elo.Rating(away)),
+		"eloEdge":               round1(r.elo.Diff(home, away, date, homeRest, awayRest)),
+		"projectedHomeXG":       round2(homeXG),
+		"projectedAwayXG":       round2(awayXG),
+		"projectedHomeGoals":    round2(homeGoals),
+		"projectedAwayGoals":    round2(awayGoals),
+		"projectedTotal":        round2(homeGoals + awayGoals),
+		"metadata":              r.metadata(),
+	})
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/KPWithCode/statpad2/searchindex"
+	"github.com/labstack/echo/v4"
+)
+
+// searchIndexNames are the indexes the TrendLens pipelines write to, the
+// only ones worth querying. Opening any other name would create, and keep,
+// an empty local index.
+func searchIndexNames() []string {
+	return []string{
+		searchindex.IndexName(os.Getenv("ALGOLIA_INDEX_NAME"), "nba-trendlens"),
+		searchindex.IndexName(os.Getenv("ALGOLIA_NHL_INDEX_NAME"), "nhl-trendlens"),
+	}
+}
+
+// QuerySearchIndex searches a local (json or memory) search index, so
+// TrendLens output can be checked without going through Algolia.
+//
+// Query params: q (text), filter=field:value (repeatable), sort, desc=true
+// and limit (default 50).
+func QuerySearchIndex(c echo.Context) error {
+	if searchindex.Backend() == searchindex.BackendAlgolia {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Queries are only supported for the json and memory search index backends",
+		})
+	}
+
+	name := c.Param("name")
+	known := false
+	for _, index := range searchIndexNames() {
+		known = known || index == name
+	}
+	if !known {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Unknown search index " + name})
+	}
+
+	indexer, err := searchindex.Open(name)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	querier, ok := indexer.(searchindex.Querier)
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Index does not support queries"})
+	}
+
+	query := searchindex.Query{
+		Text:    c.QueryParam("q"),
+		Filters: make(map[string]string),
+		SortBy:  c.QueryParam("sort"),
+		Desc:    c.QueryParam("desc") == "true",
+		Limit:   50,
+	}
+	for _, filter := range c.QueryParams()["filter"] {
+		field, value, found := strings.Cut(filter, ":")
+		if !found || field == "" {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid filter, expected field:value"})
+		}
+		query.Filters[field] = value
+	}
+	if limit := c.QueryParam("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid limit"})
+		}
+		query.Limit = n
+	}
+
+	hits := querier.Query(query)
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"index":  indexer.Name(),
+		"nbHits": len(hits),
+		"hits":   hits,
+	})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/KPWithCode/statpad2/seasons"
+	"github.com/labstack/echo/v4"
+)
+
+// LeagueSeasons is a league's current season and every season available
+type LeagueSeasons struct {
+	League  string           `json:"league"`
+	Current seasons.Season   `json:"current"`
+	Seasons []seasons.Season `json:"seasons"`
+}
+
+// GetSeasons lists the seasons each league's season parameter accepts.
+// ?league=nba limits the response to one league.
+func GetSeasons(c echo.Context) error {
+	leagues := seasons.Leagues()
+	if league := strings.ToLower(c.QueryParam("league")); league != "" {
+		leagues = []string{league}
+	}
+
+	results := make([]LeagueSeasons, 0, len(leagues))
+	for _, league := range leagues {
+		current, err := seasons.Current(league)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		list, err := seasons.List(league)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		results = append(results, LeagueSeasons{
+			League:  league,
+			Current: current,
+			Seasons: list,
+		})
+	}
+
+	return c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Shot danger tiers, lowest first
+const (
+	dangerLow    = "low"
+	dangerMedium = "medium"
+	dangerHigh   = "high"
+)
+
+var dangerTiers = []string{dangerLow, dangerMedium, dangerHigh}
+
+func dangerTierRank(tier string) int {
+	for i, t := range dangerTiers {
+		if t == tier {
+			return i
+		}
+	}
+	return -1
+}
+
+// goalLineX is where the net sits on the adjusted coordinates, which put
+// every shot in the offensive zone on the positive x side
+const goalLineX = 89.0
+
+// DangerRect is an axis-aligned region in rink feet
+type DangerRect struct {
+	MinX float64 `json:"minX"`
+	MaxX float64 `json:"maxX"`
+	MinY float64 `json:"minY"`
+	MaxY float64 `json:"maxY"`
+}
+
+// DangerRegion assigns a tier to a rectangle or polygon on the adjusted
+// rink coordinates (net at x=89, y=0). Polygon points are [x, y] pairs.
+type DangerRegion struct {
+	Name    string       `json:"name"`
+	Tier    string       `json:"tier"`
+	Rect    *DangerRect  `json:"rect,omitempty"`
+	Polygon [][2]float64 `json:"polygon,omitempty"`
+}
+
+func (r DangerRegion) contains(x, y float64) bool {
+	if r.Rect != nil {
+		return x >= r.Rect.MinX && x <= r.Rect.MaxX && y >= r.Rect.MinY && y <= r.Rect.MaxY
+	}
+
+	// Ray casting: count edges crossed by a ray heading in +x
+	inside := false
+	for i, j := 0, len(r.Polygon)-1; i < len(r.Polygon); j, i = i, i+1 {
+		xi, yi := r.Polygon[i][0], r.Polygon[i][1]
+		xj, yj := r.Polygon[j][0], r.Polygon[j][1]
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// DangerZoneModel classifies shot locations. A shot takes the highest
+// tier of the regions containing it and is low danger outside all of them.
+type DangerZoneModel struct {
+	Regions []DangerRegion `json:"regions"`
+}
+
+// defaultDangerZones are the inner slot and crease as high danger, and the
+// rest of the "home plate" out to the top of the circles as medium
+var defaultDangerZones = DangerZoneModel{
+	Regions: []DangerRegion{
+		{
+			Name: "inner slot",
+			Tier: dangerHigh,
+			Rect: &DangerRect{MinX: 69, MaxX: goalLineX, MinY: -9, MaxY: 9},
+		},
+		{
+			Name:    "home plate",
+			Tier:    dangerMedium,
+			Polygon: [][2]float64{{goalLineX, -9}, {69, -22}, {54, -22}, {54, 22}, {69, 22}, {goalLineX, 9}},
+		},
+	},
+}
+
+// Classify returns the danger tier at a location
+func (m *DangerZoneModel) Classify(x, y float64) string {
+	tier := dangerLow
+	for _, region := range m.Regions {
+		if dangerTierRank(region.Tier) > dangerTierRank(tier) && region.contains(x, y) {
+			tier = region.Tier
+		}
+	}
+	return tier
+}
+
+func (m *DangerZoneModel) validate() error {
+	if len(m.Regions) == 0 {
+		return fmt.Errorf("no regions defined")
+	}
+	for i, region := range m.Regions {
+		if dangerTierRank(region.Tier) < 0 {
+			return fmt.Errorf("region %d (%s): tier must be low, medium or high", i, region.Name)
+		}
+		switch {
+		case region.Rect != nil && len(region.Polygon) > 0:
+			return fmt.Errorf("region %d (%s): set rect or polygon, not both", i, region.Name)
+		case region.Rect != nil:
+			if region.Rect.MinX > region.Rect.MaxX || region.Rect.MinY > region.Rect.MaxY {
+				return fmt.Errorf("region %d (%s): rect min is past max", i, region.Name)
+			}
+		case len(region.Polygon) < 3:
+			return fmt.Errorf("region %d (%s): needs a rect or a polygon of at least 3 points", i, region.Name)
+		}
+	}
+	return nil
+}
+
+var (
+	dangerZonesOnce sync.Once
+	dangerZones     *DangerZoneModel
+)
+
+// dangerZoneModel returns the configured regions: the JSON file at
+// DANGER_ZONES_PATH if set, otherwise defaultDangerZones
+func dangerZoneModel() *DangerZoneModel {
+	dangerZonesOnce.Do(func() {
+		dangerZones = &defaultDangerZones
+		path := os.Getenv("DANGER_ZONES_PATH")
+		if path == "" {
+			return
+		}
+
+		model, err := loadDangerZones(path)
+		if err != nil {
+			log.Printf("Using default danger zones: %v", err)
+			return
+		}
+		dangerZones = model
+		log.Printf("Loaded %d danger zone regions from %s", len(model.Regions), path)
+	})
+	return dangerZones
+}
+
+func loadDangerZones(path string) (*DangerZoneModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	var model DangerZoneModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if err := model.validate(); err != nil {
+		return nil, fmt.Errorf("invalid danger zones in %s: %v", path, err)
+	}
+	return &model, nil
+}
+
+// shotLocation returns a shot's position on the adjusted coordinates. Rows
+// without coordinates are placed from their distance and angle.
+func shotLocation(shot ShotData) (float64, float64) {
+	if shot.hasCoordinates {
+		return shot.XCordAdjusted, shot.YCordAdjusted
+	}
+	angle := shot.ShotAngle * math.Pi / 180
+	return goalLineX - shot.ShotDistance*math.Cos(angle), shot.ShotDistance * math.Sin(angle)
+}
+
+// shotDangerTier classifies a shot with the configured danger zones
+func shotDangerTier(shot ShotData) string {
+	x, y := shotLocation(shot)
+	return dangerZoneModel().Classify(x, y)
+}
+
+// GetDangerZonesHandler returns the regions used to classify shots
+func GetDangerZonesHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, dangerZoneModel())
+}
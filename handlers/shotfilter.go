@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Game types accepted by the gameType query parameter
+const (
+	gameTypeRegular  = "regular"
+	gameTypePlayoffs = "playoffs"
+)
+
+// ShotFilter narrows shot rows to a season, date range and game type. Team
+// doesn't drop any rows; handlers use it to trim their response so ranks and
+// league context still come from every team. Zero values mean "don't filter
+// on this".
+type ShotFilter struct {
+	Season   string    `json:"season,omitempty"`
+	From     time.Time `json:"from,omitempty"`
+	To       time.Time `json:"to,omitempty"`
+	GameType string    `json:"gameType,omitempty"`
+	Team     string    `json:"team,omitempty"`
+}
+
+// parseShotFilter reads the season, from, to, gameType and team query
+// parameters. When season is omitted the latest season in shots is used;
+// season=all disables the season filter.
+func parseShotFilter(c echo.Context, shots []ShotData) (ShotFilter, error) {
+	var filter ShotFilter
+
+	season := strings.TrimSpace(c.QueryParam("season"))
+	switch strings.ToLower(season) {
+	case "":
+		filter.Season = latestSeason(shots)
+	case "all":
+		// No season filter
+	default:
+		normalized, err := normalizeNHLSeason(season)
+		if err != nil {
+			return filter, err
+		}
+		filter.Season = normalized
+	}
+
+	if from := c.QueryParam("from"); from != "" {
+		t, err := parseFilterDate(from)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", from)
+		}
+		filter.From = t
+	}
+	if to := c.QueryParam("to"); to != "" {
+		t, err := parseFilterDate(to)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", to)
+		}
+		// Include every game played on the end date
+		filter.To = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return filter, fmt.Errorf("from date must be before to date")
+	}
+
+	switch gameType := strings.ToLower(c.QueryParam("gameType")); gameType {
+	case "", "all":
+		// No game type filter
+	case "regular", "regularseason":
+		filter.GameType = gameTypeRegular
+	case "playoff", "playoffs", "postseason":
+		filter.GameType = gameTypePlayoffs
+	default:
+		return filter, fmt.Errorf("invalid gameType %q, expected regular or playoffs", gameType)
+	}
+
+	filter.Team = moneyPuckTeamCode(c.QueryParam("team"))
+
+	return filter, nil
+}
+
+// filterShotsFromQuery parses the filter query parameters and applies them
+func filterShotsFromQuery(c echo.Context, shots []ShotData) ([]ShotData, ShotFilter, error) {
+	filter, err := parseShotFilter(c, shots)
+	if err != nil {
+		return nil, filter, err
+	}
+
+	filtered, err := filter.Apply(shots)
+	if err != nil {
+		return nil, filter, err
+	}
+	return filtered, filter, nil
+}
+
+// Apply returns the shots that match the filter. A date range can only be
+// applied when the data carries game dates.
+func (f ShotFilter) Apply(shots []ShotData) ([]ShotData, error) {
+	if f.hasDateRange() && !hasShotDates(shots) {
+		return nil, fmt.Errorf("this dataset has no game dates, so from/to can't be applied")
+	}
+
+	filtered := make([]ShotData, 0, len(shots))
+	for _, shot := range shots {
+		if f.Match(shot) {
+			filtered = append(filtered, shot)
+		}
+	}
+	return filtered, nil
+}
+
+// Match reports whether a single shot passes the filter
+func (f ShotFilter) Match(shot ShotData) bool {
+	if f.Season != "" && shot.Season != f.Season {
+		return false
+	}
+
+	switch f.GameType {
+	case gameTypeRegular:
+		if shot.IsPlayoffGame {
+			return false
+		}
+	case gameTypePlayoffs:
+		if !shot.IsPlayoffGame {
+			return false
+		}
+	}
+
+	if f.hasDateRange() {
+		if shot.Date.IsZero() {
+			return false
+		}
+		if !f.From.IsZero() && shot.Date.Before(f.From) {
+			return false
+		}
+		if !f.To.IsZero() && shot.Date.After(f.To) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// includesTeam reports whether results for team should be returned
+func (f ShotFilter) includesTeam(team string) bool {
+	return f.Team == "" || f.Team == team
+}
+
+func (f ShotFilter) hasDateRange() bool {
+	return !f.From.IsZero() || !f.To.IsZero()
+}
+
+// latestSeason returns the most recent season present in the data
+func latestSeason(shots []ShotData) string {
+	latest := ""
+	for _, shot := range shots {
+		if shot.Season > latest {
+			latest = shot.Season
+		}
+	}
+	return latest
+}
+
+func hasShotDates(shots []ShotData) bool {
+	for _, shot := range shots {
+		if !shot.Date.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeNHLSeason converts "2024", "2024-2025" and "20242025" to the
+// starting year MoneyPuck uses in its season column
+func normalizeNHLSeason(season string) (string, error) {
+	s := strings.ReplaceAll(season, "-", "")
+	if len(s) == 8 {
+		s = s[:4]
+	}
+	if len(s) != 4 || strings.Trim(s, "0123456789") != "" {
+		return "", fmt.Errorf("invalid season %q, expected a year like 2024 or all", season)
+	}
+	return s, nil
+}
+
+func parseFilterDate(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
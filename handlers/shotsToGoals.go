@@ -1,9 +1,7 @@
 package handlers
 
 import (
-	"encoding/csv"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/labstack/echo/v4"
@@ -17,46 +15,28 @@ type ShotsToGoalStats struct {
 	ConversionRate float64 `json:"conversion_rate"`
 }
 
+// ProcessShotsToGoalHandler returns each team's shot-to-goal conversion rate
+// for the shots matching the season, from, to, gameType and team filters
 func ProcessShotsToGoalHandler(c echo.Context) error {
-	file, err := os.Open("data/march3.csv")
+	allShots, err := datasetShots(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to open CSV file"})
+		return shotLoadError(c, err)
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	shots, filter, err := filterShotsFromQuery(c, allShots)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to parse CSV"})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	if len(records) < 2 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV file is empty or invalid"})
-	}
-
-	columns := records[0]
-	eventIdx := findColumnIndex(columns, "event")
-	teamIdx := findColumnIndex(columns, "teamCode")
-	seasonIdx := findColumnIndex(columns, "season")
-
-	if eventIdx == -1 || teamIdx == -1 || seasonIdx == -1 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV does not contain required columns"})
-	}
-
-	// Define the current season
-	currentSeason := "2024"
-
 	stats := make(map[string]*ShotsToGoalStats)
 
-	for _, record := range records[1:] {
-		season := record[seasonIdx]
-		if season != currentSeason {
-			continue // Skip records that are not from the current season
+	for _, shot := range shots {
+		event := strings.ToLower(shot.Event)
+		team := shot.TeamCode
+		if !filter.includesTeam(team) {
+			continue
 		}
 
-		event := strings.ToLower(record[eventIdx])
-		team := record[teamIdx]
-
 		if _, ok := stats[team]; !ok {
 			stats[team] = &ShotsToGoalStats{Team: team}
 		}
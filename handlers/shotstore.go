@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// defaultShotDataPath is the MoneyPuck shot export the NHL handlers read from
+const defaultShotDataPath = "data/march3.csv"
+
+// ShotStore keeps a parsed copy of a shot CSV in memory. The file is parsed
+// once and only re-read when its size or modification time changes.
+type ShotStore struct {
+	path string
+
+	mu      sync.RWMutex
+	shots   []ShotData
+	modTime time.Time
+	size    int64
+	loaded  bool
+
+	// reloadMu serializes reloads so concurrent requests don't parse the
+	// same file twice
+	reloadMu sync.Mutex
+}
+
+// NewShotStore creates a store for the CSV at path. Nothing is read until
+// Load or Shots is called.
+func NewShotStore(path string) *ShotStore {
+	return &ShotStore{path: path}
+}
+
+// Path returns the file backing the store
+func (s *ShotStore) Path() string {
+	return s.path
+}
+
+// Load parses the file, replacing whatever the store currently holds
+func (s *ShotStore) Load() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", s.path, err)
+	}
+	_, err = s.reload(info, true)
+	return err
+}
+
+// Shots returns every parsed row in the file. The returned slice is shared
+// between callers and must not be modified.
+func (s *ShotStore) Shots() ([]ShotData, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", s.path, err)
+	}
+
+	s.mu.RLock()
+	fresh := s.isCurrent(info)
+	shots := s.shots
+	s.mu.RUnlock()
+
+	if fresh {
+		return shots, nil
+	}
+	return s.reload(info, false)
+}
+
+// isCurrent reports whether the loaded rows match the file described by
+// info. Callers must hold mu.
+func (s *ShotStore) isCurrent(info os.FileInfo) bool {
+	return s.loaded && info.ModTime().Equal(s.modTime) && info.Size() == s.size
+}
+
+func (s *ShotStore) reload(info os.FileInfo, force bool) ([]ShotData, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	// Another request may have finished the reload while we were waiting
+	if !force {
+		s.mu.RLock()
+		if s.isCurrent(info) {
+			shots := s.shots
+			s.mu.RUnlock()
+			return shots, nil
+		}
+		s.mu.RUnlock()
+	}
+
+	start := time.Now()
+	shots, err := readShotFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.shots = shots
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	s.loaded = true
+	s.mu.Unlock()
+
+	log.Printf("Loaded %d shots from %s in %v", len(shots), s.path, time.Since(start).Round(time.Millisecond))
+	return shots, nil
+}
+
+// readShotFile parses a MoneyPuck shot CSV into ShotData rows
+func readShotFile(path string) ([]ShotData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%s is empty or invalid", path)
+	}
+
+	shots, err := processCSVToShotData(records, records[0])
+	if err != nil {
+		return nil, err
+	}
+	if findColumnIndex(records[0], "xGoal") < 0 {
+		fillXGoals(shots)
+	}
+	return shots, nil
+}
+
+var (
+	shotStoresMu sync.Mutex
+	shotStores   = make(map[string]*ShotStore)
+)
+
+// shotStoreFor returns the shared store for path, creating it on first use
+func shotStoreFor(path string) *ShotStore {
+	shotStoresMu.Lock()
+	defer shotStoresMu.Unlock()
+
+	store, ok := shotStores[path]
+	if !ok {
+		store = NewShotStore(path)
+		shotStores[path] = store
+	}
+	return store
+}
+
+// forgetShotStore drops the shared store for path so its rows can be
+// garbage collected once the file is gone
+func forgetShotStore(path string) {
+	shotStoresMu.Lock()
+	defer shotStoresMu.Unlock()
+	delete(shotStores, path)
+}
+
+// LoadShotData parses the default shot file so the first request doesn't
+// pay for it. A missing file is logged rather than treated as fatal since
+// the NBA routes don't need it.
+func LoadShotData() {
+	if err := shotStoreFor(defaultShotDataPath).Load(); err != nil {
+		log.Printf("Shot data not loaded: %v", err)
+	}
+}
+
+// loadShots returns the rows for the shot file at path, or the default file
+// when path is empty
+func loadShots(path string) ([]ShotData, error) {
+	if path == "" {
+		path = defaultShotDataPath
+	}
+
+	shots, err := shotStoreFor(path).Shots()
+	if err != nil {
+		log.Printf("Error loading shot data: %v", err)
+		return nil, err
+	}
+	return shots, nil
+}
@@ -0,0 +1,545 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultRecentGames is the window for special teams recent form
+const defaultRecentGames = 10
+
+// Special teams states from the power play side, and the matching penalty
+// kill states
+var (
+	powerPlayStates   = []string{"5v4", "5v3", "4v3"}
+	penaltyKillStates = []string{"4v5", "3v5", "3v4"}
+)
+
+// SpecialTeamsSplit is a unit's play in one strength state, or all of them.
+// Time on ice is estimated from the gaps between events, so it's only as
+// good as the event density.
+type SpecialTeamsSplit struct {
+	TimeOnIce float64 `json:"timeOnIce"` // Minutes
+	GF        int     `json:"gf"`
+	GA        int     `json:"ga"`
+	XGF       float64 `json:"xgf"`
+	XGA       float64 `json:"xga"`
+	CF        int     `json:"cf"`
+	CA        int     `json:"ca"`
+	XGFPer60  float64 `json:"xgfPer60"`
+	XGAPer60  float64 `json:"xgaPer60"`
+	CFPer60   float64 `json:"cfPer60"`
+	CAPer60   float64 `json:"caPer60"`
+}
+
+// SpecialTeamsUnit is a team's power play or penalty kill
+type SpecialTeamsUnit struct {
+	Opportunities int `json:"opportunities"`
+	// Converted counts opportunities with a power play goal: scored on the
+	// power play, allowed on the penalty kill
+	Converted int `json:"converted"`
+	// Pct is PP% on the power play and PK% on the penalty kill
+	Pct                 float64 `json:"pct"`
+	GoalsPerOpportunity float64 `json:"goalsPerOpportunity"`
+	// XGPerAttempt is shot quality: xG per unblocked attempt taken on the
+	// power play, or allowed on the penalty kill
+	XGPerAttempt float64 `json:"xgPerAttempt"`
+	Rank         int     `json:"rank"`
+
+	SpecialTeamsSplit
+	Splits map[string]*SpecialTeamsSplit `json:"splits"`
+}
+
+// SpecialTeamsForm compares a team's last games with its full sample
+type SpecialTeamsForm struct {
+	Games              int     `json:"games"`
+	PPXGFPer60         float64 `json:"ppXgfPer60"`
+	PKXGAPer60         float64 `json:"pkXgaPer60"`
+	PPOppsPerGame      float64 `json:"ppOpportunitiesPerGame"`
+	PKOppsPerGame      float64 `json:"pkOpportunitiesPerGame"`
+	PPXGFPer60Trend    float64 `json:"ppXgfPer60Trend"` // Recent minus full sample
+	PKXGAPer60Trend    float64 `json:"pkXgaPer60Trend"`
+	PPOppsPerGameTrend float64 `json:"ppOpportunitiesPerGameTrend"`
+}
+
+// TeamSpecialTeams is a team's power play and penalty kill
+type TeamSpecialTeams struct {
+	Team        string            `json:"team"`
+	GamesPlayed int               `json:"gamesPlayed"`
+	PowerPlay   SpecialTeamsUnit  `json:"powerPlay"`
+	PenaltyKill SpecialTeamsUnit  `json:"penaltyKill"`
+	Recent      SpecialTeamsForm  `json:"recent"`
+	Games       []SpecialTeamGame `json:"games,omitempty"`
+}
+
+// SpecialTeamGame is one game of a team's special teams
+type SpecialTeamGame struct {
+	GameID          string  `json:"gameId"`
+	Season          string  `json:"season"`
+	Date            string  `json:"date,omitempty"`
+	Opponent        string  `json:"opponent"`
+	PPOpportunities int     `json:"ppOpportunities"`
+	PPGoals         int     `json:"ppGoals"`
+	PPXGF           float64 `json:"ppXgf"`
+	PPTimeOnIce     float64 `json:"ppTimeOnIce"`
+	PKOpportunities int     `json:"pkOpportunities"`
+	PKGoalsAgainst  int     `json:"pkGoalsAgainst"`
+	PKXGA           float64 `json:"pkXga"`
+	PKTimeOnIce     float64 `json:"pkTimeOnIce"`
+}
+
+// stSplit accumulates a SpecialTeamsSplit; time is in seconds
+type stSplit struct {
+	seconds        float64
+	gf, ga, cf, ca int
+	ff, fa         int
+	xgf, xga       float64
+}
+
+func (s *stSplit) merge(o *stSplit) {
+	s.seconds += o.seconds
+	s.gf += o.gf
+	s.ga += o.ga
+	s.cf += o.cf
+	s.ca += o.ca
+	s.ff += o.ff
+	s.fa += o.fa
+	s.xgf += o.xgf
+	s.xga += o.xga
+}
+
+func (s *stSplit) result() *SpecialTeamsSplit {
+	per60 := func(value float64) float64 {
+		return round2(safeDiv(value, s.seconds) * 3600)
+	}
+	return &SpecialTeamsSplit{
+		TimeOnIce: round1(s.seconds / 60),
+		GF:        s.gf,
+		GA:        s.ga,
+		XGF:       round2(s.xgf),
+		XGA:       round2(s.xga),
+		CF:        s.cf,
+		CA:        s.ca,
+		XGFPer60:  per60(s.xgf),
+		XGAPer60:  per60(s.xga),
+		CFPer60:   per60(float64(s.cf)),
+		CAPer60:   per60(float64(s.ca)),
+	}
+}
+
+// stUnit accumulates a power play or penalty kill
+type stUnit struct {
+	opportunities, converted int
+	total                    stSplit
+	states                   map[string]*stSplit
+}
+
+func newSTUnit() *stUnit {
+	return &stUnit{states: make(map[string]*stSplit)}
+}
+
+func (u *stUnit) state(key string) *stSplit {
+	s, ok := u.states[key]
+	if !ok {
+		s = &stSplit{}
+		u.states[key] = s
+	}
+	return s
+}
+
+func (u *stUnit) merge(o *stUnit) {
+	u.opportunities += o.opportunities
+	u.converted += o.converted
+	u.total.merge(&o.total)
+	for key, s := range o.states {
+		u.state(key).merge(s)
+	}
+}
+
+// result builds the unit; powerPlay picks which side the rates are from
+func (u *stUnit) result(states []string, powerPlay bool) SpecialTeamsUnit {
+	unit := SpecialTeamsUnit{
+		Opportunities:     u.opportunities,
+		Converted:         u.converted,
+		SpecialTeamsSplit: *u.total.result(),
+		Splits:            make(map[string]*SpecialTeamsSplit, len(states)),
+	}
+	for _, key := range states {
+		unit.Splits[key] = u.state(key).result()
+	}
+
+	conversion := safeDiv(float64(u.converted), float64(u.opportunities))
+	if powerPlay {
+		unit.Pct = round1(conversion * 100)
+		unit.GoalsPerOpportunity = round3(safeDiv(float64(u.total.gf), float64(u.opportunities)))
+		unit.XGPerAttempt = round3(safeDiv(u.total.xgf, float64(u.total.ff)))
+	} else {
+		if u.opportunities > 0 {
+			unit.Pct = round1((1 - conversion) * 100)
+		}
+		unit.GoalsPerOpportunity = round3(safeDiv(float64(u.total.ga), float64(u.opportunities)))
+		unit.XGPerAttempt = round3(safeDiv(u.total.xga, float64(u.total.fa)))
+	}
+	return unit
+}
+
+// stGame is one team's special teams in one game
+type stGame struct {
+	opponent string
+	pp, pk   *stUnit
+}
+
+// specialTeamsState returns the strength from each side ("5v4" and
+// "4v5"), or false when it isn't a power play: even strength, or a pulled
+// goalie putting a sixth skater out
+func specialTeamsState(skaters, opponents int) (string, string, bool) {
+	if skaters == opponents || skaters < 3 || skaters > 5 || opponents < 3 || opponents > 5 {
+		return "", "", false
+	}
+	return fmt.Sprintf("%dv%d", skaters, opponents), fmt.Sprintf("%dv%d", opponents, skaters), true
+}
+
+// specialTeamsGames walks each game's events in order and returns every
+// team's special teams per game, keyed by team then gameKey
+func specialTeamsGames(shots []ShotData) map[string]map[string]*stGame {
+	byGame := make(map[string][]ShotData)
+	for _, shot := range shots {
+		if shot.HomeTeamCode == "" || shot.AwayTeamCode == "" {
+			continue
+		}
+		byGame[gameKey(shot)] = append(byGame[gameKey(shot)], shot)
+	}
+
+	teams := make(map[string]map[string]*stGame)
+	for key, events := range byGame {
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].GameSeconds < events[j].GameSeconds
+		})
+		home, away := events[0].HomeTeamCode, events[0].AwayTeamCode
+		games := map[string]*stGame{
+			home: {opponent: away, pp: newSTUnit(), pk: newSTUnit()},
+			away: {opponent: home, pp: newSTUnit(), pk: newSTUnit()},
+		}
+		for team, game := range games {
+			if teams[team] == nil {
+				teams[team] = make(map[string]*stGame)
+			}
+			teams[team][key] = game
+		}
+		walkSpecialTeams(events, games[home], games[away])
+	}
+	return teams
+}
+
+// walkSpecialTeams fills home's and away's units from a game's sorted
+// events. A power play starts when a team's skater advantage appears. The
+// time between two events goes to the state they share, or half to each
+// when it changed in between.
+func walkSpecialTeams(events []ShotData, home, away *stGame) {
+	addTime := func(event ShotData, seconds float64) {
+		if seconds <= 0 {
+			return
+		}
+		homeState, awayState, ok := specialTeamsState(event.HomeSkatersOnIce, event.AwaySkatersOnIce)
+		if !ok {
+			return
+		}
+		pp, pk := home, away
+		ppState, pkState := homeState, awayState
+		if event.AwaySkatersOnIce > event.HomeSkatersOnIce {
+			pp, pk = away, home
+			ppState, pkState = awayState, homeState
+		}
+		pp.pp.total.seconds += seconds
+		pp.pp.state(ppState).seconds += seconds
+		pk.pk.total.seconds += seconds
+		pk.pk.state(pkState).seconds += seconds
+	}
+
+	// Which team's power play is open, and whether it has scored
+	var open *stGame
+	scored := false
+
+	for i, event := range events {
+		if i > 0 {
+			prev := events[i-1]
+			gap := float64(event.GameSeconds - prev.GameSeconds)
+			if prev.HomeSkatersOnIce == event.HomeSkatersOnIce && prev.AwaySkatersOnIce == event.AwaySkatersOnIce {
+				addTime(event, gap)
+			} else {
+				addTime(prev, gap/2)
+				addTime(event, gap/2)
+			}
+		}
+
+		_, _, special := specialTeamsState(event.HomeSkatersOnIce, event.AwaySkatersOnIce)
+		var advantaged, shorthanded *stGame
+		if special {
+			advantaged, shorthanded = home, away
+			if event.AwaySkatersOnIce > event.HomeSkatersOnIce {
+				advantaged, shorthanded = away, home
+			}
+		}
+		if advantaged != open {
+			open, scored = advantaged, false
+			if open != nil {
+				advantaged.pp.opportunities++
+				shorthanded.pk.opportunities++
+			}
+		}
+		if !special {
+			continue
+		}
+
+		homeState, awayState, _ := specialTeamsState(event.HomeSkatersOnIce, event.AwaySkatersOnIce)
+		shooterState, defenderState := homeState, awayState
+		shooter, defender := home, away
+		if event.TeamCode != event.HomeTeamCode {
+			shooterState, defenderState = awayState, homeState
+			shooter, defender = away, home
+		}
+
+		// The shooter is on the power play or shorthanded; record it on
+		// both teams' units
+		shooterUnit, defenderUnit := shooter.pp, defender.pk
+		if shooter != advantaged {
+			shooterUnit, defenderUnit = shooter.pk, defender.pp
+		}
+		for _, s := range []*stSplit{&shooterUnit.total, shooterUnit.state(shooterState)} {
+			recordShotFor(s, event)
+		}
+		for _, s := range []*stSplit{&defenderUnit.total, defenderUnit.state(defenderState)} {
+			recordShotAgainst(s, event)
+		}
+
+		if event.Goal && shooter == advantaged && !scored {
+			scored = true
+			advantaged.pp.converted++
+			shorthanded.pk.converted++
+		}
+	}
+
+	// The last state runs to the end of its period
+	last := events[len(events)-1]
+	period := last.Period
+	if period == 0 {
+		period = last.GameSeconds/periodSeconds + 1
+	}
+	addTime(last, float64(periodTimeLeft(last, period)))
+}
+
+func recordShotFor(s *stSplit, shot ShotData) {
+	s.cf++
+	if !isBlockedShot(shot) {
+		s.ff++
+		s.xgf += shot.XGoal
+	}
+	if shot.Goal {
+		s.gf++
+	}
+}
+
+func recordShotAgainst(s *stSplit, shot ShotData) {
+	s.ca++
+	if !isBlockedShot(shot) {
+		s.fa++
+		s.xga += shot.XGoal
+	}
+	if shot.Goal {
+		s.ga++
+	}
+}
+
+// buildSpecialTeams sums a team's games into its units and recent form.
+// keys must be in the order the games were played.
+func buildSpecialTeams(team string, games map[string]*stGame, keys []string, recent int) *TeamSpecialTeams {
+	pp, pk := newSTUnit(), newSTUnit()
+	recentPP, recentPK := newSTUnit(), newSTUnit()
+	for i, key := range keys {
+		pp.merge(games[key].pp)
+		pk.merge(games[key].pk)
+		if i >= len(keys)-recent {
+			recentPP.merge(games[key].pp)
+			recentPK.merge(games[key].pk)
+		}
+	}
+
+	result := &TeamSpecialTeams{
+		Team:        team,
+		GamesPlayed: len(keys),
+		PowerPlay:   pp.result(powerPlayStates, true),
+		PenaltyKill: pk.result(penaltyKillStates, false),
+	}
+
+	recentGames := min(recent, len(keys))
+	form := SpecialTeamsForm{
+		Games:         recentGames,
+		PPXGFPer60:    recentPP.total.result().XGFPer60,
+		PKXGAPer60:    recentPK.total.result().XGAPer60,
+		PPOppsPerGame: round2(safeDiv(float64(recentPP.opportunities), float64(recentGames))),
+		PKOppsPerGame: round2(safeDiv(float64(recentPK.opportunities), float64(recentGames))),
+	}
+	form.PPXGFPer60Trend = round2(form.PPXGFPer60 - result.PowerPlay.XGFPer60)
+	form.PKXGAPer60Trend = round2(form.PKXGAPer60 - result.PenaltyKill.XGAPer60)
+	form.PPOppsPerGameTrend = round2(form.PPOppsPerGame - safeDiv(float64(pp.opportunities), float64(len(keys))))
+	result.Recent = form
+	return result
+}
+
+// aggregateSpecialTeams builds and ranks every team's special teams. The
+// returned map holds each team's games for the per-game log.
+func aggregateSpecialTeams(shots []ShotData, recent int) ([]*TeamSpecialTeams, map[string]map[string]*stGame, *gameOrder) {
+	order := newGameOrder()
+	for _, shot := range shots {
+		order.add(shot)
+	}
+	games := specialTeamsGames(shots)
+
+	teams := make([]*TeamSpecialTeams, 0, len(games))
+	for team, teamGames := range games {
+		keys := make([]string, 0, len(teamGames))
+		for key := range teamGames {
+			keys = append(keys, key)
+		}
+		order.sort(keys)
+		teams = append(teams, buildSpecialTeams(team, teamGames, keys, recent))
+	}
+
+	// Power plays rank by xGF/60, penalty kills by fewest xGA/60
+	sort.Slice(teams, func(i, j int) bool {
+		return teams[i].PenaltyKill.XGAPer60 < teams[j].PenaltyKill.XGAPer60
+	})
+	for i, team := range teams {
+		team.PenaltyKill.Rank = i + 1
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		if teams[i].PowerPlay.XGFPer60 != teams[j].PowerPlay.XGFPer60 {
+			return teams[i].PowerPlay.XGFPer60 > teams[j].PowerPlay.XGFPer60
+		}
+		return teams[i].Team < teams[j].Team
+	})
+	for i, team := range teams {
+		team.PowerPlay.Rank = i + 1
+	}
+	return teams, games, order
+}
+
+func recentGamesFromQuery(c echo.Context) (int, error) {
+	recent := defaultRecentGames
+	if value := c.QueryParam("recent"); value != "" {
+		var err error
+		recent, err = strconv.Atoi(value)
+		if err != nil || recent < 1 {
+			return 0, fmt.Errorf("recent must be a positive number of games")
+		}
+	}
+	return recent, nil
+}
+
+var specialTeamsMetadata = map[string]interface{}{
+	"opportunities": "Inferred from strength changes between shot events; a power play with no attempts isn't seen",
+	"timeOnIce":     "Estimated from the gaps between events, split in half when the strength changed in between",
+	"states":        "5v4, 5v3 and 4v3 from the power play side; pulled-goalie situations aren't special teams",
+	"recent":        "Recent form covers the last `recent` games; trends are recent minus the full sample",
+}
+
+// ProcessSpecialTeamsHandler reports every team's power play and penalty
+// kill, ranked across the league.
+//
+// Query params: the shared shot filters and recent (games in the form
+// window, default 10).
+func ProcessSpecialTeamsHandler(c echo.Context) error {
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	recent, err := recentGamesFromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	teams, _, _ := aggregateSpecialTeams(shots, recent)
+
+	filtered := make([]*TeamSpecialTeams, 0, len(teams))
+	for _, team := range teams {
+		if filter.includesTeam(team.Team) {
+			filtered = append(filtered, team)
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"teams":    filtered,
+		"metadata": specialTeamsMetadata,
+	})
+}
+
+// TeamSpecialTeamsHandler is ProcessSpecialTeamsHandler for one team, with
+// its game-by-game log
+func TeamSpecialTeamsHandler(c echo.Context) error {
+	code := moneyPuckTeamCode(c.Param("code"))
+
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, _, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	recent, err := recentGamesFromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	teams, games, order := aggregateSpecialTeams(shots, recent)
+
+	var team *TeamSpecialTeams
+	for _, t := range teams {
+		if t.Team == code {
+			team = t
+		}
+	}
+	if team == nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "No games found for team " + code})
+	}
+
+	summaries := aggregateGameStats(shots)
+	keys := make([]string, 0, len(games[code]))
+	for key := range games[code] {
+		keys = append(keys, key)
+	}
+	order.sort(keys)
+	for _, key := range keys {
+		game := games[code][key]
+		summary := summaries[key]
+		team.Games = append(team.Games, SpecialTeamGame{
+			GameID:          summary.GameID,
+			Season:          summary.Season,
+			Date:            summary.Date,
+			Opponent:        game.opponent,
+			PPOpportunities: game.pp.opportunities,
+			PPGoals:         game.pp.total.gf,
+			PPXGF:           round2(game.pp.total.xgf),
+			PPTimeOnIce:     round1(game.pp.total.seconds / 60),
+			PKOpportunities: game.pk.opportunities,
+			PKGoalsAgainst:  game.pk.total.ga,
+			PKXGA:           round2(game.pk.total.xga),
+			PKTimeOnIce:     round1(game.pk.total.seconds / 60),
+		})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"team":     team,
+		"metadata": specialTeamsMetadata,
+	})
+}
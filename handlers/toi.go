@@ -0,0 +1,548 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// defaultTOIDataPath is read when TOI_DATA_PATH isn't set. It can be a
+// MoneyPuck skaters (line stats) export or an NHL shift chart export.
+const defaultTOIDataPath = "data/skaters.csv"
+
+// ErrNoTOIData is returned when no time-on-ice file is available
+var ErrNoTOIData = errors.New("no time-on-ice data loaded")
+
+// TOI source formats
+const (
+	toiSourceSkaters = "skaters"
+	toiSourceShifts  = "shifts"
+)
+
+func toiDataPath() string {
+	if path := os.Getenv("TOI_DATA_PATH"); path != "" {
+		return path
+	}
+	return defaultTOIDataPath
+}
+
+// PlayerTOI is a skater's ice time and what happened while they were on
+// the ice, for the games or seasons asked for
+type PlayerTOI struct {
+	PlayerID string
+	Name     string
+	Team     string
+	Position string
+	Games    int
+	Seconds  float64
+	OnIceCF  int
+	OnIceCA  int
+	OnIceGF  int
+	OnIceGA  int
+	OnIceXGF float64
+	OnIceXGA float64
+}
+
+// skaterSeason is one row of a MoneyPuck skaters export
+type skaterSeason struct {
+	season string
+	toi    PlayerTOI
+}
+
+// shift is one row of a shift chart: a player's stint on the ice, in
+// seconds from the start of the game
+type shift struct {
+	gameKey  string
+	playerID string
+	name     string
+	team     string // In MoneyPuck form, to match the shot data
+	start    int
+	end      int
+}
+
+// toiData is a parsed TOI file in one of the two formats
+type toiData struct {
+	source  string
+	skaters []skaterSeason
+	shifts  []shift
+}
+
+var toiCache struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	data    *toiData
+}
+
+// loadTOIData returns the parsed TOI file, re-reading it when it changes
+func loadTOIData() (*toiData, error) {
+	path := toiDataPath()
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoTOIData
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	toiCache.Lock()
+	defer toiCache.Unlock()
+	if toiCache.data != nil && toiCache.path == path && toiCache.modTime.Equal(info.ModTime()) && toiCache.size == info.Size() {
+		return toiCache.data, nil
+	}
+
+	start := time.Now()
+	data, err := readTOIFile(path)
+	if err != nil {
+		return nil, err
+	}
+	toiCache.path, toiCache.modTime, toiCache.size, toiCache.data = path, info.ModTime(), info.Size(), data
+	log.Printf("Loaded %s time on ice from %s in %v", data.source, path, time.Since(start).Round(time.Millisecond))
+	return data, nil
+}
+
+func readTOIFile(path string) (*toiData, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", path, err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%s is empty or invalid", path)
+	}
+
+	headers := records[0]
+	switch {
+	case findColumnIndex(headers, "icetime") >= 0:
+		return parseSkaterRows(records)
+	case findColumnIndex(headers, "startTime") >= 0 && findColumnIndex(headers, "endTime") >= 0:
+		return parseShiftRows(records)
+	default:
+		return nil, fmt.Errorf("%s is neither a skaters export (icetime column) nor a shift chart (startTime/endTime columns)", path)
+	}
+}
+
+// parseSkaterRows reads the "all" situation rows of a MoneyPuck skaters
+// export
+func parseSkaterRows(records [][]string) (*toiData, error) {
+	headers := records[0]
+	col := func(name string) int { return findColumnIndex(headers, name) }
+	playerID, season, situation, games, icetime := col("playerId"), col("season"), col("situation"), col("games_played"), col("icetime")
+	if playerID < 0 || season < 0 {
+		return nil, fmt.Errorf("skaters export needs playerId and season columns")
+	}
+	name, team, position := col("name"), col("team"), col("position")
+	cf, ca := col("OnIce_F_shotAttempts"), col("OnIce_A_shotAttempts")
+	gf, ga := col("OnIce_F_goals"), col("OnIce_A_goals")
+	xgf, xga := col("OnIce_F_xGoals"), col("OnIce_A_xGoals")
+
+	data := &toiData{source: toiSourceSkaters}
+	for _, row := range records[1:] {
+		if len(row) != len(headers) {
+			continue
+		}
+		get := func(idx int) string {
+			if idx < 0 {
+				return ""
+			}
+			return row[idx]
+		}
+		if situation >= 0 && row[situation] != "all" {
+			continue
+		}
+		data.skaters = append(data.skaters, skaterSeason{
+			season: row[season],
+			toi: PlayerTOI{
+				PlayerID: row[playerID],
+				Name:     get(name),
+				Team:     get(team),
+				Position: get(position),
+				Games:    parseInt(get(games), 0),
+				Seconds:  parseFloat(get(icetime), 0),
+				OnIceCF:  int(parseFloat(get(cf), 0)),
+				OnIceCA:  int(parseFloat(get(ca), 0)),
+				OnIceGF:  int(parseFloat(get(gf), 0)),
+				OnIceGA:  int(parseFloat(get(ga), 0)),
+				OnIceXGF: parseFloat(get(xgf), 0),
+				OnIceXGA: parseFloat(get(xga), 0),
+			},
+		})
+	}
+	return data, nil
+}
+
+// parseShiftRows reads an NHL shift chart. Times are m:ss into the period
+// when there's a period column, otherwise seconds into the game. Game IDs
+// may be the NHL's full 2024020001 form or MoneyPuck's season + game_id.
+func parseShiftRows(records [][]string) (*toiData, error) {
+	headers := records[0]
+	col := func(names ...string) int {
+		for _, name := range names {
+			if idx := findColumnIndex(headers, name); idx >= 0 {
+				return idx
+			}
+		}
+		return -1
+	}
+	playerID, gameID, season := col("playerId"), col("gameId", "game_id"), col("season")
+	team, period := col("teamAbbrev", "team", "teamCode"), col("period")
+	startTime, endTime := col("startTime"), col("endTime")
+	firstName, lastName := col("firstName"), col("lastName")
+	if playerID < 0 || gameID < 0 || team < 0 {
+		return nil, fmt.Errorf("shift chart needs playerId, gameId and teamAbbrev columns")
+	}
+
+	data := &toiData{source: toiSourceShifts}
+	for _, row := range records[1:] {
+		if len(row) != len(headers) || row[startTime] == "" || row[endTime] == "" {
+			continue
+		}
+
+		seasonValue := ""
+		if season >= 0 {
+			seasonValue = row[season]
+		}
+		key, ok := shiftGameKey(row[gameID], seasonValue)
+		if !ok {
+			continue
+		}
+
+		offset := 0
+		if period >= 0 {
+			offset = (parseInt(row[period], 1) - 1) * periodSeconds
+		}
+		start, errStart := parseClock(row[startTime])
+		end, errEnd := parseClock(row[endTime])
+		if errStart != nil || errEnd != nil || end <= start {
+			continue
+		}
+
+		name := ""
+		if firstName >= 0 && lastName >= 0 {
+			name = strings.TrimSpace(row[firstName] + " " + row[lastName])
+		}
+		data.shifts = append(data.shifts, shift{
+			gameKey:  key,
+			playerID: row[playerID],
+			name:     name,
+			team:     moneyPuckTeamCode(row[team]),
+			start:    offset + start,
+			end:      offset + end,
+		})
+	}
+	return data, nil
+}
+
+// shiftGameKey converts a shift chart game ID to gameKey form. NHL IDs
+// like 2024020001 are the season followed by MoneyPuck's game_id (20001)
+// zero padded to 6 digits.
+func shiftGameKey(gameID, season string) (string, bool) {
+	if len(gameID) == 10 {
+		number, err := strconv.Atoi(gameID[4:])
+		if err != nil {
+			return "", false
+		}
+		return gameID[:4] + "-" + strconv.Itoa(number), true
+	}
+	if season == "" {
+		return "", false
+	}
+	normalized, err := normalizeNHLSeason(season)
+	if err != nil {
+		return "", false
+	}
+	return normalized + "-" + strings.TrimLeft(gameID, "0"), true
+}
+
+// playerTOI returns each skater's ice time for the games in shots, keyed
+// by player ID. Shift charts are matched game by game, so every shot
+// filter applies; a skaters export is per season, so only the seasons do.
+func playerTOI(shots []ShotData) (map[string]*PlayerTOI, string, error) {
+	data, err := loadTOIData()
+	if err != nil {
+		return nil, "", err
+	}
+
+	players := make(map[string]*PlayerTOI)
+	player := func(id string) *PlayerTOI {
+		p, ok := players[id]
+		if !ok {
+			p = &PlayerTOI{PlayerID: id}
+			players[id] = p
+		}
+		return p
+	}
+
+	if data.source == toiSourceSkaters {
+		seasons := make(map[string]bool)
+		for _, shot := range shots {
+			seasons[shot.Season] = true
+		}
+		for _, row := range data.skaters {
+			if !seasons[row.season] {
+				continue
+			}
+			p := player(row.toi.PlayerID)
+			// Rows are per season and team, so the last one wins
+			p.Name, p.Team, p.Position = row.toi.Name, row.toi.Team, row.toi.Position
+			p.Games += row.toi.Games
+			p.Seconds += row.toi.Seconds
+			p.OnIceCF += row.toi.OnIceCF
+			p.OnIceCA += row.toi.OnIceCA
+			p.OnIceGF += row.toi.OnIceGF
+			p.OnIceGA += row.toi.OnIceGA
+			p.OnIceXGF += row.toi.OnIceXGF
+			p.OnIceXGA += row.toi.OnIceXGA
+		}
+		return players, data.source, nil
+	}
+
+	// Index each game's shots by time so shifts can find theirs quickly
+	gameShots := make(map[string][]ShotData)
+	for _, shot := range shots {
+		gameShots[gameKey(shot)] = append(gameShots[gameKey(shot)], shot)
+	}
+	for _, events := range gameShots {
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].GameSeconds < events[j].GameSeconds
+		})
+	}
+
+	games := make(map[string]map[string]bool)
+	for _, s := range data.shifts {
+		events, ok := gameShots[s.gameKey]
+		if !ok {
+			continue
+		}
+		p := player(s.playerID)
+		p.Name, p.Team = s.name, s.team
+		p.Seconds += float64(s.end - s.start)
+		if games[s.playerID] == nil {
+			games[s.playerID] = make(map[string]bool)
+		}
+		games[s.playerID][s.gameKey] = true
+
+		// A shot at the moment of a change counts for the players coming
+		// off, so the window is (start, end]
+		first := sort.Search(len(events), func(i int) bool { return events[i].GameSeconds > s.start })
+		for _, shot := range events[first:] {
+			if shot.GameSeconds > s.end {
+				break
+			}
+			blocked := isBlockedShot(shot)
+			if moneyPuckTeamCode(shot.TeamCode) == s.team {
+				p.OnIceCF++
+				if !blocked {
+					p.OnIceXGF += shot.XGoal
+				}
+				if shot.Goal {
+					p.OnIceGF++
+				}
+			} else {
+				p.OnIceCA++
+				if !blocked {
+					p.OnIceXGA += shot.XGoal
+				}
+				if shot.Goal {
+					p.OnIceGA++
+				}
+			}
+		}
+	}
+	for id, played := range games {
+		players[id].Games = len(played)
+	}
+	return players, data.source, nil
+}
+
+// applyPlayerTOI sets each player's TimeOnIce, in minutes, from the TOI
+// source. Players it doesn't cover are left at zero.
+func applyPlayerTOI(playerStats map[string]PlayerStats, toi map[string]*PlayerTOI) {
+	for id, stats := range playerStats {
+		if t, ok := toi[id]; ok {
+			stats.TimeOnIce = t.Seconds / 60
+			playerStats[id] = stats
+		}
+	}
+}
+
+// playerRates puts a skater's shots and on-ice numbers on a per-60 basis.
+// It returns nil without ice time to divide by.
+func playerRates(stats PlayerStats, toi *PlayerTOI) *NHLRateStats {
+	if toi == nil || toi.Seconds <= 0 {
+		return nil
+	}
+	per60 := func(value float64) float64 {
+		return round2(value / toi.Seconds * 3600)
+	}
+	return &NHLRateStats{
+		TimeOnIce:        round1(toi.Seconds / 60),
+		TimeOnIcePerGame: round1(safeDiv(toi.Seconds/60, float64(toi.Games))),
+		ShotsPer60:       per60(float64(stats.ShotsAttempted)),
+		ShotsOnGoalPer60: per60(float64(stats.ShotsOnGoal)),
+		GoalsPer60:       per60(float64(stats.Goals)),
+		IXGPer60:         per60(stats.TotalXGoals),
+		OnIceGF:          toi.OnIceGF,
+		OnIceGA:          toi.OnIceGA,
+		OnIceXGF:         round2(toi.OnIceXGF),
+		OnIceXGA:         round2(toi.OnIceXGA),
+		OnIceXGFPct:      round1(safeDiv(toi.OnIceXGF, toi.OnIceXGF+toi.OnIceXGA) * 100),
+		OnIceXGFPer60:    per60(toi.OnIceXGF),
+		OnIceXGAPer60:    per60(toi.OnIceXGA),
+		OnIceCFPct:       round1(safeDiv(float64(toi.OnIceCF), float64(toi.OnIceCF+toi.OnIceCA)) * 100),
+	}
+}
+
+// defaultMinTOI is the ice time, in minutes, a skater needs to be listed
+// by PlayerRatesHandler; rates over a few shifts are mostly noise
+const defaultMinTOI = 50.0
+
+// PlayerRates is one skater in the rates table
+type PlayerRates struct {
+	PlayerID    string  `json:"playerId"`
+	PlayerName  string  `json:"playerName"`
+	Position    string  `json:"position"`
+	TeamCode    string  `json:"teamCode"`
+	GamesPlayed int     `json:"gamesPlayed"` // Games with ice time
+	Shots       int     `json:"shots"`
+	ShotsOnGoal int     `json:"shotsOnGoal"`
+	Goals       int     `json:"goals"`
+	IXG         float64 `json:"ixg"`
+	NHLRateStats
+}
+
+// playerRateSorts are the columns PlayerRatesHandler can sort by, highest
+// first
+var playerRateSorts = map[string]func(p *PlayerRates) float64{
+	"timeOnIce":     func(p *PlayerRates) float64 { return p.TimeOnIce },
+	"shotsPer60":    func(p *PlayerRates) float64 { return p.ShotsPer60 },
+	"goalsPer60":    func(p *PlayerRates) float64 { return p.GoalsPer60 },
+	"ixgPer60":      func(p *PlayerRates) float64 { return p.IXGPer60 },
+	"onIceXGFPct":   func(p *PlayerRates) float64 { return p.OnIceXGFPct },
+	"onIceXGFPer60": func(p *PlayerRates) float64 { return p.OnIceXGFPer60 },
+	// Fewest against is best
+	"onIceXGAPer60": func(p *PlayerRates) float64 { return -p.OnIceXGAPer60 },
+}
+
+// PlayerRatesHandler lists skaters' per-60 and on-ice rates from the TOI
+// file, so players with very different ice time can be compared.
+//
+// Query params: the shared shot filters (from, to and gameType only with a
+// shift chart), minToi (minutes, default 50) and sort (timeOnIce,
+// shotsPer60, goalsPer60, ixgPer60, onIceXGFPct, onIceXGFPer60 or
+// onIceXGAPer60; default ixgPer60).
+func PlayerRatesHandler(c echo.Context) error {
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	minTOI := defaultMinTOI
+	if value := c.QueryParam("minToi"); value != "" {
+		minTOI, err = strconv.ParseFloat(value, 64)
+		if err != nil || minTOI < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "minToi must be a non-negative number of minutes"})
+		}
+	}
+	sortBy := c.QueryParam("sort")
+	if sortBy == "" {
+		sortBy = "ixgPer60"
+	}
+	sortValue, ok := playerRateSorts[sortBy]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown sort %q", sortBy)})
+	}
+
+	toi, source, err := playerTOI(shots)
+	if errors.Is(err, ErrNoTOIData) {
+		return c.JSON(http.StatusNotFound, map[string]string{
+			"error": fmt.Sprintf("%v; add a skaters export or shift chart at %s or set TOI_DATA_PATH", err, toiDataPath()),
+		})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	// The skaters export only has whole seasons of ice time, which can't
+	// be the denominator for a slice of the shots
+	if source == toiSourceSkaters && (filter.hasDateRange() || filter.GameType != "") {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "from, to and gameType need a shift chart; the skaters export only covers whole seasons",
+		})
+	}
+
+	playerStats := aggregatePlayerStats(playerShotRows(shots))
+
+	players := make([]*PlayerRates, 0, len(toi))
+	for id, t := range toi {
+		if t.Seconds < minTOI*60 {
+			continue
+		}
+		// Skaters who never shot still have ice time and on-ice numbers
+		stats, ok := playerStats[id]
+		if !ok {
+			stats = PlayerStats{PlayerID: id, PlayerName: t.Name, Position: t.Position, TeamCode: t.Team}
+		}
+		if !filter.includesTeam(stats.TeamCode) {
+			continue
+		}
+		// No ice time to divide by, even when minToi lets the row through
+		rates := playerRates(stats, t)
+		if rates == nil {
+			continue
+		}
+		players = append(players, &PlayerRates{
+			PlayerID:     id,
+			PlayerName:   stats.PlayerName,
+			Position:     stats.Position,
+			TeamCode:     stats.TeamCode,
+			GamesPlayed:  t.Games,
+			Shots:        stats.ShotsAttempted,
+			ShotsOnGoal:  stats.ShotsOnGoal,
+			Goals:        stats.Goals,
+			IXG:          round2(stats.TotalXGoals),
+			NHLRateStats: *rates,
+		})
+	}
+	sort.Slice(players, func(i, j int) bool {
+		if a, b := sortValue(players[i]), sortValue(players[j]); a != b {
+			return a > b
+		}
+		return players[i].PlayerID < players[j].PlayerID
+	})
+
+	metadata := map[string]interface{}{
+		"source": source,
+		"minToi": minTOI,
+		"sort":   sortBy,
+		"onIce":  "On-ice shots, goals and xG are every unblocked attempt (all attempts for CF%) while the skater was on the ice, at all strengths",
+	}
+	if source == toiSourceSkaters {
+		metadata["coverage"] = "The skaters export is per season, so from, to and gameType aren't accepted with it"
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"players":  players,
+		"metadata": metadata,
+	})
+}
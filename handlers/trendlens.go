@@ -1,7 +1,8 @@
 package handlers
 
 import (
-	"encoding/csv"
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -10,38 +11,58 @@ import (
 	"strings"
 	"time"
 
-	"github.com/algolia/algoliasearch-client-go/v4/algolia/search"
+	"github.com/KPWithCode/statpad2/searchindex"
 	"github.com/labstack/echo/v4"
 )
 
 // ShotData represents individual shot data from the CSV
 type ShotData struct {
-	ShotID              string    `json:"shotID"`
-	GameID              string    `json:"game_id"`
-	ShooterName         string    `json:"shooterName"`
-	ShooterPlayerID     string    `json:"shooterPlayerId"`
-	GoalieNameForShot   string    `json:"goalieNameForShot"`
-	GoalieIDForShot     string    `json:"goalieIdForShot"`
-	TeamCode            string    `json:"teamCode"`
-	HomeTeamCode        string    `json:"homeTeamCode"`
-	AwayTeamCode        string    `json:"awayTeamCode"`
-	ShotDistance        float64   `json:"shotDistance"`
-	ShotAngle           float64   `json:"shotAngle"`
-	ShotType            string    `json:"shotType"`
-	Goal                bool      `json:"goal"`
-	XGoal               float64   `json:"xGoal"`
-	ShotRush            bool      `json:"shotRush"`
-	ShotWasOnGoal       bool      `json:"shotWasOnGoal"`
-	ShotOnEmptyNet      bool      `json:"shotOnEmptyNet"`
-	Period              int       `json:"period"`
-	TimeLeft            float64   `json:"timeLeft"`
-	ShotGoalProbability float64   `json:"shotGoalProbability"`
-	HomeSkatersOnIce    int       `json:"homeSkatersOnIce"`
-	AwaySkatersOnIce    int       `json:"awaySkatersOnIce"`
-	PlayerPosition      string    `json:"playerPositionThatDidEvent"`
-	ShooterTimeOnIce    float64   `json:"shooterTimeOnIce"`
-	Time                string    `json:"time"` // Time field for date filtering
-	Date                time.Time // Parsed time for filtering
+	ShotID                    string    `json:"shotID"`
+	GameID                    string    `json:"game_id"`
+	Season                    string    `json:"season"`
+	IsPlayoffGame             bool      `json:"isPlayoffGame"`
+	Event                     string    `json:"event"`
+	ShooterName               string    `json:"shooterName"`
+	ShooterPlayerID           string    `json:"shooterPlayerId"`
+	GoalieNameForShot         string    `json:"goalieNameForShot"`
+	GoalieIDForShot           string    `json:"goalieIdForShot"`
+	TeamCode                  string    `json:"teamCode"`
+	HomeTeamCode              string    `json:"homeTeamCode"`
+	AwayTeamCode              string    `json:"awayTeamCode"`
+	IsHomeTeam                bool      `json:"isHomeTeam"`
+	HomeTeamGoals             int       `json:"homeTeamGoals"`
+	AwayTeamGoals             int       `json:"awayTeamGoals"`
+	HomeTeamWon               bool      `json:"homeTeamWon"`
+	ShotDistance              float64   `json:"shotDistance"`
+	ShotAngle                 float64   `json:"shotAngle"`
+	ArenaAdjustedShotDistance float64   `json:"arenaAdjustedShotDistance"`
+	ShotAngleAdjusted         float64   `json:"shotAngleAdjusted"`
+	ShotType                  string    `json:"shotType"`
+	Goal                      bool      `json:"goal"`
+	XGoal                     float64   `json:"xGoal"`
+	ShotRush                  bool      `json:"shotRush"`
+	ShotRebound               bool      `json:"shotRebound"`          // Shot came off a rebound
+	ShotGeneratedRebound      bool      `json:"shotGeneratedRebound"` // Shot gave up a rebound
+	TimeSinceLastEvent        int       `json:"timeSinceLastEvent"`   // Seconds
+	LastEventCategory         string    `json:"lastEventCategory"`    // SHOT, MISS, BLOCK, TAKE, GIVE, HIT, FAC...
+	ShotWasOnGoal             bool      `json:"shotWasOnGoal"`
+	ShotOnEmptyNet            bool      `json:"shotOnEmptyNet"`
+	Period                    int       `json:"period"`
+	GameSeconds               int       `json:"gameSeconds"` // Seconds elapsed in the game ("time" column)
+	TimeLeft                  float64   `json:"timeLeft"`
+	ShotGoalProbability       float64   `json:"shotGoalProbability"`
+	HomeSkatersOnIce          int       `json:"homeSkatersOnIce"`
+	AwaySkatersOnIce          int       `json:"awaySkatersOnIce"`
+	PlayerPosition            string    `json:"playerPositionThatDidEvent"`
+	ShooterTimeOnIce          float64   `json:"shooterTimeOnIce"`
+	XCord                     float64   `json:"xCord"`
+	YCord                     float64   `json:"yCord"`
+	XCordAdjusted             float64   `json:"xCordAdjusted"` // Mirrored so the shooting team attacks +x
+	YCordAdjusted             float64   `json:"yCordAdjusted"`
+	Time                      string    `json:"time"` // Time field for date filtering
+	Date                      time.Time // Parsed time for filtering
+
+	hasCoordinates bool
 }
 
 // PlayerStats aggregates shot data for a player
@@ -59,38 +80,13 @@ type PlayerStats struct {
 	AverageDistance float64 `json:"averageDistance"`
 	AverageAngle    float64 `json:"averageAngle"`
 	TotalXGoals     float64 `json:"totalXGoals"`
-	TimeOnIce       float64 `json:"timeOnIce"`
+	TimeOnIce       float64 `json:"timeOnIce"` // Minutes, from the TOI file; 0 when it doesn't cover the player
 	PowerPlayShots  int     `json:"powerPlayShots"`
 	PowerPlayGoals  int     `json:"powerPlayGoals"`
 	HighDangerShots int     `json:"highDangerShots"`
 	HighDangerGoals int     `json:"highDangerGoals"`
 }
 
-// Helper function to check if a shot is from a high-danger area
-//
-//	func isShotHighDanger(shotDistance float64, shotAngle float64) bool {
-//		// Define high danger shots based on distance and angle
-//		// Typically shots from slot area (less than 25 feet, angles less than 45 degrees)
-//		return shotDistance < 25 && math.Abs(shotAngle) < 45
-//	}
-func isShotHighDanger(shotDistance float64, shotAngle float64, shotType string) bool {
-	shotType = strings.ToLower(shotType)
-
-	switch {
-	case shotDistance <= 20 && math.Abs(shotAngle) <= 45:
-		// Inner slot area - highest danger
-		return true
-	case shotDistance <= 30 && math.Abs(shotAngle) <= 35:
-		// Moderate distance but good angle
-		return true
-	case (shotType == "tip" || shotType == "deflection") && shotDistance <= 25:
-		// Tips and deflections are dangerous from slightly further out
-		return true
-	default:
-		return false
-	}
-}
-
 // Helper function to check if a shot was during power play
 func isPowerPlayShot(homeSkatersOnIce, awaySkatersOnIce int, isHomeTeam bool) bool {
 	if isHomeTeam {
@@ -172,12 +168,12 @@ func calculateAdvancedMetrics(playerStats PlayerStats) map[string]float64 {
 		metrics["shotsOnGoalPerGame"] = 0.0
 	}
 	// Shot on goal percentage
-	// if playerStats.ShotsAttempted > 0 {
-	// 	sogPct := float64(playerStats.ShotsOnGoal) / float64(playerStats.ShotsAttempted) * 100
-	// 	metrics["shotOnGoalPct"] = roundToOneDecimal(sogPct)
-	// } else {
-	// 	metrics["shotOnGoalPct"] = 0.0
-	// }
+	if playerStats.ShotsAttempted > 0 {
+		sogPct := float64(playerStats.ShotsOnGoal) / float64(playerStats.ShotsAttempted) * 100
+		metrics["shotOnGoalPct"] = roundToOneDecimal(sogPct)
+	} else {
+		metrics["shotOnGoalPct"] = 0.0
+	}
 
 	// Expected goals per shot
 	if playerStats.ShotsAttempted > 0 {
@@ -260,15 +256,129 @@ func calculateAdvancedMetrics(playerStats PlayerStats) map[string]float64 {
 
 	return metrics
 }
+
+// shotColumns holds the index of every CSV column ShotData is built from.
+// Indexes are resolved once per file; a missing column is -1.
+type shotColumns struct {
+	shotID, gameID, season, event                         int
+	isPlayoffGame, gameDate                               int
+	shooterName, shooterPlayerID, goalieName, goalieID    int
+	teamCode, homeTeamCode, awayTeamCode, isHomeTeam      int
+	homeTeamGoals, awayTeamGoals, homeTeamWon             int
+	shotDistance, shotAngle, arenaDistance, angleAdjusted int
+	shotType, goal, xGoal, shotRush, shotOnEmptyNet       int
+	shotRebound, shotGeneratedRebound                     int
+	timeSinceLastEvent, lastEventCategory                 int
+	period, time, timeLeft, shotGoalProbability           int
+	homeSkaters, awaySkaters, position, shooterTimeOnIce  int
+	xCord, yCord, xCordAdjusted, yCordAdjusted            int
+}
+
+func resolveShotColumns(headers []string) shotColumns {
+	return shotColumns{
+		shotID:               findColumnIndex(headers, "shotID"),
+		gameID:               findColumnIndex(headers, "game_id"),
+		season:               findColumnIndex(headers, "season"),
+		event:                findColumnIndex(headers, "event"),
+		isPlayoffGame:        findColumnIndex(headers, "isPlayoffGame"),
+		gameDate:             findColumnIndex(headers, "gameDate"),
+		shooterName:          findColumnIndex(headers, "shooterName"),
+		shooterPlayerID:      findColumnIndex(headers, "shooterPlayerId"),
+		goalieName:           findColumnIndex(headers, "goalieNameForShot"),
+		goalieID:             findColumnIndex(headers, "goalieIdForShot"),
+		teamCode:             findColumnIndex(headers, "teamCode"),
+		homeTeamCode:         findColumnIndex(headers, "homeTeamCode"),
+		awayTeamCode:         findColumnIndex(headers, "awayTeamCode"),
+		isHomeTeam:           findColumnIndex(headers, "isHomeTeam"),
+		homeTeamGoals:        findColumnIndex(headers, "homeTeamGoals"),
+		awayTeamGoals:        findColumnIndex(headers, "awayTeamGoals"),
+		homeTeamWon:          findColumnIndex(headers, "homeTeamWon"),
+		shotDistance:         findColumnIndex(headers, "shotDistance"),
+		shotAngle:            findColumnIndex(headers, "shotAngle"),
+		arenaDistance:        findColumnIndex(headers, "arenaAdjustedShotDistance"),
+		angleAdjusted:        findColumnIndex(headers, "shotAngleAdjusted"),
+		shotType:             findColumnIndex(headers, "shotType"),
+		goal:                 findColumnIndex(headers, "goal"),
+		xGoal:                findColumnIndex(headers, "xGoal"),
+		shotRush:             findColumnIndex(headers, "shotRush"),
+		shotOnEmptyNet:       findColumnIndex(headers, "shotOnEmptyNet"),
+		shotRebound:          findColumnIndex(headers, "shotRebound"),
+		shotGeneratedRebound: findColumnIndex(headers, "shotGeneratedRebound"),
+		timeSinceLastEvent:   findColumnIndex(headers, "timeSinceLastEvent"),
+		lastEventCategory:    findColumnIndex(headers, "lastEventCategory"),
+		period:               findColumnIndex(headers, "period"),
+		time:                 findColumnIndex(headers, "time"),
+		timeLeft:             findColumnIndex(headers, "timeLeft"),
+		shotGoalProbability:  findColumnIndex(headers, "shotGoalProbability"),
+		homeSkaters:          findColumnIndex(headers, "homeSkatersOnIce"),
+		awaySkaters:          findColumnIndex(headers, "awaySkatersOnIce"),
+		position:             findColumnIndex(headers, "playerPositionThatDidEvent"),
+		shooterTimeOnIce:     findColumnIndex(headers, "shooterTimeOnIce"),
+		xCord:                findColumnIndex(headers, "xCord"),
+		yCord:                findColumnIndex(headers, "yCord"),
+		xCordAdjusted:        findColumnIndex(headers, "xCordAdjusted"),
+		yCordAdjusted:        findColumnIndex(headers, "yCordAdjusted"),
+	}
+}
+
+// value returns the cell at idx, or "" if the column is missing
+func (cols shotColumns) value(row []string, idx int) string {
+	if idx < 0 || idx >= len(row) {
+		return ""
+	}
+	return row[idx]
+}
+
+// shotDateFormats are tried in order when parsing a game date
+var shotDateFormats = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"01/02/2006 15:04:05",
+	"01/02/2006",
+	"2006-01-02",
+	"20060102",
+}
+
+// parseShotDate returns the zero time if value matches none of the formats
+func parseShotDate(value string) time.Time {
+	for _, format := range shotDateFormats {
+		if t, err := time.Parse(format, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseCoordinates fills the rink coordinates. Exports with only the raw
+// columns are mirrored here so the shooting team always attacks +x.
+func (shot *ShotData) parseCoordinates(x, y, xAdjusted, yAdjusted string) {
+	if x == "" && xAdjusted == "" {
+		return
+	}
+	shot.hasCoordinates = true
+	shot.XCord = parseFloat(x, 0)
+	shot.YCord = parseFloat(y, 0)
+
+	if xAdjusted != "" {
+		shot.XCordAdjusted = parseFloat(xAdjusted, 0)
+		shot.YCordAdjusted = parseFloat(yAdjusted, 0)
+		return
+	}
+	shot.XCordAdjusted, shot.YCordAdjusted = shot.XCord, shot.YCord
+	if shot.XCord < 0 {
+		shot.XCordAdjusted, shot.YCordAdjusted = -shot.XCord, -shot.YCord
+	}
+}
+
 func processCSVToShotData(csvData [][]string, headers []string) ([]ShotData, error) {
-	var shotData []ShotData
+	cols := resolveShotColumns(headers)
 
-	// Create map of header names to indices
-	headerMap := make(map[string]int)
-	for i, header := range headers {
-		headerMap[header] = i
+	if cols.event == -1 || cols.time == -1 || cols.teamCode == -1 || cols.season == -1 || cols.gameID == -1 {
+		return nil, fmt.Errorf("CSV does not contain required columns")
 	}
 
+	shotData := make([]ShotData, 0, len(csvData))
+
 	// Process each row in the CSV
 	for i := 1; i < len(csvData); i++ {
 		row := csvData[i]
@@ -276,84 +386,89 @@ func processCSVToShotData(csvData [][]string, headers []string) ([]ShotData, err
 			continue // Skip malformed rows
 		}
 
-		// Helper function to get value at a specific column
-		getValue := func(header string) string {
-			if idx, ok := headerMap[header]; ok && idx < len(row) {
-				return row[idx]
-			}
-			return ""
-		}
-
-		// Check if this is truly a player shot (has player ID, name, etc.)
-		playerID := getValue("shooterPlayerId")
-		playerName := getValue("shooterName")
-		if playerID == "" || playerName == "" {
-			// Skip rows that don't have clear player attribution
-			continue
+		get := func(idx int) string {
+			return cols.value(row, idx)
 		}
 
 		// Determine if it's a shot on goal (must be explicitly SHOT event)
-		eventType := getValue("event")
+		eventType := get(cols.event)
 		isShotOnGoal := eventType == "SHOT"
-		isGoal := parseBool(getValue("goal"), false)
 
 		// Get time for date filtering
-		timeStr := getValue("time")
+		timeStr := get(cols.time)
 
 		// Construct shot data from CSV row
 		shot := ShotData{
-			ShotID:              getValue("shotID"),
-			GameID:              getValue("game_id"),
-			ShooterName:         playerName,
-			ShooterPlayerID:     playerID,
-			GoalieNameForShot:   getValue("goalieNameForShot"),
-			GoalieIDForShot:     getValue("goalieIdForShot"),
-			TeamCode:            getValue("teamCode"),
-			HomeTeamCode:        getValue("homeTeamCode"),
-			AwayTeamCode:        getValue("awayTeamCode"),
-			ShotDistance:        parseFloat(getValue("shotDistance"), 0),
-			ShotAngle:           parseFloat(getValue("shotAngle"), 0),
-			ShotType:            getValue("shotType"),
-			Goal:                isGoal,
-			XGoal:               parseFloat(getValue("xGoal"), 0),
-			ShotRush:            parseBool(getValue("shotRush"), false),
-			ShotWasOnGoal:       isShotOnGoal,
-			ShotOnEmptyNet:      parseBool(getValue("shotOnEmptyNet"), false),
-			Period:              parseInt(getValue("period"), 0),
-			TimeLeft:            parseFloat(getValue("timeLeft"), 0),
-			ShotGoalProbability: parseFloat(getValue("shotGoalProbability"), 0),
-			HomeSkatersOnIce:    parseInt(getValue("homeSkatersOnIce"), 5),
-			AwaySkatersOnIce:    parseInt(getValue("awaySkatersOnIce"), 5),
-			PlayerPosition:      getValue("playerPositionThatDidEvent"),
-			ShooterTimeOnIce:    parseFloat(getValue("shooterTimeOnIce"), 0),
-			Time:                timeStr,
+			ShotID:                    get(cols.shotID),
+			GameID:                    get(cols.gameID),
+			Season:                    get(cols.season),
+			IsPlayoffGame:             parseBool(get(cols.isPlayoffGame), false),
+			Event:                     eventType,
+			ShooterName:               get(cols.shooterName),
+			ShooterPlayerID:           get(cols.shooterPlayerID),
+			GoalieNameForShot:         get(cols.goalieName),
+			GoalieIDForShot:           get(cols.goalieID),
+			TeamCode:                  get(cols.teamCode),
+			HomeTeamCode:              get(cols.homeTeamCode),
+			AwayTeamCode:              get(cols.awayTeamCode),
+			IsHomeTeam:                parseBool(get(cols.isHomeTeam), false),
+			HomeTeamGoals:             parseInt(get(cols.homeTeamGoals), 0),
+			AwayTeamGoals:             parseInt(get(cols.awayTeamGoals), 0),
+			HomeTeamWon:               parseBool(get(cols.homeTeamWon), false),
+			ShotDistance:              parseFloat(get(cols.shotDistance), 0),
+			ShotAngle:                 parseFloat(get(cols.shotAngle), 0),
+			ArenaAdjustedShotDistance: parseFloat(get(cols.arenaDistance), -1),
+			ShotAngleAdjusted:         parseFloat(get(cols.angleAdjusted), 0),
+			ShotType:                  get(cols.shotType),
+			Goal:                      parseBool(get(cols.goal), false),
+			XGoal:                     parseFloat(get(cols.xGoal), 0),
+			ShotRush:                  parseBool(get(cols.shotRush), false),
+			ShotRebound:               parseBool(get(cols.shotRebound), false),
+			ShotGeneratedRebound:      parseBool(get(cols.shotGeneratedRebound), false),
+			TimeSinceLastEvent:        int(parseFloat(get(cols.timeSinceLastEvent), 0)),
+			LastEventCategory:         get(cols.lastEventCategory),
+			ShotWasOnGoal:             isShotOnGoal,
+			ShotOnEmptyNet:            parseBool(get(cols.shotOnEmptyNet), false),
+			Period:                    parseInt(get(cols.period), 0),
+			GameSeconds:               parseInt(timeStr, 0),
+			TimeLeft:                  parseFloat(get(cols.timeLeft), 0),
+			ShotGoalProbability:       parseFloat(get(cols.shotGoalProbability), 0),
+			HomeSkatersOnIce:          parseInt(get(cols.homeSkaters), 5),
+			AwaySkatersOnIce:          parseInt(get(cols.awaySkaters), 5),
+			PlayerPosition:            get(cols.position),
+			ShooterTimeOnIce:          parseFloat(get(cols.shooterTimeOnIce), 0),
+			Time:                      timeStr,
 		}
 
-		// Try to parse the time if available
-		if timeStr != "" {
-			// Attempt to parse time in various formats
-			formats := []string{
-				"2006-01-02 15:04:05",
-				"2006-01-02T15:04:05",
-				"01/02/2006 15:04:05",
-				"01/02/2006",
-				"2006-01-02",
-			}
-
-			for _, format := range formats {
-				if t, err := time.Parse(format, timeStr); err == nil {
-					shot.Date = t
-					break
-				}
-			}
+		// Prefer an explicit game date column, falling back to the time
+		// column for exports that carry a timestamp there
+		if dateStr := get(cols.gameDate); dateStr != "" {
+			shot.Date = parseShotDate(dateStr)
+		} else if timeStr != "" {
+			shot.Date = parseShotDate(timeStr)
 		}
 
+		shot.parseCoordinates(get(cols.xCord), get(cols.yCord), get(cols.xCordAdjusted), get(cols.yCordAdjusted))
+
 		shotData = append(shotData, shot)
 	}
 
 	return shotData, nil
 }
 
+// playerShotRows keeps the shots with clear player attribution (a shooter ID
+// and name). Team-level rows are dropped.
+func playerShotRows(shots []ShotData) []ShotData {
+	playerShots := make([]ShotData, 0, len(shots))
+	for _, shot := range shots {
+		if shot.ShooterPlayerID == "" || shot.ShooterName == "" {
+			continue
+		}
+		playerShots = append(playerShots, shot)
+	}
+	return playerShots
+}
+
 // }
 // Update the aggregatePlayerStats function to only count player shots
 func debugShotData(shotData []ShotData) {
@@ -437,7 +552,7 @@ func aggregatePlayerStats(shotData []ShotData) map[string]PlayerStats {
 		}
 
 		// Add game to player's games played
-		playerGames[playerId][shot.GameID] = true
+		playerGames[playerId][gameKey(shot)] = true
 
 		// Update player stats
 		stats := playerStats[playerId]
@@ -475,7 +590,7 @@ func aggregatePlayerStats(shotData []ShotData) map[string]PlayerStats {
 		}
 
 		// Check for high danger shot
-		if isShotHighDanger(shot.ShotDistance, shot.ShotAngle, shot.ShotType) {
+		if shotDangerTier(shot) == dangerHigh {
 			stats.HighDangerShots++
 			if shot.Goal {
 				stats.HighDangerGoals++
@@ -495,7 +610,6 @@ func aggregatePlayerStats(shotData []ShotData) map[string]PlayerStats {
 		stats.AverageDistance = ((stats.AverageDistance * float64(stats.ShotsAttempted-1)) + shot.ShotDistance) / float64(stats.ShotsAttempted)
 		stats.AverageAngle = ((stats.AverageAngle * float64(stats.ShotsAttempted-1)) + math.Abs(shot.ShotAngle)) / float64(stats.ShotsAttempted)
 		stats.TotalXGoals += shot.XGoal
-		stats.TimeOnIce += shot.ShooterTimeOnIce
 
 		// Update the player stats in the map
 		playerStats[playerId] = stats
@@ -520,133 +634,67 @@ func aggregatePlayerStats(shotData []ShotData) map[string]PlayerStats {
 	return playerStats
 }
 
-// Aggregate shots by game
-func aggregateGameStats(shotData []ShotData) map[string]map[string]interface{} {
-	gameStats := make(map[string]map[string]interface{})
-
-	// Group shots by game
-	gameShots := make(map[string][]ShotData)
-	for _, shot := range shotData {
-		gameID := shot.GameID
-		gameShots[gameID] = append(gameShots[gameID], shot)
-	}
-
-	// Process each game
-	for gameID, shots := range gameShots {
-		// Initialize game stats
-		stats := make(map[string]interface{})
-
-		// Basic game info
-		if len(shots) > 0 {
-			stats["homeTeam"] = shots[0].HomeTeamCode
-			stats["awayTeam"] = shots[0].AwayTeamCode
-		}
-
-		// Count shots and goals
-		totalShots := len(shots)
-		totalGoals := 0
-		totalXGoals := 0.0
-
-		homeShots := 0
-		homeGoals := 0
-		homeXGoals := 0.0
-
-		awayShots := 0
-		awayGoals := 0
-		awayXGoals := 0.0
-
-		for _, shot := range shots {
-			// Add to totals
-			if shot.Goal {
-				totalGoals++
-			}
-			totalXGoals += shot.XGoal
-
-			// Add to team-specific counts
-			isHomeTeam := shot.TeamCode == shot.HomeTeamCode
-			if isHomeTeam {
-				homeShots++
-				if shot.Goal {
-					homeGoals++
-				}
-				homeXGoals += shot.XGoal
-			} else {
-				awayShots++
-				if shot.Goal {
-					awayGoals++
-				}
-				awayXGoals += shot.XGoal
-			}
-		}
-
-		// Store statistics
-		stats["totalShots"] = totalShots
-		stats["totalGoals"] = totalGoals
-		stats["totalXGoals"] = math.Round(totalXGoals*100) / 100
-
-		stats["homeShots"] = homeShots
-		stats["homeGoals"] = homeGoals
-		stats["homeXGoals"] = math.Round(homeXGoals*100) / 100
-
-		stats["awayShots"] = awayShots
-		stats["awayGoals"] = awayGoals
-		stats["awayXGoals"] = math.Round(awayXGoals*100) / 100
-
-		// Calculate game pace (shots per minute)
-		stats["gamePace"] = math.Round((float64(totalShots)/60.0)*10) / 10
-
-		// Calculate xG share
-		if homeXGoals+awayXGoals > 0 {
-			homeXGShare := homeXGoals / (homeXGoals + awayXGoals)
-			stats["homeXGShare"] = math.Round(homeXGShare*1000) / 1000
-		}
-
-		gameStats[gameID] = stats
-	}
-
-	return gameStats
+// NHLTrendLensSyncResult summarizes an NHL TrendLens index refresh
+type NHLTrendLensSyncResult struct {
+	Status       string `json:"status"`
+	Index        string `json:"index"`
+	PlayerCount  int    `json:"playerCount"`
+	GameCount    int    `json:"gameCount"`
+	RecordsSaved int    `json:"recordsSaved"`
+	TaskID       int64  `json:"taskID"`
+	Date         string `json:"date"`
+	// Diff is set on dry runs in place of saving
+	Diff *searchindex.DiffResult `json:"diff,omitempty"`
 }
 
 // Main NHL Trend Lens handler for local CSV data
 func NHLTrendLensHandler(c echo.Context) error {
-	// Open and read CSV file
-	file, err := os.Open("data/march3.csv") // Using specific file as requested
+	allShots, err := datasetShots(c)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to open CSV file"})
+		return shotLoadError(c, err)
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	shots, filter, err := filterShotsFromQuery(c, allShots)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to parse CSV"})
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
 	}
 
-	if len(records) < 2 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV file is empty or invalid"})
+	// Only the default dataset's latest season, unfiltered, feeds the live
+	// index, the same documents the scheduled sync writes. Uploads and
+	// other filters can preview their documents but never replace them.
+	dataset := strings.TrimSpace(c.QueryParam("dataset"))
+	dryRun := c.QueryParam("dryRun") == "true" ||
+		(dataset != "" && dataset != defaultDatasetID) ||
+		filter != (ShotFilter{Season: latestSeason(allShots)})
+	result, err := syncNHLTrendLens(c.Request().Context(), shots, filter, dryRun)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+	return c.JSON(http.StatusOK, result)
+}
 
-	// Get headers from the first row
-	headers := records[0]
-
-	// Check required columns
-	eventIdx := findColumnIndex(headers, "event")
-	timeIdx := findColumnIndex(headers, "time")
-	teamIdx := findColumnIndex(headers, "teamCode")
-	seasonIdx := findColumnIndex(headers, "season")
-	gameIDIdx := findColumnIndex(headers, "game_id")
-
-	if eventIdx == -1 || timeIdx == -1 || teamIdx == -1 || seasonIdx == -1 || gameIDIdx == -1 {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "CSV does not contain required columns"})
+// SyncNHLTrendLens pushes player documents for the latest season in the
+// shot data to the NHL TrendLens search index
+func SyncNHLTrendLens(ctx context.Context) (*NHLTrendLensSyncResult, error) {
+	allShots, err := loadShots("")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to load shot data")
 	}
 
-	// Process CSV data into shot data
-	shotData, err := processCSVToShotData(records, headers)
+	filter := ShotFilter{Season: latestSeason(allShots)}
+	shots, err := filter.Apply(allShots)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Error processing shot data: %v", err),
-		})
+		return nil, err
 	}
+	return syncNHLTrendLens(ctx, shots, filter, false)
+}
+
+// syncNHLTrendLens builds and saves the player documents for shots. With
+// dryRun set nothing is written; the result carries a diff against the
+// documents already in the index.
+func syncNHLTrendLens(ctx context.Context, shots []ShotData, filter ShotFilter, dryRun bool) (*NHLTrendLensSyncResult, error) {
+	// Only shots with player attribution feed the player documents
+	shotData := playerShotRows(shots)
 	debugShotData(shotData)
 	// Calculate date thresholds for recent data
 	monthAgo := time.Now().AddDate(0, 0, -30) // Filter for last two weeks
@@ -695,29 +743,24 @@ func NHLTrendLensHandler(c echo.Context) error {
 	playerStats := aggregatePlayerStats(shotData)
 	recentPlayerStats := aggregatePlayerStats(recentShotData)
 
+	// Ice time comes from the TOI file. On-ice numbers need every shot,
+	// not just the ones with a shooter.
+	toi, _, err := playerTOI(shots)
+	if err != nil && !errors.Is(err, ErrNoTOIData) {
+		return nil, err
+	}
+	applyPlayerTOI(playerStats, toi)
+
 	// Aggregate game stats
 	gameStats := aggregateGameStats(shotData)
 
-	// Initialize Algolia client
-	algoliaAppID := os.Getenv("ALGOLIA_APP_ID")
-	algoliaAPIKey := os.Getenv("ALGOLIA_API_KEY")
-	algoliaIndexName := os.Getenv("ALGOLIA_NHL_INDEX_NAME")
-
-	if algoliaAppID == "" || algoliaAPIKey == "" || algoliaIndexName == "" {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": "Missing Algolia credentials",
-		})
-	}
-
-	client, err := search.NewClient(algoliaAppID, algoliaAPIKey)
+	indexer, err := searchindex.Open(searchindex.IndexName(os.Getenv("ALGOLIA_NHL_INDEX_NAME"), "nhl-trendlens"))
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Failed to initialize Algolia client: %v", err),
-		})
+		return nil, err
 	}
 
-	// Create batch requests for players
-	var batchRequests []search.BatchRequest
+	// Build a document per player
+	var docs []searchindex.Document
 
 	// Helper function to round to one decimal
 	roundToOneDecimal := func(val float64) float64 {
@@ -730,81 +773,87 @@ func NHLTrendLensHandler(c echo.Context) error {
 		if stats.ShotsAttempted < 5 {
 			continue
 		}
+		if !filter.includesTeam(stats.TeamCode) {
+			continue
+		}
 
 		// Calculate advanced metrics
 		metrics := calculateAdvancedMetrics(stats)
 
 		// Basic player info and stats
-		batchBody := map[string]interface{}{
-			"objectID":    fmt.Sprintf("nhl_player_%s", playerID),
-			"playerID":    playerID,
-			"playerName":  stats.PlayerName,
-			"position":    stats.Position,
-			"teamCode":    stats.TeamCode,
-			"gamesPlayed": stats.GamesPlayed,
+		doc := NHLPlayerDocument{
+			ObjectID:      fmt.Sprintf("nhl_player_%s", playerID),
+			SchemaVersion: NHLPlayerDocumentSchemaVersion,
+			PlayerID:      playerID,
+			PlayerName:    stats.PlayerName,
+			Position:      stats.Position,
+			TeamCode:      stats.TeamCode,
+			GamesPlayed:   stats.GamesPlayed,
 
 			// Shooting stats
-			"shotsAttempted":     stats.ShotsAttempted,
-			"shotsOnGoal":        stats.ShotsOnGoal,
-			"goals":              stats.Goals,
-			"emptyNetGoals":      stats.EmptyNetGoals,
-			"shotsPerGame":       roundToOneDecimal(float64(stats.ShotsAttempted) / float64(stats.GamesPlayed)),
-			"shotsOnGoalPerGame": roundToOneDecimal(float64(stats.ShotsOnGoal) / float64(stats.GamesPlayed)),
-			"goalsPerGame":       roundToOneDecimal(float64(stats.Goals) / float64(stats.GamesPlayed)),
+			ShotsAttempted:     stats.ShotsAttempted,
+			ShotsOnGoal:        stats.ShotsOnGoal,
+			Goals:              stats.Goals,
+			EmptyNetGoals:      stats.EmptyNetGoals,
+			ShotsPerGame:       roundToOneDecimal(float64(stats.ShotsAttempted) / float64(stats.GamesPlayed)),
+			ShotsOnGoalPerGame: roundToOneDecimal(float64(stats.ShotsOnGoal) / float64(stats.GamesPlayed)),
+			GoalsPerGame:       roundToOneDecimal(float64(stats.Goals) / float64(stats.GamesPlayed)),
 
 			// Shot quality metrics
-			"averageDistance": roundToOneDecimal(stats.AverageDistance),
-			"averageAngle":    roundToOneDecimal(stats.AverageAngle),
-			"totalXGoals":     roundToOneDecimal(stats.TotalXGoals),
-			"xGoalsPerGame":   roundToOneDecimal(stats.TotalXGoals / float64(stats.GamesPlayed)),
+			AverageDistance: roundToOneDecimal(stats.AverageDistance),
+			AverageAngle:    roundToOneDecimal(stats.AverageAngle),
+			TotalXGoals:     roundToOneDecimal(stats.TotalXGoals),
+			XGoalsPerGame:   roundToOneDecimal(stats.TotalXGoals / float64(stats.GamesPlayed)),
 
 			// Shooting percentages
-			"shootingPct":   metrics["shootingPct"],
-			"shotOnGoalPct": metrics["shotOnGoalPct"],
-			"xGoalsPerShot": metrics["xGoalsPerShot"],
+			ShootingPct:   metrics["shootingPct"],
+			ShotOnGoalPct: metrics["shotOnGoalPct"],
+			XGoalsPerShot: metrics["xGoalsPerShot"],
 
 			// Special situations
-			"rushShots":         stats.RushShots,
-			"rushShotPct":       roundToOneDecimal(float64(stats.RushShots) / float64(stats.ShotsAttempted) * 100),
-			"highDangerShots":   stats.HighDangerShots,
-			"highDangerGoals":   stats.HighDangerGoals,
-			"highDangerShotPct": metrics["highDangerShotPct"],
-			"highDangerGoalPct": metrics["highDangerGoalPct"],
-			"powerPlayShots":    stats.PowerPlayShots,
-			"powerPlayGoals":    stats.PowerPlayGoals,
-			"powerPlayShotPct":  metrics["powerPlayShootingPct"],
+			RushShots:         stats.RushShots,
+			RushShotPct:       roundToOneDecimal(float64(stats.RushShots) / float64(stats.ShotsAttempted) * 100),
+			HighDangerShots:   stats.HighDangerShots,
+			HighDangerGoals:   stats.HighDangerGoals,
+			HighDangerShotPct: metrics["highDangerShotPct"],
+			HighDangerGoalPct: metrics["highDangerGoalPct"],
+			PowerPlayShots:    stats.PowerPlayShots,
+			PowerPlayGoals:    stats.PowerPlayGoals,
+			PowerPlayShotPct:  metrics["powerPlayShootingPct"],
 
 			// Advanced metrics
-			"goalsAboveExpected":      metrics["goalsAboveExpected"],
-			"hockeyCardRating":        metrics["hockeyCardRating"],
-			"hockeyCardRatingPerGame": metrics["hockeyCardRatingPerGame"],
+			GoalsAboveExpected:      metrics["goalsAboveExpected"],
+			HockeyCardRating:        metrics["hockeyCardRating"],
+			HockeyCardRatingPerGame: metrics["hockeyCardRatingPerGame"],
 
 			// Metadata
-			"lastUpdated": time.Now().Format(time.RFC3339),
+			LastUpdated: time.Now().Format(time.RFC3339),
+
+			NHLRateStats: playerRates(stats, toi[playerID]),
 		}
 
 		// Add recent period stats if they exist
 		if recentStats, exists := recentPlayerStats[playerID]; exists && recentStats.GamesPlayed > 0 {
 			recentMetrics := calculateAdvancedMetrics(recentStats)
 
-			recentStatsMap := map[string]interface{}{
-				"recentGamesPlayed":        recentStats.GamesPlayed,
-				"recentShotsAttempted":     recentStats.ShotsAttempted,
-				"recentShotsOnGoal":        recentStats.ShotsOnGoal,
-				"recentGoals":              recentStats.Goals,
-				"recentShotsPerGame":       roundToOneDecimal(float64(recentStats.ShotsAttempted) / float64(recentStats.GamesPlayed)),
-				"recentShotsOnGoalPerGame": roundToOneDecimal(float64(recentStats.ShotsOnGoal) / float64(recentStats.GamesPlayed)),
-				"recentGoalsPerGame":       roundToOneDecimal(float64(recentStats.Goals) / float64(recentStats.GamesPlayed)),
-				"recentShootingPct":        recentMetrics["shootingPct"],
-				"recentXGoals":             roundToOneDecimal(recentStats.TotalXGoals),
-				"recentXGoalsPerGame":      roundToOneDecimal(recentStats.TotalXGoals / float64(recentStats.GamesPlayed)),
-				"recentGoalsAboveExpected": recentMetrics["goalsAboveExpected"],
-				"recentHighDangerGoals":    recentStats.HighDangerGoals,
-				"recentHighDangerShots":    recentStats.HighDangerShots,
-				"recentPowerPlayGoals":     recentStats.PowerPlayGoals,
-				"recentHockeyCardRating":   recentMetrics["hockeyCardRating"],
-				"recentHighDangerShotPct":  recentMetrics["highDangerShotPct"],
-				"recentHighDangerGoalPct":  recentMetrics["highDangerGoalPct"],
+			recent := &NHLRecentStats{
+				RecentGamesPlayed:        recentStats.GamesPlayed,
+				RecentShotsAttempted:     recentStats.ShotsAttempted,
+				RecentShotsOnGoal:        recentStats.ShotsOnGoal,
+				RecentGoals:              recentStats.Goals,
+				RecentShotsPerGame:       roundToOneDecimal(float64(recentStats.ShotsAttempted) / float64(recentStats.GamesPlayed)),
+				RecentShotsOnGoalPerGame: roundToOneDecimal(float64(recentStats.ShotsOnGoal) / float64(recentStats.GamesPlayed)),
+				RecentGoalsPerGame:       roundToOneDecimal(float64(recentStats.Goals) / float64(recentStats.GamesPlayed)),
+				RecentShootingPct:        recentMetrics["shootingPct"],
+				RecentXGoals:             roundToOneDecimal(recentStats.TotalXGoals),
+				RecentXGoalsPerGame:      roundToOneDecimal(recentStats.TotalXGoals / float64(recentStats.GamesPlayed)),
+				RecentGoalsAboveExpected: recentMetrics["goalsAboveExpected"],
+				RecentHighDangerGoals:    recentStats.HighDangerGoals,
+				RecentHighDangerShots:    recentStats.HighDangerShots,
+				RecentPowerPlayGoals:     recentStats.PowerPlayGoals,
+				RecentHockeyCardRating:   recentMetrics["hockeyCardRating"],
+				RecentHighDangerShotPct:  recentMetrics["highDangerShotPct"],
+				RecentHighDangerGoalPct:  recentMetrics["highDangerGoalPct"],
 			}
 
 			// Add trend indicators (comparing recent to overall performance)
@@ -823,16 +872,25 @@ func NHLTrendLensHandler(c echo.Context) error {
 				xGoalsTrend := (recentStats.TotalXGoals / float64(recentStats.GamesPlayed)) -
 					((stats.TotalXGoals - recentStats.TotalXGoals) / float64(stats.GamesPlayed-recentStats.GamesPlayed))
 
-				recentStatsMap["goalsScoringTrend"] = roundToOneDecimal(goalsScoringTrend)
-				recentStatsMap["shootingPctTrend"] = roundToOneDecimal(shootingPctTrend)
-				recentStatsMap["xGoalsTrend"] = roundToOneDecimal(xGoalsTrend)
+				// Add hockey card rating trend
+				hockeyCardTrend := recentMetrics["hockeyCardRatingPerGame"] -
+					((metrics["hockeyCardRating"] - recentMetrics["hockeyCardRating"]) /
+						float64(stats.GamesPlayed-recentStats.GamesPlayed))
+
+				trends := &NHLTrends{
+					GoalsScoringTrend: roundToOneDecimal(goalsScoringTrend),
+					ShootingPctTrend:  roundToOneDecimal(shootingPctTrend),
+					XGoalsTrend:       roundToOneDecimal(xGoalsTrend),
+					HockeyCardTrend:   roundToOneDecimal(hockeyCardTrend),
+				}
 
 				// Add high danger shooting trends
 				if recentStats.HighDangerShots > 0 && (stats.HighDangerShots-recentStats.HighDangerShots) > 0 {
 					highDangerScoringTrend := (float64(recentStats.HighDangerGoals) / float64(recentStats.HighDangerShots)) -
 						(float64(stats.HighDangerGoals-recentStats.HighDangerGoals) /
 							float64(stats.HighDangerShots-recentStats.HighDangerShots))
-					recentStatsMap["recentHighDangerScoringTrend"] = roundToOneDecimal(highDangerScoringTrend * 100)
+					trend := roundToOneDecimal(highDangerScoringTrend * 100)
+					trends.RecentHighDangerScoringTrend = &trend
 				}
 
 				// Add rush shot trends
@@ -840,7 +898,8 @@ func NHLTrendLensHandler(c echo.Context) error {
 					rushShotPctTrend := (float64(recentStats.RushShots) / float64(recentStats.ShotsAttempted)) -
 						(float64(stats.RushShots-recentStats.RushShots) /
 							float64(stats.ShotsAttempted-recentStats.ShotsAttempted))
-					recentStatsMap["rushShotPctTrend"] = roundToOneDecimal(rushShotPctTrend * 100)
+					trend := roundToOneDecimal(rushShotPctTrend * 100)
+					trends.RushShotPctTrend = &trend
 				}
 
 				// Add power play trend
@@ -848,44 +907,50 @@ func NHLTrendLensHandler(c echo.Context) error {
 					ppGoalsTrend := (float64(recentStats.PowerPlayGoals) / float64(recentStats.PowerPlayShots)) -
 						(float64(stats.PowerPlayGoals-recentStats.PowerPlayGoals) /
 							float64(stats.PowerPlayShots-recentStats.PowerPlayShots))
-					recentStatsMap["powerPlayScoringTrend"] = roundToOneDecimal(ppGoalsTrend * 100)
+					trend := roundToOneDecimal(ppGoalsTrend * 100)
+					trends.PowerPlayScoringTrend = &trend
 				}
 
-				// Add hockey card rating trend
-				hockeyCardTrend := recentMetrics["hockeyCardRatingPerGame"] -
-					((metrics["hockeyCardRating"] - recentMetrics["hockeyCardRating"]) /
-						float64(stats.GamesPlayed-recentStats.GamesPlayed))
-				recentStatsMap["hockeyCardTrend"] = roundToOneDecimal(hockeyCardTrend)
+				recent.NHLTrends = trends
 			}
 
-			// Add recent stats to batch body
-			for k, v := range recentStatsMap {
-				batchBody[k] = v
-			}
+			doc.NHLRecentStats = recent
+		}
+
+		indexDoc, err := searchindex.ToDocument(doc)
+		if err != nil {
+			return nil, err
 		}
+		docs = append(docs, indexDoc)
+	}
 
-		batchRequests = append(batchRequests, *search.NewEmptyBatchRequest().
-			SetAction(search.Action("updateObject")).
-			SetBody(batchBody))
+	if dryRun {
+		diff, err := searchindex.Diff(ctx, indexer, docs, "lastUpdated")
+		if err != nil {
+			return nil, err
+		}
+		return &NHLTrendLensSyncResult{
+			Status:      "dry-run",
+			Index:       indexer.Name(),
+			PlayerCount: len(playerStats),
+			GameCount:   len(gameStats),
+			Date:        time.Now().Format(time.RFC3339),
+			Diff:        diff,
+		}, nil
 	}
 
-	// Perform batch update
-	response, err := client.Batch(client.NewApiBatchRequest(
-		algoliaIndexName,
-		search.NewEmptyBatchWriteParams().SetRequests(batchRequests),
-	))
+	saved, err := indexer.Save(ctx, docs)
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, map[string]string{
-			"error": fmt.Sprintf("Failed to save to Algolia: %v", err),
-		})
-	}
-
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"status":       "success",
-		"playerCount":  len(playerStats),
-		"gameCount":    len(gameStats),
-		"recordsSaved": len(batchRequests),
-		"taskID":       response.TaskID,
-		"date":         time.Now().Format(time.RFC3339),
-	})
+		return nil, err
+	}
+
+	return &NHLTrendLensSyncResult{
+		Status:       "success",
+		Index:        indexer.Name(),
+		PlayerCount:  len(playerStats),
+		GameCount:    len(gameStats),
+		RecordsSaved: saved.Saved,
+		TaskID:       saved.TaskID,
+		Date:         time.Now().Format(time.RFC3339),
+	}, nil
 }
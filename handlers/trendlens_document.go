@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/KPWithCode/statpad2/searchindex"
+	"github.com/labstack/echo/v4"
+)
+
+// NHLPlayerDocumentSchemaVersion is bumped whenever a field is added,
+// removed or changes meaning, so consumers can tell which shape they have
+const NHLPlayerDocumentSchemaVersion = 2
+
+// NHLPlayerDocument is the NHL TrendLens search record for one skater
+type NHLPlayerDocument struct {
+	ObjectID      string `json:"objectID"`
+	SchemaVersion int    `json:"schemaVersion"`
+
+	PlayerID    string `json:"playerID"`
+	PlayerName  string `json:"playerName"`
+	Position    string `json:"position"`
+	TeamCode    string `json:"teamCode"`
+	GamesPlayed int    `json:"gamesPlayed"`
+
+	// Shooting stats
+	ShotsAttempted     int     `json:"shotsAttempted"`
+	ShotsOnGoal        int     `json:"shotsOnGoal"`
+	Goals              int     `json:"goals"`
+	EmptyNetGoals      int     `json:"emptyNetGoals"`
+	ShotsPerGame       float64 `json:"shotsPerGame"`
+	ShotsOnGoalPerGame float64 `json:"shotsOnGoalPerGame"`
+	GoalsPerGame       float64 `json:"goalsPerGame"`
+
+	// Shot quality metrics
+	AverageDistance float64 `json:"averageDistance"`
+	AverageAngle    float64 `json:"averageAngle"`
+	TotalXGoals     float64 `json:"totalXGoals"`
+	XGoalsPerGame   float64 `json:"xGoalsPerGame"`
+
+	// Shooting percentages
+	ShootingPct   float64 `json:"shootingPct" doc:"Goals per shot on goal, as a percentage"`
+	ShotOnGoalPct float64 `json:"shotOnGoalPct" doc:"Shots on goal per shot attempt, as a percentage"`
+	XGoalsPerShot float64 `json:"xGoalsPerShot"`
+
+	// Special situations
+	RushShots         int     `json:"rushShots"`
+	RushShotPct       float64 `json:"rushShotPct"`
+	HighDangerShots   int     `json:"highDangerShots"`
+	HighDangerGoals   int     `json:"highDangerGoals"`
+	HighDangerShotPct float64 `json:"highDangerShotPct" doc:"Share of shot attempts that were high danger"`
+	HighDangerGoalPct float64 `json:"highDangerGoalPct" doc:"Goals per high-danger shot, as a percentage"`
+	PowerPlayShots    int     `json:"powerPlayShots"`
+	PowerPlayGoals    int     `json:"powerPlayGoals"`
+	PowerPlayShotPct  float64 `json:"powerPlayShotPct" doc:"Goals per power-play shot, as a percentage"`
+
+	// Advanced metrics
+	GoalsAboveExpected      float64 `json:"goalsAboveExpected"`
+	HockeyCardRating        float64 `json:"hockeyCardRating" doc:"Goals + 0.5 x high-danger goals + 2 x goals above expected"`
+	HockeyCardRatingPerGame float64 `json:"hockeyCardRatingPerGame"`
+
+	LastUpdated string `json:"lastUpdated" doc:"RFC 3339 time the document was built"`
+
+	// Rate stats need the player's ice time; left out when the TOI file
+	// doesn't cover them
+	*NHLRateStats
+
+	// Recent stats cover the last month; left out when the player has no
+	// games in that window
+	*NHLRecentStats
+}
+
+// NHLRateStats are the time-on-ice fields of an NHLPlayerDocument: per-60
+// rates for the player's own shots, and everything that happened while
+// they were on the ice
+type NHLRateStats struct {
+	TimeOnIce        float64 `json:"timeOnIce" doc:"Minutes"`
+	TimeOnIcePerGame float64 `json:"timeOnIcePerGame" doc:"Minutes per game with ice time"`
+	ShotsPer60       float64 `json:"shotsPer60" doc:"Shot attempts per 60 minutes"`
+	ShotsOnGoalPer60 float64 `json:"shotsOnGoalPer60"`
+	GoalsPer60       float64 `json:"goalsPer60"`
+	IXGPer60         float64 `json:"ixgPer60" doc:"Individual expected goals per 60 minutes"`
+
+	OnIceGF       int     `json:"onIceGF"`
+	OnIceGA       int     `json:"onIceGA"`
+	OnIceXGF      float64 `json:"onIceXGF"`
+	OnIceXGA      float64 `json:"onIceXGA"`
+	OnIceXGFPct   float64 `json:"onIceXGFPct" doc:"Share of on-ice expected goals for, as a percentage"`
+	OnIceXGFPer60 float64 `json:"onIceXGFPer60"`
+	OnIceXGAPer60 float64 `json:"onIceXGAPer60"`
+	OnIceCFPct    float64 `json:"onIceCFPct" doc:"Share of on-ice shot attempts for, as a percentage"`
+}
+
+// NHLRecentStats are the last-month fields of an NHLPlayerDocument
+type NHLRecentStats struct {
+	RecentGamesPlayed        int     `json:"recentGamesPlayed"`
+	RecentShotsAttempted     int     `json:"recentShotsAttempted"`
+	RecentShotsOnGoal        int     `json:"recentShotsOnGoal"`
+	RecentGoals              int     `json:"recentGoals"`
+	RecentShotsPerGame       float64 `json:"recentShotsPerGame"`
+	RecentShotsOnGoalPerGame float64 `json:"recentShotsOnGoalPerGame"`
+	RecentGoalsPerGame       float64 `json:"recentGoalsPerGame"`
+	RecentShootingPct        float64 `json:"recentShootingPct"`
+	RecentXGoals             float64 `json:"recentXGoals"`
+	RecentXGoalsPerGame      float64 `json:"recentXGoalsPerGame"`
+	RecentGoalsAboveExpected float64 `json:"recentGoalsAboveExpected"`
+	RecentHighDangerGoals    int     `json:"recentHighDangerGoals"`
+	RecentHighDangerShots    int     `json:"recentHighDangerShots"`
+	RecentPowerPlayGoals     int     `json:"recentPowerPlayGoals"`
+	RecentHockeyCardRating   float64 `json:"recentHockeyCardRating"`
+	RecentHighDangerShotPct  float64 `json:"recentHighDangerShotPct"`
+	RecentHighDangerGoalPct  float64 `json:"recentHighDangerGoalPct"`
+
+	// Trends need games outside the recent window to compare against
+	*NHLTrends
+}
+
+// NHLTrends compare a player's recent form with the rest of their season.
+// Positive values mean the player is doing better lately.
+type NHLTrends struct {
+	GoalsScoringTrend float64 `json:"goalsScoringTrend" doc:"Recent minus earlier goals per game"`
+	ShootingPctTrend  float64 `json:"shootingPctTrend"`
+	XGoalsTrend       float64 `json:"xGoalsTrend"`
+	HockeyCardTrend   float64 `json:"hockeyCardTrend"`
+
+	// Set only when both periods have shots of the kind
+	RecentHighDangerScoringTrend *float64 `json:"recentHighDangerScoringTrend,omitempty"`
+	RushShotPctTrend             *float64 `json:"rushShotPctTrend,omitempty"`
+	PowerPlayScoringTrend        *float64 `json:"powerPlayScoringTrend,omitempty"`
+}
+
+// NHLTrendLensSchemaHandler serves the JSON Schema for NHLPlayerDocument
+func NHLTrendLensSchemaHandler(c echo.Context) error {
+	return c.JSON(http.StatusOK, searchindex.JSONSchema(
+		"NHLPlayerDocument", NHLPlayerDocumentSchemaVersion, NHLPlayerDocument{}))
+}
@@ -2,9 +2,9 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 
 	"github.com/joho/godotenv"
@@ -59,33 +59,21 @@ func GetUpcomingSports(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "API key is missing"})
 	}
 
-	// Construct the request URL
-	baseURL := "https://api.the-odds-api.com/v4/sports/upcoming/odds"
-	regions := "us"
-	markets := "h2h,spreads,totals"
-	oddsFormat := "american"
-	url := fmt.Sprintf(
-		"%s/?apiKey=%s&regions=%s&markets=%s&oddsFormat=%s",
-		baseURL, apiKey, regions, markets, oddsFormat,
-	)
-
-	// Make the API request
-	resp, err := http.Get(url)
+	// Fetch upcoming odds, served from the cache when recent enough
+	params := url.Values{}
+	params.Set("regions", "us")
+	params.Set("markets", "h2h,spreads,totals")
+	params.Set("oddsFormat", "american")
+
+	body, err := fetchOddsAPI(c.Request().Context(), apiKey, "/sports/upcoming/odds", params, oddsPricesTTL, oddsPricesStaleFor)
 	if err != nil {
 		log.Printf("Error making API request: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch events"})
 	}
-	defer resp.Body.Close()
-
-	// Check for a non-200 HTTP status code
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("API responded with status code: %d", resp.StatusCode)
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to fetch events"})
-	}
 
 	// Parse the response
 	var events []SportsEvent
-	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+	if err := json.Unmarshal(body, &events); err != nil {
 		log.Printf("Error parsing API response: %v", err)
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to parse events"})
 	}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/KPWithCode/statpad2/upstream"
+	"github.com/labstack/echo/v4"
+)
+
+// GetUpstreamMetrics reports request counts, retries and quota consumed for
+// each upstream API since startup
+func GetUpstreamMetrics(c echo.Context) error {
+	return c.JSON(http.StatusOK, upstream.AllStats())
+}
@@ -0,0 +1,643 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KPWithCode/statpad2/logistic"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	periodSeconds = 1200
+	// regulationSeconds is three 20 minute periods
+	regulationSeconds      = 3 * periodSeconds
+	regularSeasonOTSeconds = 300
+	// winProbBucketSeconds splits each period into 5 minute table rows
+	winProbBucketSeconds = 300
+	// maxWinProbDiff caps the goal differential in the table; bigger
+	// leads are all but decided
+	maxWinProbDiff = 3
+	// winProbLambda is the L2 penalty on the win probability fit
+	winProbLambda = 1.0
+)
+
+// Strength states in the win probability table, from the team's side
+const (
+	strengthEven        = "even"
+	strengthPowerPlay   = "powerPlay"
+	strengthShortHanded = "shortHanded"
+)
+
+var winProbStrengths = []string{strengthEven, strengthPowerPlay, strengthShortHanded}
+
+var winProbFeatures = []string{"home", "diff", "diffByTime", "skaterAdvantage", "skaterAdvantageByTime"}
+
+// winProbState is a game situation from one team's side
+type winProbState struct {
+	home bool
+	// period is 1-3, or 4 and up for overtime
+	period int
+	// secondsLeft is what's left in regulation, 0 in overtime
+	secondsLeft float64
+	diff        int
+	advantage   int
+}
+
+// features scales the differential and skater advantage by the time left:
+// a one-goal lead is worth more the less time there is to answer it
+func (s winProbState) features() []float64 {
+	urgency := 1 / math.Sqrt(s.secondsLeft/60+1)
+	return []float64{
+		boolToFloat(s.home),
+		float64(s.diff),
+		float64(s.diff) * urgency,
+		float64(s.advantage),
+		float64(s.advantage) * urgency,
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// WinProbModel is the fitted logistic model behind the table and the live
+// endpoint
+type WinProbModel struct {
+	Seasons  []string        `json:"seasons"`
+	Games    int             `json:"games"`
+	Samples  int             `json:"samples"`
+	Features []string        `json:"features"`
+	Fit      *logistic.Model `json:"fit"`
+}
+
+// predict returns the win probability for a state. Without a venue both
+// are averaged.
+func (m *WinProbModel) predict(s winProbState, venueKnown bool) float64 {
+	if venueKnown {
+		return m.Fit.Predict(s.features())
+	}
+	home, away := s, s
+	home.home, away.home = true, false
+	return (m.Fit.Predict(home.features()) + m.Fit.Predict(away.features())) / 2
+}
+
+// WinProbCell is one row of the table: how often teams in the situation
+// went on to win, next to the model's smoothed estimate
+type WinProbCell struct {
+	Period          int      `json:"period"` // 4 is overtime
+	TimeLeft        string   `json:"timeLeft"`
+	Diff            int      `json:"diff"`
+	Strength        string   `json:"strength"`
+	Samples         int      `json:"samples"`
+	Wins            int      `json:"wins"`
+	EmpiricalWinPct *float64 `json:"empiricalWinPct"`
+	ModelWinPct     float64  `json:"modelWinPct"`
+}
+
+// WinProbPoint is the home team's win probability after one event
+type WinProbPoint struct {
+	GameSeconds        int     `json:"gameSeconds"`
+	Period             int     `json:"period"`
+	TimeLeft           string  `json:"timeLeft"` // In the period
+	Event              string  `json:"event,omitempty"`
+	Team               string  `json:"team,omitempty"`
+	Shooter            string  `json:"shooter,omitempty"`
+	HomeGoals          int     `json:"homeGoals"`
+	AwayGoals          int     `json:"awayGoals"`
+	HomeSkaters        int     `json:"homeSkaters"`
+	AwaySkaters        int     `json:"awaySkaters"`
+	HomeWinProbability float64 `json:"homeWinProbability"`
+}
+
+// gameResult is a game's final score and winner
+type gameResult struct {
+	homeGoals, awayGoals int
+	homeWon              bool
+}
+
+// gameResults works out every game's winner from its final score, using
+// homeTeamWon for games decided in a shootout
+func gameResults(shots []ShotData) map[string]*gameResult {
+	results := make(map[string]*gameResult)
+	for _, shot := range shots {
+		key := gameKey(shot)
+		result, ok := results[key]
+		if !ok {
+			result = &gameResult{}
+			results[key] = result
+		}
+		homeGoals, awayGoals := shot.HomeTeamGoals, shot.AwayTeamGoals
+		if shot.Goal {
+			if shot.TeamCode == shot.HomeTeamCode {
+				homeGoals++
+			} else {
+				awayGoals++
+			}
+		}
+		result.homeGoals = max(result.homeGoals, homeGoals)
+		result.awayGoals = max(result.awayGoals, awayGoals)
+		result.homeWon = shot.HomeTeamWon
+	}
+
+	for _, result := range results {
+		if result.homeGoals != result.awayGoals {
+			result.homeWon = result.homeGoals > result.awayGoals
+		}
+	}
+	return results
+}
+
+// homeState returns the situation before a shot from the home team's side
+func homeState(shot ShotData) winProbState {
+	period := shot.Period
+	if period == 0 {
+		period = shot.GameSeconds/periodSeconds + 1
+	}
+	return winProbState{
+		home:        true,
+		period:      period,
+		secondsLeft: math.Max(float64(regulationSeconds-shot.GameSeconds), 0),
+		diff:        shot.HomeTeamGoals - shot.AwayTeamGoals,
+		advantage:   shot.HomeSkatersOnIce - shot.AwaySkatersOnIce,
+	}
+}
+
+// opponent returns the same situation from the other team's side
+func (s winProbState) opponent() winProbState {
+	s.home = !s.home
+	s.diff = -s.diff
+	s.advantage = -s.advantage
+	return s
+}
+
+// fitWinProbModel fits the model to every event in shots. Each event is a
+// sample from both teams' sides so the fit is symmetric.
+func fitWinProbModel(shots []ShotData) (*WinProbModel, error) {
+	results := gameResults(shots)
+	seasons := make(map[string]bool)
+
+	var rows [][]float64
+	var outcomes []bool
+	for _, shot := range shots {
+		if shot.HomeTeamCode == "" || shot.AwayTeamCode == "" {
+			continue
+		}
+		seasons[shot.Season] = true
+		state := homeState(shot)
+		homeWon := results[gameKey(shot)].homeWon
+		rows = append(rows, state.features(), state.opponent().features())
+		outcomes = append(outcomes, homeWon, !homeWon)
+	}
+
+	fit, err := logistic.Fit(rows, outcomes, winProbLambda)
+	if err != nil {
+		return nil, fmt.Errorf("can't fit win probability model: %v", err)
+	}
+	return &WinProbModel{
+		Seasons:  sortedKeys(seasons),
+		Games:    len(results),
+		Samples:  len(rows) / 2,
+		Features: winProbFeatures,
+		Fit:      fit,
+	}, nil
+}
+
+// maxWinProbModels bounds how many fitted models are kept in memory
+const maxWinProbModels = 32
+
+// winProbModelKey identifies a fit: the dataset file as it was on disk and
+// the filters applied to it
+type winProbModelKey struct {
+	path    string
+	modTime time.Time
+	size    int64
+	filter  ShotFilter
+}
+
+var (
+	winProbModelsMu sync.Mutex
+	winProbModels   = make(map[winProbModelKey]*WinProbModel)
+)
+
+// winProbModelFor returns the model for shots, the request's dataset
+// filtered by filter. Fits are kept until the dataset file changes, the
+// way the xG model is, so live requests don't refit on every call.
+func winProbModelFor(c echo.Context, shots []ShotData, filter ShotFilter) (*WinProbModel, error) {
+	path, err := datasetPath(strings.TrimSpace(c.QueryParam("dataset")))
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+	key := winProbModelKey{path: path, modTime: info.ModTime(), size: info.Size(), filter: filter}
+
+	winProbModelsMu.Lock()
+	model, ok := winProbModels[key]
+	winProbModelsMu.Unlock()
+	if ok {
+		return model, nil
+	}
+
+	model, err = fitWinProbModel(shots)
+	if err != nil {
+		return nil, err
+	}
+
+	winProbModelsMu.Lock()
+	defer winProbModelsMu.Unlock()
+	if len(winProbModels) >= maxWinProbModels {
+		// Any entry will do; most are for a file that has since changed
+		for stale := range winProbModels {
+			delete(winProbModels, stale)
+			break
+		}
+	}
+	winProbModels[key] = model
+	return model, nil
+}
+
+// WinProbHandler returns the win probability for a live game situation.
+//
+// Query params: period (1-3, or 4/OT), timeLeft (left in the period, as
+// seconds or m:ss), diff (the team's goals minus the opponent's), strength
+// (5v4, 4v5, 5v5 and so on from the team's side, default even), home
+// (true or false; omitted averages both venues), and the shared shot
+// filters that choose what the model is fit to.
+func WinProbHandler(c echo.Context) error {
+	state, venueKnown, err := winProbStateFromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	model, err := winProbModelFor(c, shots, filter)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"winProbability": round3(model.predict(state, venueKnown)),
+		"model":          model,
+	})
+}
+
+func winProbStateFromQuery(c echo.Context) (winProbState, bool, error) {
+	var state winProbState
+
+	period := strings.ToUpper(c.QueryParam("period"))
+	switch period {
+	case "":
+		return state, false, fmt.Errorf("period is required")
+	case "OT":
+		state.period = 4
+	default:
+		p, err := strconv.Atoi(period)
+		if err != nil || p < 1 {
+			return state, false, fmt.Errorf("invalid period %q, expected 1, 2, 3 or OT", period)
+		}
+		state.period = p
+	}
+
+	left := periodSeconds
+	if value := c.QueryParam("timeLeft"); value != "" {
+		seconds, err := parseClock(value)
+		if err != nil || seconds > periodSeconds {
+			return state, false, fmt.Errorf("invalid timeLeft %q, expected seconds or m:ss up to 20:00", value)
+		}
+		left = seconds
+	}
+	if state.period <= 3 {
+		state.secondsLeft = float64((3-state.period)*periodSeconds + left)
+	}
+
+	diff := c.QueryParam("diff")
+	if diff == "" {
+		return state, false, fmt.Errorf("diff is required")
+	}
+	d, err := strconv.Atoi(diff)
+	if err != nil {
+		return state, false, fmt.Errorf("invalid diff %q", diff)
+	}
+	state.diff = d
+
+	if state.advantage, err = parseStrength(c.QueryParam("strength")); err != nil {
+		return state, false, err
+	}
+
+	venueKnown := c.QueryParam("home") != ""
+	state.home = parseBool(c.QueryParam("home"), false)
+	return state, venueKnown, nil
+}
+
+// parseClock reads seconds or m:ss, neither of which can be negative
+func parseClock(value string) (int, error) {
+	if minutes, seconds, ok := strings.Cut(value, ":"); ok {
+		m, err := strconv.Atoi(minutes)
+		if err != nil || m < 0 {
+			return 0, fmt.Errorf("invalid minutes")
+		}
+		s, err := strconv.Atoi(seconds)
+		if err != nil || s < 0 || s >= 60 {
+			return 0, fmt.Errorf("invalid seconds")
+		}
+		return m*60 + s, nil
+	}
+	s, err := strconv.Atoi(value)
+	if err != nil || s < 0 {
+		return 0, fmt.Errorf("invalid seconds")
+	}
+	return s, nil
+}
+
+// parseStrength returns the skater advantage for "5v4" style strengths, or
+// even, powerPlay (pp) and shortHanded (pk, sh)
+func parseStrength(value string) (int, error) {
+	switch strings.ToLower(value) {
+	case "", "even", "ev":
+		return 0, nil
+	case "powerplay", "pp":
+		return 1, nil
+	case "shorthanded", "pk", "sh":
+		return -1, nil
+	}
+	skaters, opponents, ok := strings.Cut(strings.ToLower(value), "v")
+	if ok {
+		a, errA := strconv.Atoi(skaters)
+		b, errB := strconv.Atoi(opponents)
+		if errA == nil && errB == nil && a >= 3 && a <= 6 && b >= 3 && b <= 6 {
+			return a - b, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid strength %q, expected something like 5v5, 5v4 or 4v5", value)
+}
+
+// WinProbTableHandler returns the league-wide win probability by period,
+// time left, goal differential and strength, empirical next to smoothed.
+// Query params: the shared shot filters.
+func WinProbTableHandler(c echo.Context) error {
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	model, err := winProbModelFor(c, shots, filter)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	}
+
+	type cellKey struct {
+		period, bucket, diff int
+		strength             string
+	}
+	counts := make(map[cellKey]*WinProbCell)
+	results := gameResults(shots)
+
+	for _, shot := range shots {
+		if shot.HomeTeamCode == "" || shot.AwayTeamCode == "" {
+			continue
+		}
+		homeWon := results[gameKey(shot)].homeWon
+		home := homeState(shot)
+		for _, side := range []struct {
+			state winProbState
+			won   bool
+		}{{home, homeWon}, {home.opponent(), !homeWon}} {
+			key := cellKey{
+				period:   min(side.state.period, 4),
+				bucket:   winProbBucket(shot, side.state.period),
+				diff:     max(-maxWinProbDiff, min(maxWinProbDiff, side.state.diff)),
+				strength: strengthName(side.state.advantage),
+			}
+			cell, ok := counts[key]
+			if !ok {
+				cell = &WinProbCell{}
+				counts[key] = cell
+			}
+			cell.Samples++
+			if side.won {
+				cell.Wins++
+			}
+		}
+	}
+
+	var table []WinProbCell
+	for period := 1; period <= 4; period++ {
+		buckets := periodSeconds / winProbBucketSeconds
+		diffs := []int{}
+		for d := -maxWinProbDiff; d <= maxWinProbDiff; d++ {
+			diffs = append(diffs, d)
+		}
+		if period == 4 {
+			// Overtime is sudden death, so it's always tied
+			buckets, diffs = 1, []int{0}
+		}
+
+		for bucket := 0; bucket < buckets; bucket++ {
+			for _, diff := range diffs {
+				for _, strength := range winProbStrengths {
+					cell := WinProbCell{
+						Period:   period,
+						TimeLeft: "OT",
+						Diff:     diff,
+						Strength: strength,
+					}
+					state := winProbState{period: period, diff: diff, advantage: strengthAdvantage(strength)}
+					if period <= 3 {
+						// Evaluate the model at the middle of the bucket
+						upper := periodSeconds - bucket*winProbBucketSeconds
+						cell.TimeLeft = fmt.Sprintf("%s-%s", formatClock(upper), formatClock(upper-winProbBucketSeconds))
+						state.secondsLeft = float64((3-period)*periodSeconds + upper - winProbBucketSeconds/2)
+					}
+					cell.ModelWinPct = round1(model.predict(state, false) * 100)
+
+					if counted, ok := counts[cellKey{period, bucket, diff, strength}]; ok {
+						cell.Samples = counted.Samples
+						cell.Wins = counted.Wins
+						pct := round1(float64(counted.Wins) / float64(counted.Samples) * 100)
+						cell.EmpiricalWinPct = &pct
+					}
+					table = append(table, cell)
+				}
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"table": table,
+		"model": model,
+		"metadata": map[string]interface{}{
+			"samples":  "Every shot attempt is a sample from both teams' sides",
+			"diff":     fmt.Sprintf("Goal differential from the team's side, capped at +/-%d", maxWinProbDiff),
+			"strength": "Skater advantage from the team's side",
+			"model":    "Logistic regression on venue, differential and skater advantage, the last two also scaled by 1/sqrt(minutes left + 1)",
+		},
+	})
+}
+
+// winProbBucket returns which 5 minute slice of its period a shot is in,
+// counting from the start of the period. Overtime is one bucket.
+func winProbBucket(shot ShotData, period int) int {
+	if period > 3 {
+		return 0
+	}
+	elapsed := shot.GameSeconds - (period-1)*periodSeconds
+	bucket := elapsed / winProbBucketSeconds
+	return max(0, min(bucket, periodSeconds/winProbBucketSeconds-1))
+}
+
+func strengthName(advantage int) string {
+	switch {
+	case advantage > 0:
+		return strengthPowerPlay
+	case advantage < 0:
+		return strengthShortHanded
+	default:
+		return strengthEven
+	}
+}
+
+func strengthAdvantage(strength string) int {
+	switch strength {
+	case strengthPowerPlay:
+		return 1
+	case strengthShortHanded:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func formatClock(seconds int) string {
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// GameWinProbHandler reconstructs a game's win probability chart from its
+// events. The season filter picks which season's game ID is meant; the
+// model is fit to the same filtered shots.
+func GameWinProbHandler(c echo.Context) error {
+	gameID := c.Param("gameId")
+
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var events []ShotData
+	for _, shot := range shots {
+		if shot.GameID == gameID {
+			events = append(events, shot)
+		}
+	}
+	if len(events) == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "Game not found: " + gameID})
+	}
+	if seasons := len(gameResults(events)); seasons > 1 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("Game %s is in %d seasons, pick one with season", gameID, seasons),
+		})
+	}
+
+	model, err := winProbModelFor(c, shots, filter)
+	if err != nil {
+		return c.JSON(http.StatusUnprocessableEntity, map[string]string{"error": err.Error()})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].GameSeconds < events[j].GameSeconds
+	})
+	first := events[0]
+	result := gameResults(events)[gameKey(first)]
+
+	chart := []WinProbPoint{{
+		Period:             1,
+		TimeLeft:           formatClock(periodSeconds),
+		HomeSkaters:        5,
+		AwaySkaters:        5,
+		HomeWinProbability: round3(model.predict(winProbState{home: true, period: 1, secondsLeft: regulationSeconds}, true)),
+	}}
+	for _, event := range events {
+		// The score columns are before the shot; the chart shows after
+		homeGoals, awayGoals := event.HomeTeamGoals, event.AwayTeamGoals
+		if event.Goal {
+			if event.TeamCode == event.HomeTeamCode {
+				homeGoals++
+			} else {
+				awayGoals++
+			}
+		}
+		state := homeState(event)
+		state.diff = homeGoals - awayGoals
+
+		point := WinProbPoint{
+			GameSeconds:        event.GameSeconds,
+			Period:             state.period,
+			TimeLeft:           formatClock(periodTimeLeft(event, state.period)),
+			Event:              event.Event,
+			Team:               event.TeamCode,
+			Shooter:            event.ShooterName,
+			HomeGoals:          homeGoals,
+			AwayGoals:          awayGoals,
+			HomeSkaters:        event.HomeSkatersOnIce,
+			AwaySkaters:        event.AwaySkatersOnIce,
+			HomeWinProbability: round3(model.predict(state, true)),
+		}
+		if state.period > 3 && event.Goal {
+			// Overtime goals end the game
+			point.HomeWinProbability = boolToFloat(event.TeamCode == event.HomeTeamCode)
+		}
+		chart = append(chart, point)
+	}
+
+	final := chart[len(chart)-1]
+	final.Event, final.Team, final.Shooter = "END", "", ""
+	final.HomeGoals, final.AwayGoals = result.homeGoals, result.awayGoals
+	final.HomeWinProbability = boolToFloat(result.homeWon)
+	chart = append(chart, final)
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"gameId":   gameID,
+		"season":   first.Season,
+		"homeTeam": first.HomeTeamCode,
+		"awayTeam": first.AwayTeamCode,
+		"homeWon":  result.homeWon,
+		"chart":    chart,
+		"model":    model,
+	})
+}
+
+// periodTimeLeft returns the seconds left in the shot's period. Regular
+// season overtime is a single 5 minute period.
+func periodTimeLeft(shot ShotData, period int) int {
+	end := period * periodSeconds
+	if period > 3 && !shot.IsPlayoffGame {
+		end = regulationSeconds + regularSeasonOTSeconds
+	}
+	return max(end-shot.GameSeconds, 0)
+}
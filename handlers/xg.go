@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/KPWithCode/statpad2/xgmodel"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	xgModelMu sync.RWMutex
+	xgModel   *xgmodel.Model
+)
+
+// xgModelPath is where the trained model is stored, XG_MODEL_PATH or
+// data/models/xg.json
+func xgModelPath() string {
+	if path := os.Getenv("XG_MODEL_PATH"); path != "" {
+		return path
+	}
+	return "data/models/xg.json"
+}
+
+// currentXGModel returns the trained model, reading it from disk on first
+// use
+func currentXGModel() (*xgmodel.Model, error) {
+	xgModelMu.RLock()
+	model := xgModel
+	xgModelMu.RUnlock()
+	if model != nil {
+		return model, nil
+	}
+
+	xgModelMu.Lock()
+	defer xgModelMu.Unlock()
+	if xgModel != nil {
+		return xgModel, nil
+	}
+	model, err := xgmodel.Load(xgModelPath())
+	if err != nil {
+		return nil, err
+	}
+	xgModel = model
+	return model, nil
+}
+
+// xgShot converts a shot row to the model's input
+func xgShot(shot ShotData) xgmodel.Shot {
+	skaters, opponents := teamSkaters(shot)
+	return xgmodel.Shot{
+		Distance:        shot.ShotDistance,
+		Angle:           shot.ShotAngle,
+		ShotType:        shot.ShotType,
+		Rush:            shot.ShotRush,
+		Rebound:         shot.ShotRebound,
+		SkaterAdvantage: skaters - opponents,
+	}
+}
+
+// isXGShot reports whether a shot is one the model is trained on and
+// scores: an unblocked attempt at a goalie
+func isXGShot(shot ShotData) bool {
+	return !isBlockedShot(shot) && !shot.ShotOnEmptyNet
+}
+
+// fillXGoals scores shots with the trained model. It's used for files
+// without an xGoal column; without a trained model the rows are left at 0.
+func fillXGoals(shots []ShotData) {
+	model, err := currentXGModel()
+	if err != nil {
+		log.Printf("Shot file has no xGoal column and no xG model is available: %v", err)
+		return
+	}
+	for i := range shots {
+		if shots[i].ShotOnEmptyNet {
+			// No model needed for an empty net
+			if shots[i].Goal {
+				shots[i].XGoal = 1
+			}
+			continue
+		}
+		if !isBlockedShot(shots[i]) {
+			shots[i].XGoal = model.Score(xgShot(shots[i]))
+		}
+	}
+	log.Printf("Filled xGoal for %d shots from the in-repo xG model", len(shots))
+}
+
+// TrainXGModelHandler trains the expected-goals model from the shot data
+// and saves it.
+//
+// Query params: trainSeasons (comma separated, default every season but
+// the latest), holdout (season to calibrate on, default the latest, and
+// never one of trainSeasons; "none" calibrates in-sample) and lambda (L2
+// penalty, default 1).
+func TrainXGModelHandler(c echo.Context) error {
+	shots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+
+	seasonsInData := make(map[string]bool)
+	for _, shot := range shots {
+		seasonsInData[shot.Season] = true
+	}
+	latest := latestSeason(shots)
+
+	holdout := latest
+	if value := c.QueryParam("holdout"); value != "" {
+		if strings.EqualFold(value, "none") {
+			holdout = ""
+		} else if holdout, err = normalizeNHLSeason(value); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+	}
+
+	train := make(map[string]bool)
+	if value := c.QueryParam("trainSeasons"); value != "" {
+		for _, season := range strings.Split(value, ",") {
+			normalized, err := normalizeNHLSeason(strings.TrimSpace(season))
+			if err != nil {
+				return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+			}
+			train[normalized] = true
+		}
+		// Calibrating on a training season would pass in-sample numbers off
+		// as a holdout
+		if train[holdout] {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("trainSeasons includes the holdout season %s; pick another holdout or holdout=none", holdout),
+			})
+		}
+	} else {
+		for season := range seasonsInData {
+			if season != holdout {
+				train[season] = true
+			}
+		}
+		// With a single season there's nothing to hold out
+		if len(train) == 0 {
+			train[latest] = true
+			holdout = ""
+		}
+	}
+
+	lambda := xgmodel.DefaultLambda
+	if value := c.QueryParam("lambda"); value != "" {
+		lambda, err = strconv.ParseFloat(value, 64)
+		if err != nil || lambda < 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "lambda must be a non-negative number"})
+		}
+	}
+
+	var samples, holdoutSamples []xgmodel.Sample
+	for _, shot := range shots {
+		if !isXGShot(shot) {
+			continue
+		}
+		sample := xgmodel.Sample{Shot: xgShot(shot), Goal: shot.Goal}
+		if train[shot.Season] {
+			samples = append(samples, sample)
+		}
+		if holdout != "" && shot.Season == holdout {
+			holdoutSamples = append(holdoutSamples, sample)
+		}
+	}
+
+	model, err := xgmodel.Train(samples, lambda)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	model.Seasons = sortedKeys(train)
+
+	calibrationSeasons := []string{holdout}
+	if holdout == "" || len(holdoutSamples) == 0 {
+		// In-sample calibration is optimistic but better than none
+		holdoutSamples = samples
+		calibrationSeasons = model.Seasons
+	}
+	model.Calibration = calibrateSamples(model, holdoutSamples)
+	model.Calibration.Seasons = calibrationSeasons
+
+	if err := model.Save(xgModelPath()); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	xgModelMu.Lock()
+	xgModel = model
+	xgModelMu.Unlock()
+
+	return c.JSON(http.StatusOK, model)
+}
+
+// GetXGModelHandler returns the trained model with its calibration
+func GetXGModelHandler(c echo.Context) error {
+	model, err := currentXGModel()
+	if errors.Is(err, xgmodel.ErrNoModel) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, model)
+}
+
+// ScoreXGHandler scores shots with the trained model. GET scores one shot
+// from the distance, angle, shotType, rush, rebound and skaterAdvantage
+// query params; POST scores a JSON array of shots.
+func ScoreXGHandler(c echo.Context) error {
+	model, err := currentXGModel()
+	if errors.Is(err, xgmodel.ErrNoModel) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if c.Request().Method == http.MethodPost {
+		var shots []xgmodel.Shot
+		if err := c.Bind(&shots); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "Expected a JSON array of shots"})
+		}
+		xGoals := make([]float64, len(shots))
+		for i, shot := range shots {
+			xGoals[i] = round3(model.Score(shot))
+		}
+		return c.JSON(http.StatusOK, map[string]interface{}{"xGoals": xGoals})
+	}
+
+	shot, err := xgShotFromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"shot":  shot,
+		"xGoal": round3(model.Score(shot)),
+	})
+}
+
+func xgShotFromQuery(c echo.Context) (xgmodel.Shot, error) {
+	shot := xgmodel.Shot{ShotType: c.QueryParam("shotType")}
+
+	distance := c.QueryParam("distance")
+	if distance == "" {
+		return shot, fmt.Errorf("distance is required")
+	}
+	var err error
+	if shot.Distance, err = strconv.ParseFloat(distance, 64); err != nil || shot.Distance < 0 {
+		return shot, fmt.Errorf("invalid distance %q", distance)
+	}
+	if angle := c.QueryParam("angle"); angle != "" {
+		if shot.Angle, err = strconv.ParseFloat(angle, 64); err != nil {
+			return shot, fmt.Errorf("invalid angle %q", angle)
+		}
+	}
+	shot.Rush = parseBool(c.QueryParam("rush"), false)
+	shot.Rebound = parseBool(c.QueryParam("rebound"), false)
+	if advantage := c.QueryParam("skaterAdvantage"); advantage != "" {
+		if shot.SkaterAdvantage, err = strconv.Atoi(advantage); err != nil {
+			return shot, fmt.Errorf("invalid skaterAdvantage %q", advantage)
+		}
+	}
+	return shot, nil
+}
+
+// XGTeamComparison sets a team's goals beside both xG sources
+type XGTeamComparison struct {
+	Team         string  `json:"team"`
+	Goals        int     `json:"goals"`
+	ModelXGoals  float64 `json:"modelXGoals"`
+	VendorXGoals float64 `json:"vendorXGoals"`
+}
+
+// CompareXGHandler scores the filtered shots with the trained model and
+// compares the result with the vendor's xGoal column: calibration by
+// decile for each, and per-team totals.
+func CompareXGHandler(c echo.Context) error {
+	model, err := currentXGModel()
+	if errors.Is(err, xgmodel.ErrNoModel) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	allShots, err := datasetShots(c)
+	if err != nil {
+		return shotLoadError(c, err)
+	}
+	shots, filter, err := filterShotsFromQuery(c, allShots)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	var modelPredictions, vendorPredictions []float64
+	var goals []bool
+	teams := make(map[string]*XGTeamComparison)
+	for _, shot := range shots {
+		if !isXGShot(shot) {
+			continue
+		}
+		prediction := model.Score(xgShot(shot))
+		modelPredictions = append(modelPredictions, prediction)
+		vendorPredictions = append(vendorPredictions, shot.XGoal)
+		goals = append(goals, shot.Goal)
+
+		team, ok := teams[shot.TeamCode]
+		if !ok {
+			team = &XGTeamComparison{Team: shot.TeamCode}
+			teams[shot.TeamCode] = team
+		}
+		team.ModelXGoals += prediction
+		team.VendorXGoals += shot.XGoal
+		if shot.Goal {
+			team.Goals++
+		}
+	}
+
+	teamList := make([]XGTeamComparison, 0, len(teams))
+	for code, team := range teams {
+		if !filter.includesTeam(code) {
+			continue
+		}
+		team.ModelXGoals = round1(team.ModelXGoals)
+		team.VendorXGoals = round1(team.VendorXGoals)
+		teamList = append(teamList, *team)
+	}
+	sort.Slice(teamList, func(i, j int) bool {
+		return teamList[i].Team < teamList[j].Team
+	})
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"model":  xgmodel.Calibrate(modelPredictions, goals),
+		"vendor": xgmodel.Calibrate(vendorPredictions, goals),
+		"teams":  teamList,
+	})
+}
+
+func calibrateSamples(model *xgmodel.Model, samples []xgmodel.Sample) *xgmodel.Calibration {
+	predictions := make([]float64, len(samples))
+	goals := make([]bool, len(samples))
+	for i, sample := range samples {
+		predictions[i] = model.Score(sample.Shot)
+		goals[i] = sample.Goal
+	}
+	return xgmodel.Calibrate(predictions, goals)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,236 @@
+// Package jobs runs recurring background work (index refreshes, schedule
+// pulls, odds snapshots) on cron specs and keeps the outcome of each job's
+// last run for the /jobs endpoints.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Func does a job's work and returns the number of records it wrote
+type Func func(ctx context.Context) (int, error)
+
+// Errors returned by Trigger
+var (
+	ErrNotFound = errors.New("job not found")
+	ErrRunning  = errors.New("job is already running")
+)
+
+// runTimeout bounds a single run so a stuck upstream can't wedge a job
+const runTimeout = 10 * time.Minute
+
+// Status is a job's configuration and the outcome of its last run
+type Status struct {
+	Name        string     `json:"name"`
+	Spec        string     `json:"spec"`
+	Running     bool       `json:"running"`
+	Runs        int        `json:"runs"`
+	NextRun     *time.Time `json:"nextRun,omitempty"`
+	LastRun     *time.Time `json:"lastRun,omitempty"`
+	LastTrigger string     `json:"lastTrigger,omitempty"`
+	Duration    string     `json:"duration,omitempty"`
+	Records     int        `json:"records"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+type job struct {
+	name    string
+	spec    string
+	fn      Func
+	entryID cron.EntryID
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Scheduler runs registered jobs on their cron specs
+type Scheduler struct {
+	cron *cron.Cron
+
+	mu   sync.RWMutex
+	jobs map[string]*job
+}
+
+// New creates a scheduler. Jobs don't run until Start is called.
+func New() *Scheduler {
+	return &Scheduler{
+		cron: cron.New(),
+		jobs: make(map[string]*job),
+	}
+}
+
+var (
+	defaultOnce      sync.Once
+	defaultScheduler *Scheduler
+)
+
+// Default returns the process-wide scheduler
+func Default() *Scheduler {
+	defaultOnce.Do(func() {
+		defaultScheduler = New()
+	})
+	return defaultScheduler
+}
+
+// Register adds a job. defaultSpec is a standard five-field cron spec and
+// can be overridden with JOB_<NAME>_SPEC (nba-trendlens reads
+// JOB_NBA_TRENDLENS_SPEC). A spec of "off" leaves the job unscheduled, but
+// it can still be run on demand.
+func (s *Scheduler) Register(name, defaultSpec string, fn Func) error {
+	spec := defaultSpec
+	if override := os.Getenv(specEnvVar(name)); override != "" {
+		spec = override
+	}
+
+	j := &job{name: name, spec: spec, fn: fn}
+	j.status = Status{Name: name, Spec: spec}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.jobs[name]; exists {
+		return fmt.Errorf("job %s is already registered", name)
+	}
+
+	if !strings.EqualFold(spec, "off") {
+		id, err := s.cron.AddFunc(spec, func() { s.run(j, "schedule") })
+		if err != nil {
+			return fmt.Errorf("invalid spec %q for job %s: %v", spec, name, err)
+		}
+		j.entryID = id
+	}
+
+	s.jobs[name] = j
+	return nil
+}
+
+// Start begins running jobs on their schedules
+func (s *Scheduler) Start() {
+	s.cron.Start()
+}
+
+// Stop stops scheduling new runs and waits for running jobs to finish
+func (s *Scheduler) Stop() {
+	<-s.cron.Stop().Done()
+}
+
+// Trigger starts a run of the named job in the background
+func (s *Scheduler) Trigger(name string) (Status, error) {
+	s.mu.RLock()
+	j, ok := s.jobs[name]
+	s.mu.RUnlock()
+	if !ok {
+		return Status{}, ErrNotFound
+	}
+
+	j.mu.Lock()
+	if j.status.Running {
+		status := j.status
+		j.mu.Unlock()
+		return status, ErrRunning
+	}
+	// Mark it running now so the caller sees the run has started
+	j.status.Running = true
+	status := j.status
+	j.mu.Unlock()
+
+	go s.run(j, "manual")
+	return status, nil
+}
+
+// Statuses returns every job's status, sorted by name
+func (s *Scheduler) Statuses() []Status {
+	s.mu.RLock()
+	jobs := make([]*job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.RUnlock()
+
+	statuses := make([]Status, 0, len(jobs))
+	for _, j := range jobs {
+		statuses = append(statuses, s.statusOf(j))
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+func (s *Scheduler) statusOf(j *job) Status {
+	j.mu.Lock()
+	status := j.status
+	j.mu.Unlock()
+
+	if j.entryID != 0 {
+		if next := s.cron.Entry(j.entryID).Next; !next.IsZero() {
+			status.NextRun = &next
+		}
+	}
+	return status
+}
+
+// run executes j unless a run is already in progress. Scheduled runs claim
+// the job here; manual runs have already claimed it in Trigger.
+func (s *Scheduler) run(j *job, trigger string) {
+	j.mu.Lock()
+	if trigger != "manual" {
+		if j.status.Running {
+			j.mu.Unlock()
+			log.Printf("jobs: skipping scheduled run of %s, previous run still going", j.name)
+			return
+		}
+		j.status.Running = true
+	}
+	j.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	defer cancel()
+
+	start := time.Now()
+	records, err := call(ctx, j)
+	duration := time.Since(start)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status.Running = false
+	j.status.Runs++
+	j.status.LastRun = &start
+	j.status.LastTrigger = trigger
+	j.status.Duration = duration.Round(time.Millisecond).String()
+	j.status.Records = records
+	j.status.LastError = ""
+	if err != nil {
+		j.status.LastError = err.Error()
+		log.Printf("jobs: %s failed after %s: %v", j.name, j.status.Duration, err)
+		return
+	}
+	log.Printf("jobs: %s wrote %d records in %s", j.name, records, j.status.Duration)
+}
+
+// call runs j's function, turning a panic into an error so one bad job
+// can't take the server down or leave itself marked as running
+func call(ctx context.Context, j *job) (records int, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("jobs: %s panicked: %v\n%s", j.name, r, debug.Stack())
+			records, err = 0, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return j.fn(ctx)
+}
+
+// specEnvVar returns the environment variable overriding a job's spec
+func specEnvVar(name string) string {
+	return "JOB_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_SPEC"
+}
@@ -0,0 +1,200 @@
+// Package logistic fits L2-penalized logistic regressions with Newton's
+// method. It's shared by the models that predict a yes/no outcome from a
+// handful of features, like expected goals and win probability.
+package logistic
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	maxIterations = 50
+	tolerance     = 1e-7
+)
+
+// Model is a fitted regression. Inputs are standardized with Means and
+// Scales before the weights are applied.
+type Model struct {
+	Means     []float64 `json:"means"`
+	Scales    []float64 `json:"scales"`
+	Intercept float64   `json:"intercept"`
+	Weights   []float64 `json:"weights"`
+}
+
+// Predict returns the probability of the outcome for the feature row x
+func (m *Model) Predict(x []float64) float64 {
+	z := m.Intercept
+	for i, value := range x {
+		z += m.Weights[i] * (value - m.Means[i]) / m.Scales[i]
+	}
+	return Sigmoid(z)
+}
+
+// Sigmoid maps log-odds to a probability
+func Sigmoid(z float64) float64 {
+	return 1 / (1 + math.Exp(-z))
+}
+
+// Fit fits rows to outcomes with iteratively reweighted least squares and
+// an L2 penalty of lambda on the standardized weights. rows are not
+// modified.
+func Fit(rows [][]float64, outcomes []bool, lambda float64) (*Model, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows to fit")
+	}
+	if len(rows) != len(outcomes) {
+		return nil, fmt.Errorf("%d rows but %d outcomes", len(rows), len(outcomes))
+	}
+
+	positives := 0
+	for _, outcome := range outcomes {
+		if outcome {
+			positives++
+		}
+	}
+	if positives == 0 || positives == len(rows) {
+		return nil, fmt.Errorf("rows need both outcomes to fit")
+	}
+
+	k := len(rows[0])
+	n := float64(len(rows))
+
+	// Standardize so one penalty fits every feature
+	means := make([]float64, k)
+	for _, row := range rows {
+		for j, value := range row {
+			means[j] += value
+		}
+	}
+	for j := range means {
+		means[j] /= n
+	}
+
+	scales := make([]float64, k)
+	for _, row := range rows {
+		for j, value := range row {
+			scales[j] += (value - means[j]) * (value - means[j])
+		}
+	}
+	for j := range scales {
+		scales[j] = math.Sqrt(scales[j] / n)
+		if scales[j] == 0 {
+			// A constant feature contributes nothing; avoid dividing by 0
+			scales[j] = 1
+		}
+	}
+
+	standardized := make([][]float64, len(rows))
+	for i, row := range rows {
+		standardized[i] = make([]float64, k)
+		for j, value := range row {
+			standardized[i][j] = (value - means[j]) / scales[j]
+		}
+	}
+
+	// Parameters are the intercept followed by the weights
+	beta := make([]float64, k+1)
+	beta[0] = math.Log(float64(positives) / float64(len(rows)-positives))
+
+	for iteration := 0; iteration < maxIterations; iteration++ {
+		gradient := make([]float64, k+1)
+		hessian := make([][]float64, k+1)
+		for j := range hessian {
+			hessian[j] = make([]float64, k+1)
+		}
+
+		x := make([]float64, k+1)
+		x[0] = 1
+		for i, row := range standardized {
+			copy(x[1:], row)
+
+			z := 0.0
+			for j, value := range x {
+				z += beta[j] * value
+			}
+			p := Sigmoid(z)
+			y := 0.0
+			if outcomes[i] {
+				y = 1
+			}
+			w := p * (1 - p)
+
+			for a := range x {
+				gradient[a] += (y - p) * x[a]
+				for b := a; b < len(x); b++ {
+					hessian[a][b] += w * x[a] * x[b]
+				}
+			}
+		}
+
+		// Penalize the weights but not the intercept
+		for j := 1; j <= k; j++ {
+			gradient[j] -= lambda * beta[j]
+			hessian[j][j] += lambda
+		}
+		for a := range hessian {
+			for b := 0; b < a; b++ {
+				hessian[a][b] = hessian[b][a]
+			}
+		}
+
+		step, err := solve(hessian, gradient)
+		if err != nil {
+			return nil, err
+		}
+
+		largest := 0.0
+		for j := range beta {
+			beta[j] += step[j]
+			largest = math.Max(largest, math.Abs(step[j]))
+		}
+		if largest < tolerance {
+			break
+		}
+	}
+
+	return &Model{
+		Means:     means,
+		Scales:    scales,
+		Intercept: beta[0],
+		Weights:   beta[1:],
+	}, nil
+}
+
+// solve returns x for a x = b using Gaussian elimination with partial
+// pivoting. a and b are overwritten.
+func solve(a [][]float64, b []float64) ([]float64, error) {
+	n := len(b)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, fmt.Errorf("logistic fit failed: singular system")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		for row := col + 1; row < n; row++ {
+			factor := a[row][col] / a[col][col]
+			for j := col; j < n; j++ {
+				a[row][j] -= factor * a[col][j]
+			}
+			b[row] -= factor * b[col]
+		}
+	}
+
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := b[row]
+		for j := row + 1; j < n; j++ {
+			sum -= a[row][j] * x[j]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x, nil
+}
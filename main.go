@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
 
+	"github.com/KPWithCode/statpad2/handlers"
+	"github.com/KPWithCode/statpad2/handlers/nbahandler"
+	"github.com/KPWithCode/statpad2/jobs"
 	"github.com/KPWithCode/statpad2/routes"
+	mlb "github.com/KPWithCode/statpad2/routes/mlbroutes"
 	nba "github.com/KPWithCode/statpad2/routes/nbaroutes"
+	"github.com/KPWithCode/statpad2/seasons"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
@@ -14,6 +20,13 @@ func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Fatal("Error loading .env file")
 	}
+	// Parse the NHL shot file once up front; handlers share the parsed rows
+	handlers.LoadShotData()
+
+	scheduler := jobs.Default()
+	registerJobs(scheduler)
+	scheduler.Start()
+
 	e := echo.New()
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"http://www.localhost:3000", "http://192.168.1.155:3000", "exp://192.168.1.155:19000",
@@ -29,8 +42,55 @@ func main() {
 	routes.EventRoutes(e)
 	routes.AssistRoutes(e)
 	routes.GoalRoutes(e)
+	routes.NHLRoutes(e)
+	routes.DatasetRoutes(e)
+	routes.SeasonRoutes(e)
+	routes.MetricsRoutes(e)
+	routes.JobRoutes(e)
+	routes.SearchRoutes(e)
 	nba.NBARoutes(e)
+	mlb.MLBRoutes(e)
 
 	e.Logger.Fatal(e.Start(":8000"))
 
 }
+
+// registerJobs sets up the background jobs with their default schedules;
+// each can be overridden with JOB_<NAME>_SPEC
+func registerJobs(scheduler *jobs.Scheduler) {
+	register := func(name, spec string, fn jobs.Func) {
+		if err := scheduler.Register(name, spec, fn); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Refresh the TrendLens indexes every morning once last night's games
+	// are final
+	register("nba-trendlens", "0 9 * * *", func(ctx context.Context) (int, error) {
+		season, err := seasons.Current("nba")
+		if err != nil {
+			return 0, err
+		}
+		result, err := nbahandler.SyncTrendLens(ctx, season.Key, false)
+		if err != nil {
+			return 0, err
+		}
+		return result.RecordsSaved, nil
+	})
+	register("nhl-trendlens", "30 9 * * *", func(ctx context.Context) (int, error) {
+		result, err := handlers.SyncNHLTrendLens(ctx)
+		if err != nil {
+			return 0, err
+		}
+		return result.RecordsSaved, nil
+	})
+
+	// Cache last night's NHL play-by-play for the playmaking endpoints
+	register("nhl-playbyplay", "0 10 * * *", handlers.WarmNHLPlayByPlay)
+
+	// Keep today's schedule warm in the cache
+	register("nba-schedule", "*/30 * * * *", nbahandler.PullTodaysSchedule)
+
+	// Odds API credits are metered, so snapshot sparingly
+	register("odds-snapshot", "0 */4 * * *", handlers.SnapshotOdds)
+}
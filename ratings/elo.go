@@ -0,0 +1,278 @@
+// Package ratings rates NHL teams from game results: an Elo system with
+// margin of victory, home ice and rest adjustments, and ridge regression
+// offense and defense ratings from expected goals.
+package ratings
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// InitialElo is the rating a team starts at, and the league average
+const InitialElo = 1500.0
+
+// Game is a completed game, home team's side
+type Game struct {
+	Season    string
+	Date      time.Time // Zero when unknown; rest is then ignored
+	Home      string
+	Away      string
+	HomeGoals int
+	AwayGoals int
+	HomeWon   bool
+	HomeXG    float64
+	AwayXG    float64
+}
+
+// EloConfig are the tunable Elo parameters
+type EloConfig struct {
+	// K is the rating points at stake in a game before the margin of
+	// victory multiplier
+	K float64 `json:"k"`
+	// HomeAdvantage is added to the home team's rating when predicting
+	HomeAdvantage float64 `json:"homeAdvantage"`
+	// RestPerDay is the rating points per day of rest one team has over
+	// the other, up to MaxRestDays
+	RestPerDay  float64 `json:"restPerDay"`
+	MaxRestDays int     `json:"maxRestDays"`
+	// Carryover is the share of a team's distance from average kept
+	// between seasons
+	Carryover float64 `json:"carryover"`
+}
+
+// DefaultEloConfig is a reasonable NHL starting point before tuning
+var DefaultEloConfig = EloConfig{
+	K:             6,
+	HomeAdvantage: 50,
+	RestPerDay:    10,
+	MaxRestDays:   3,
+	Carryover:     0.7,
+}
+
+// Elo tracks team ratings through a run of games
+type Elo struct {
+	Config EloConfig
+
+	ratings    map[string]float64
+	lastPlayed map[string]time.Time
+	season     string
+}
+
+// NewElo starts every team at InitialElo
+func NewElo(config EloConfig) *Elo {
+	return &Elo{
+		Config:     config,
+		ratings:    make(map[string]float64),
+		lastPlayed: make(map[string]time.Time),
+	}
+}
+
+// Rating returns a team's current rating
+func (e *Elo) Rating(team string) float64 {
+	if rating, ok := e.ratings[team]; ok {
+		return rating
+	}
+	return InitialElo
+}
+
+// Ratings returns every rated team's current rating
+func (e *Elo) Ratings() map[string]float64 {
+	ratings := make(map[string]float64, len(e.ratings))
+	for team, rating := range e.ratings {
+		ratings[team] = rating
+	}
+	return ratings
+}
+
+// LastPlayed returns the date of a team's last game, zero if unknown
+func (e *Elo) LastPlayed(team string) time.Time {
+	return e.lastPlayed[team]
+}
+
+// Expected returns the probability a team rated diff points above its
+// opponent wins
+func Expected(diff float64) float64 {
+	return 1 / (1 + math.Pow(10, -diff/400))
+}
+
+// restDays returns the days since a team last played, capped at max. A
+// team with no previous game is treated as fully rested.
+func restDays(last, date time.Time, max int) int {
+	if last.IsZero() || date.IsZero() {
+		return max
+	}
+	days := int(date.Sub(last).Hours() / 24)
+	if days > max {
+		return max
+	}
+	return days
+}
+
+// Diff returns the home team's effective rating edge for a game on date,
+// with home ice and rest. homeRest and awayRest override the rest worked
+// out from previous games when not negative.
+func (e *Elo) Diff(home, away string, date time.Time, homeRest, awayRest int) float64 {
+	if homeRest < 0 {
+		homeRest = restDays(e.lastPlayed[home], date, e.Config.MaxRestDays)
+	}
+	if awayRest < 0 {
+		awayRest = restDays(e.lastPlayed[away], date, e.Config.MaxRestDays)
+	}
+	homeRest = min(homeRest, e.Config.MaxRestDays)
+	awayRest = min(awayRest, e.Config.MaxRestDays)
+
+	return e.Rating(home) - e.Rating(away) + e.Config.HomeAdvantage +
+		e.Config.RestPerDay*float64(homeRest-awayRest)
+}
+
+// Predict returns the home team's win probability
+func (e *Elo) Predict(home, away string, date time.Time, homeRest, awayRest int) float64 {
+	return Expected(e.Diff(home, away, date, homeRest, awayRest))
+}
+
+// movMultiplier scales K by the margin of victory, damped when the
+// favorite wins so strong teams' ratings don't run away
+func movMultiplier(margin int, winnerDiff float64) float64 {
+	if margin < 1 {
+		margin = 1
+	}
+	return (0.6686*math.Log(float64(margin)) + 0.8048) * 2.05 / (winnerDiff*0.001 + 2.05)
+}
+
+// Update rates a game and returns the home win probability it was
+// predicted at. Games must be fed in the order they were played.
+func (e *Elo) Update(game Game) float64 {
+	if game.Season != e.season {
+		if e.season != "" {
+			for team, rating := range e.ratings {
+				e.ratings[team] = InitialElo + e.Config.Carryover*(rating-InitialElo)
+			}
+		}
+		e.season = game.Season
+	}
+
+	diff := e.Diff(game.Home, game.Away, game.Date, -1, -1)
+	expected := Expected(diff)
+
+	actual, winnerDiff := 0.0, -diff
+	if game.HomeWon {
+		actual, winnerDiff = 1, diff
+	}
+	margin := game.HomeGoals - game.AwayGoals
+	if margin < 0 {
+		margin = -margin
+	}
+	shift := e.Config.K * movMultiplier(margin, winnerDiff) * (actual - expected)
+
+	e.ratings[game.Home] = e.Rating(game.Home) + shift
+	e.ratings[game.Away] = e.Rating(game.Away) - shift
+	if !game.Date.IsZero() {
+		e.lastPlayed[game.Home] = game.Date
+		e.lastPlayed[game.Away] = game.Date
+	}
+	return expected
+}
+
+// EloFit is how well a config predicted a run of games
+type EloFit struct {
+	Config  EloConfig `json:"config"`
+	Games   int       `json:"games"`
+	LogLoss float64   `json:"logLoss"`
+	Brier   float64   `json:"brier"`
+}
+
+// Evaluate runs games through a fresh Elo and scores its pre-game
+// predictions on the games in scored seasons. Earlier seasons only warm
+// the ratings up.
+func Evaluate(config EloConfig, games []Game, scored map[string]bool) (*Elo, EloFit) {
+	elo := NewElo(config)
+	fit := EloFit{Config: config}
+	for _, game := range games {
+		p := elo.Update(game)
+		if !scored[game.Season] {
+			continue
+		}
+		y := 0.0
+		if game.HomeWon {
+			y = 1
+		}
+		p = math.Min(math.Max(p, 1e-6), 1-1e-6)
+		fit.Games++
+		fit.LogLoss -= y*math.Log(p) + (1-y)*math.Log(1-p)
+		fit.Brier += (p - y) * (p - y)
+	}
+	if fit.Games > 0 {
+		fit.LogLoss /= float64(fit.Games)
+		fit.Brier /= float64(fit.Games)
+	}
+	return elo, fit
+}
+
+// HasDates reports whether any game has a date. Without dates every team
+// looks fully rested, so rest can't be fit or applied.
+func HasDates(games []Game) bool {
+	for _, game := range games {
+		if !game.Date.IsZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// Tune grid searches K, home advantage and rest on the log loss of every
+// season after the first, which only warms the ratings up. With a single
+// season the second half of it is scored instead. Rest is left at zero
+// when the games have no dates.
+func Tune(games []Game) EloFit {
+	seasons := make(map[string]bool)
+	for _, game := range games {
+		seasons[game.Season] = true
+	}
+	ordered := make([]string, 0, len(seasons))
+	for season := range seasons {
+		ordered = append(ordered, season)
+	}
+	sort.Strings(ordered)
+
+	scored := make(map[string]bool)
+	tuneGames := games
+	if len(ordered) > 1 {
+		for _, season := range ordered[1:] {
+			scored[season] = true
+		}
+	} else if len(ordered) == 1 {
+		// Score the second half by relabelling it as its own season
+		// without regressing ratings in between
+		scored[ordered[0]] = true
+		half := len(games) / 2
+		tuneGames = make([]Game, len(games))
+		copy(tuneGames, games)
+		for i := range tuneGames[:half] {
+			tuneGames[i].Season = ""
+		}
+	}
+
+	restGrid := []float64{0}
+	if HasDates(games) {
+		restGrid = []float64{0, 10, 20}
+	}
+
+	best := EloFit{Config: DefaultEloConfig, LogLoss: math.Inf(1)}
+	for _, k := range []float64{2, 4, 6, 8, 10, 12} {
+		for _, home := range []float64{0, 25, 50, 75} {
+			for _, rest := range restGrid {
+				config := DefaultEloConfig
+				config.K, config.HomeAdvantage, config.RestPerDay = k, home, rest
+				_, fit := Evaluate(config, tuneGames, scored)
+				if fit.Games > 0 && fit.LogLoss < best.LogLoss {
+					best = fit
+				}
+			}
+		}
+	}
+	if math.IsInf(best.LogLoss, 1) {
+		best.LogLoss = 0
+	}
+	return best
+}
@@ -0,0 +1,169 @@
+package ratings
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultRidgeLambda is the penalty on team ratings, in games' worth of
+// shrinkage toward average
+const DefaultRidgeLambda = 10.0
+
+// TeamStrength is a team's xG rating in expected goals per game above an
+// average team. Offense is xG created; Defense is xG prevented, so higher
+// is better for both.
+type TeamStrength struct {
+	Team    string  `json:"team"`
+	Games   int     `json:"games"`
+	Offense float64 `json:"offense"`
+	Defense float64 `json:"defense"`
+	Net     float64 `json:"net"`
+}
+
+// StrengthModel is a fitted xG rating: each side's xG in a game is
+// modelled as Intercept + HomeIce (for the home side) + the side's
+// offense - the opponent's defense.
+type StrengthModel struct {
+	Games     int                      `json:"games"`
+	Lambda    float64                  `json:"lambda"`
+	Intercept float64                  `json:"intercept"` // xG per game for an average road team
+	HomeIce   float64                  `json:"homeIce"`
+	Teams     map[string]*TeamStrength `json:"teams"`
+}
+
+// Project returns the expected xG for each side of a matchup. Unknown
+// teams are treated as average.
+func (m *StrengthModel) Project(home, away string) (float64, float64) {
+	rating := func(team string) *TeamStrength {
+		if t, ok := m.Teams[team]; ok {
+			return t
+		}
+		return &TeamStrength{}
+	}
+	h, a := rating(home), rating(away)
+	homeXG := m.Intercept + m.HomeIce + h.Offense - a.Defense
+	awayXG := m.Intercept + a.Offense - h.Defense
+	return math.Max(homeXG, 0), math.Max(awayXG, 0)
+}
+
+// FitStrength fits offense and defense ratings to games' xG with ridge
+// regression. Every game is two rows, one per side. The intercept and home
+// ice aren't penalized. lambda must be positive: offense and defense are
+// only identified relative to the intercept through the penalty.
+func FitStrength(games []Game, lambda float64) (*StrengthModel, error) {
+	if len(games) == 0 {
+		return nil, fmt.Errorf("no games to fit")
+	}
+	if lambda <= 0 {
+		return nil, fmt.Errorf("lambda must be positive")
+	}
+
+	teamSet := make(map[string]bool)
+	for _, game := range games {
+		teamSet[game.Home] = true
+		teamSet[game.Away] = true
+	}
+	teams := make([]string, 0, len(teamSet))
+	for team := range teamSet {
+		teams = append(teams, team)
+	}
+	sort.Strings(teams)
+	index := make(map[string]int, len(teams))
+	for i, team := range teams {
+		index[team] = i
+	}
+
+	// Columns: intercept, home ice, offense per team, defense per team
+	n := 2 + 2*len(teams)
+	offense := func(team string) int { return 2 + index[team] }
+	defense := func(team string) int { return 2 + len(teams) + index[team] }
+
+	xtx := make([][]float64, n)
+	for i := range xtx {
+		xtx[i] = make([]float64, n)
+	}
+	xty := make([]float64, n)
+	gamesPlayed := make(map[string]int)
+
+	// Each row has at most four non-zero entries, all 1 except defense
+	// which is -1
+	addRow := func(cols []int, signs []float64, y float64) {
+		for a, ca := range cols {
+			xty[ca] += signs[a] * y
+			for b, cb := range cols {
+				xtx[ca][cb] += signs[a] * signs[b]
+			}
+		}
+	}
+	for _, game := range games {
+		addRow([]int{0, 1, offense(game.Home), defense(game.Away)}, []float64{1, 1, 1, -1}, game.HomeXG)
+		addRow([]int{0, offense(game.Away), defense(game.Home)}, []float64{1, 1, -1}, game.AwayXG)
+		gamesPlayed[game.Home]++
+		gamesPlayed[game.Away]++
+	}
+	for j := 2; j < n; j++ {
+		xtx[j][j] += lambda
+	}
+
+	beta, err := solve(xtx, xty)
+	if err != nil {
+		return nil, err
+	}
+
+	model := &StrengthModel{
+		Games:     len(games),
+		Lambda:    lambda,
+		Intercept: beta[0],
+		HomeIce:   beta[1],
+		Teams:     make(map[string]*TeamStrength, len(teams)),
+	}
+	for _, team := range teams {
+		off, def := beta[offense(team)], beta[defense(team)]
+		model.Teams[team] = &TeamStrength{
+			Team:    team,
+			Games:   gamesPlayed[team],
+			Offense: off,
+			Defense: def,
+			Net:     off + def,
+		}
+	}
+	return model, nil
+}
+
+// solve returns x for a x = b using Gaussian elimination with partial
+// pivoting. a and b are overwritten.
+func solve(a [][]float64, b []float64) ([]float64, error) {
+	n := len(b)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, fmt.Errorf("strength fit failed: singular system")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		for row := col + 1; row < n; row++ {
+			factor := a[row][col] / a[col][col]
+			for j := col; j < n; j++ {
+				a[row][j] -= factor * a[col][j]
+			}
+			b[row] -= factor * b[col]
+		}
+	}
+
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := b[row]
+		for j := row + 1; j < n; j++ {
+			sum -= a[row][j] * x[j]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x, nil
+}
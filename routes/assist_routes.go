@@ -6,6 +6,7 @@ import (
 )
 
 func AssistRoutes(e *echo.Echo) {
-	// Define a route for processing assists
+	// Team assists in the original response shape, kept for older clients;
+	// /nhl/playmaking has the full numbers
 	e.GET("/process-assists", handlers.ProcessAssistsHandler)
 }
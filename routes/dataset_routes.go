@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/KPWithCode/statpad2/handlers"
+	"github.com/labstack/echo/v4"
+)
+
+func DatasetRoutes(e *echo.Echo) {
+	// NHL shot datasets; select one on any NHL route with ?dataset=<id>
+	e.POST("/datasets", handlers.UploadDatasetHandler)
+	e.GET("/datasets", handlers.ListDatasetsHandler)
+	e.DELETE("/datasets/:id", handlers.DeleteDatasetHandler)
+}
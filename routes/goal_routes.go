@@ -14,6 +14,7 @@ func GoalRoutes(e *echo.Echo) {
 	e.GET("/process-dangerzone", handlers.ProcessDangerZone)
 
 	e.GET("/nhl/trendlens", handlers.NHLTrendLensHandler)
+	e.GET("/nhl/trendlens/schema", handlers.NHLTrendLensSchemaHandler)
 
 	e.GET("/process-goals", handlers.ProcessGoalsHandler)
 }
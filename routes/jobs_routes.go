@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/KPWithCode/statpad2/handlers"
+	"github.com/labstack/echo/v4"
+)
+
+func JobRoutes(e *echo.Echo) {
+	// Background job status and manual runs
+	e.GET("/jobs", handlers.GetJobs)
+	e.POST("/jobs/:name/run", handlers.RunJob)
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/KPWithCode/statpad2/handlers"
+	"github.com/labstack/echo/v4"
+)
+
+func MetricsRoutes(e *echo.Echo) {
+	// Upstream API usage and quota
+	e.GET("/metrics/upstreams", handlers.GetUpstreamMetrics)
+}
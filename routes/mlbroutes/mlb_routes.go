@@ -6,6 +6,6 @@ import (
 )
 
 func MLBRoutes(e *echo.Echo) {
-	// Define a route for processing goals
+	// Pythagorean expected wins (classic and Pythagenpat)
 	e.GET("/mlb/pythagorean", mlbhandler.PythagoreanHandler)
 }
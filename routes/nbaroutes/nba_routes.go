@@ -12,6 +12,7 @@ func NBARoutes(e *echo.Echo) {
 	e.GET("/nba/epm", nbahandler.EPMHandler)
 	e.GET("/nba/blowoutindicator", nbahandler.BlowoutPredictorHandler)
 	e.GET("/nba/trendlens", nbahandler.TrendLensHandler)
+	e.GET("/nba/trendlens/schema", nbahandler.TrendLensSchemaHandler)
 
 	// maybe
 	e.GET("/nba/bayesian", nbahandler.BayesianMatchupHandler)
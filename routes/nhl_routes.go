@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"github.com/KPWithCode/statpad2/handlers"
+	"github.com/labstack/echo/v4"
+)
+
+func NHLRoutes(e *echo.Echo) {
+	// NHL analytics built from the shot data
+	e.GET("/nhl/goalies", handlers.ProcessGoaliesHandler)
+	e.GET("/nhl/players/rates", handlers.PlayerRatesHandler)
+	e.GET("/nhl/players/chances", handlers.PlayerChancesHandler)
+	e.GET("/nhl/possession", handlers.ProcessPossessionHandler)
+	e.GET("/nhl/danger-zones", handlers.GetDangerZonesHandler)
+	e.GET("/nhl/heatmap", handlers.ProcessHeatmapHandler)
+	e.GET("/nhl/chances", handlers.ProcessChancesHandler)
+	e.GET("/nhl/positions", handlers.ProcessPositionSplitsHandler)
+	e.GET("/nhl/props", handlers.ProcessPropsHandler)
+	e.GET("/nhl/props/matchup", handlers.MatchupPropsHandler)
+	e.GET("/nhl/playmaking", handlers.ProcessPlaymakingHandler)
+	e.GET("/nhl/players/playmaking", handlers.PlayerPlaymakingHandler)
+	e.GET("/nhl/teams/:code/games", handlers.TeamGamesHandler)
+	e.GET("/nhl/special-teams", handlers.ProcessSpecialTeamsHandler)
+	e.GET("/nhl/teams/:code/special-teams", handlers.TeamSpecialTeamsHandler)
+
+	// Team ratings and game predictions
+	e.GET("/nhl/ratings", handlers.NHLRatingsHandler)
+	e.GET("/nhl/predict", handlers.NHLPredictHandler)
+
+	// Win probability
+	e.GET("/nhl/winprob", handlers.WinProbHandler)
+	e.GET("/nhl/winprob/table", handlers.WinProbTableHandler)
+	e.GET("/nhl/winprob/games/:gameId", handlers.GameWinProbHandler)
+
+	// In-repo expected goals model
+	e.POST("/nhl/xg/train", handlers.TrainXGModelHandler)
+	e.GET("/nhl/xg/model", handlers.GetXGModelHandler)
+	e.GET("/nhl/xg/score", handlers.ScoreXGHandler)
+	e.POST("/nhl/xg/score", handlers.ScoreXGHandler)
+	e.GET("/nhl/xg/compare", handlers.CompareXGHandler)
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/KPWithCode/statpad2/handlers"
+	"github.com/labstack/echo/v4"
+)
+
+func SearchRoutes(e *echo.Echo) {
+	// Local search index queries (SEARCH_INDEX_BACKEND=json or memory)
+	e.GET("/search-index/:name", handlers.QuerySearchIndex)
+}
@@ -0,0 +1,11 @@
+package routes
+
+import (
+	"github.com/KPWithCode/statpad2/handlers"
+	"github.com/labstack/echo/v4"
+)
+
+func SeasonRoutes(e *echo.Echo) {
+	// Season keys accepted by the season parameter on /nba and /mlb routes
+	e.GET("/seasons", handlers.GetSeasons)
+}
@@ -0,0 +1,91 @@
+package searchindex
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/algolia/algoliasearch-client-go/v4/algolia/search"
+)
+
+// fetchBatchSize is the most objects Algolia returns from one GetObjects
+// call
+const fetchBatchSize = 1000
+
+// Algolia is an Indexer backed by an Algolia index
+type Algolia struct {
+	client *search.APIClient
+	index  string
+}
+
+// NewAlgolia creates an indexer for index in the given Algolia app
+func NewAlgolia(appID, apiKey, index string) (*Algolia, error) {
+	if appID == "" || apiKey == "" || index == "" {
+		return nil, ErrMissingCredentials
+	}
+
+	client, err := search.NewClient(appID, apiKey)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to initialize Algolia client: %v", err)
+	}
+	return &Algolia{client: client, index: index}, nil
+}
+
+func (a *Algolia) Name() string {
+	return a.index
+}
+
+func (a *Algolia) Save(ctx context.Context, docs []Document) (*SaveResult, error) {
+	batchRequests := make([]search.BatchRequest, 0, len(docs))
+	for _, doc := range docs {
+		batchRequests = append(batchRequests, *search.NewEmptyBatchRequest().
+			SetAction(search.Action("updateObject")).
+			SetBody(map[string]interface{}(doc)))
+	}
+
+	response, err := a.client.Batch(a.client.NewApiBatchRequest(
+		a.index,
+		search.NewEmptyBatchWriteParams().SetRequests(batchRequests),
+	), search.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to save to Algolia: %v", err)
+	}
+
+	return &SaveResult{Saved: len(batchRequests), TaskID: response.TaskID}, nil
+}
+
+func (a *Algolia) Fetch(ctx context.Context, ids []string) (map[string]Document, error) {
+	docs := make(map[string]Document, len(ids))
+
+	for start := 0; start < len(ids); start += fetchBatchSize {
+		end := start + fetchBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		requests := make([]search.GetObjectsRequest, 0, end-start)
+		for _, id := range ids[start:end] {
+			requests = append(requests, *search.NewGetObjectsRequest(id, a.index))
+		}
+
+		response, err := a.client.GetObjects(
+			a.client.NewApiGetObjectsRequest(search.NewGetObjectsParams(requests)),
+			search.WithContext(ctx),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to fetch from Algolia: %v", err)
+		}
+
+		// Missing objects come back as null results
+		for _, result := range response.Results {
+			if result == nil {
+				continue
+			}
+			doc := Document(result)
+			if id := doc.ObjectID(); id != "" {
+				docs[id] = doc
+			}
+		}
+	}
+
+	return docs, nil
+}
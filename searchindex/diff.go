@@ -0,0 +1,117 @@
+package searchindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+)
+
+// Change types reported by Diff
+const (
+	ChangeAdded   = "added"
+	ChangeUpdated = "updated"
+)
+
+// FieldChange is one field that differs from the stored document
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// Change describes what saving one document would do
+type Change struct {
+	ObjectID string        `json:"objectID"`
+	Type     string        `json:"type"`
+	Fields   []FieldChange `json:"fields,omitempty"`
+}
+
+// DiffResult summarizes what saving a set of documents would change
+type DiffResult struct {
+	Added     int      `json:"added"`
+	Updated   int      `json:"updated"`
+	Unchanged int      `json:"unchanged"`
+	Changes   []Change `json:"changes"`
+}
+
+// Diff compares docs against what index currently holds without writing
+// anything. Fields named in ignore (timestamps, usually) are not compared.
+// Unchanged documents are counted but not listed.
+func Diff(ctx context.Context, index Indexer, docs []Document, ignore ...string) (*DiffResult, error) {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		ids = append(ids, doc.ObjectID())
+	}
+
+	stored, err := index.Fetch(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	skip := make(map[string]bool, len(ignore))
+	for _, field := range ignore {
+		skip[field] = true
+	}
+
+	result := &DiffResult{Changes: []Change{}}
+	for _, doc := range docs {
+		id := doc.ObjectID()
+		old, exists := stored[id]
+		if !exists {
+			result.Added++
+			result.Changes = append(result.Changes, Change{ObjectID: id, Type: ChangeAdded})
+			continue
+		}
+
+		fields := diffFields(old, doc, skip)
+		if len(fields) == 0 {
+			result.Unchanged++
+			continue
+		}
+		result.Updated++
+		result.Changes = append(result.Changes, Change{ObjectID: id, Type: ChangeUpdated, Fields: fields})
+	}
+
+	sort.Slice(result.Changes, func(i, j int) bool {
+		return result.Changes[i].ObjectID < result.Changes[j].ObjectID
+	})
+	return result, nil
+}
+
+// diffFields lists the fields whose values differ. Values are compared by
+// their JSON encoding so an int and the float64 it decodes to are equal.
+func diffFields(old, updated Document, skip map[string]bool) []FieldChange {
+	names := make(map[string]bool)
+	for field := range old {
+		names[field] = true
+	}
+	for field := range updated {
+		names[field] = true
+	}
+
+	var fields []FieldChange
+	for field := range names {
+		if skip[field] {
+			continue
+		}
+		oldValue, newValue := old[field], updated[field]
+		if !sameJSON(oldValue, newValue) {
+			fields = append(fields, FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].Field < fields[j].Field
+	})
+	return fields
+}
+
+func sameJSON(a, b interface{}) bool {
+	aj, aerr := json.Marshal(a)
+	bj, berr := json.Marshal(b)
+	if aerr != nil || berr != nil {
+		return false
+	}
+	return bytes.Equal(aj, bj)
+}
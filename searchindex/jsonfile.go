@@ -0,0 +1,93 @@
+package searchindex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// JSONFile is an Indexer persisted to <dir>/<name>.json. The whole index is
+// held in memory and rewritten on every save, which is fine at TrendLens
+// sizes (a few thousand players).
+type JSONFile struct {
+	*Memory
+	path string
+
+	writeMu sync.Mutex
+}
+
+// NewJSONFile opens the index file for name in dir, creating it on first
+// save if it doesn't exist
+func NewJSONFile(dir, name string) (*JSONFile, error) {
+	index := &JSONFile{
+		Memory: NewMemory(name),
+		path:   filepath.Join(dir, name+".json"),
+	}
+
+	data, err := os.ReadFile(index.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading index %s: %v", index.path, err)
+	}
+
+	var docs []Document
+	if err := json.Unmarshal(data, &docs); err != nil {
+		return nil, fmt.Errorf("error parsing index %s: %v", index.path, err)
+	}
+	for _, doc := range docs {
+		if id := doc.ObjectID(); id != "" {
+			index.docs[id] = doc
+		}
+	}
+	return index, nil
+}
+
+func (j *JSONFile) Save(ctx context.Context, docs []Document) (*SaveResult, error) {
+	j.writeMu.Lock()
+	defer j.writeMu.Unlock()
+
+	result, err := j.Memory.Save(ctx, docs)
+	if err != nil {
+		return nil, err
+	}
+	if err := j.write(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// write saves every document, sorted by objectID so the file diffs cleanly
+func (j *JSONFile) write() error {
+	j.mu.RLock()
+	docs := make([]Document, 0, len(j.docs))
+	for _, doc := range j.docs {
+		docs = append(docs, doc)
+	}
+	j.mu.RUnlock()
+
+	sort.Slice(docs, func(a, b int) bool {
+		return docs[a].ObjectID() < docs[b].ObjectID()
+	})
+
+	data, err := json.MarshalIndent(docs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding index %s: %v", j.name, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil {
+		return fmt.Errorf("error creating %s: %v", filepath.Dir(j.path), err)
+	}
+
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing index %s: %v", j.path, err)
+	}
+	return os.Rename(tmp, j.path)
+}
@@ -0,0 +1,160 @@
+package searchindex
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory is an Indexer that keeps documents in memory. It supports simple
+// queries, so it doubles as a stand-in for Algolia during development.
+type Memory struct {
+	name string
+
+	mu   sync.RWMutex
+	docs map[string]Document
+}
+
+// NewMemory creates an empty in-memory index
+func NewMemory(name string) *Memory {
+	return &Memory{name: name, docs: make(map[string]Document)}
+}
+
+func (m *Memory) Name() string {
+	return m.name
+}
+
+func (m *Memory) Save(ctx context.Context, docs []Document) (*SaveResult, error) {
+	normalized := make([]Document, 0, len(docs))
+	for _, doc := range docs {
+		if doc.ObjectID() == "" {
+			return nil, fmt.Errorf("document is missing an objectID")
+		}
+		// Store documents as they'd come back from a real index, so numbers
+		// compare the same way whichever backend they were read from
+		copied, err := normalize(doc)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, copied)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, doc := range normalized {
+		m.docs[doc.ObjectID()] = doc
+	}
+	return &SaveResult{Saved: len(normalized)}, nil
+}
+
+func (m *Memory) Fetch(ctx context.Context, ids []string) (map[string]Document, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	docs := make(map[string]Document, len(ids))
+	for _, id := range ids {
+		if doc, ok := m.docs[id]; ok {
+			docs[id] = doc
+		}
+	}
+	return docs, nil
+}
+
+// Query selects documents from a local index
+type Query struct {
+	// Text matches documents with a string field containing it,
+	// case-insensitively
+	Text string
+	// Filters requires each field to equal the value, compared as text
+	Filters map[string]string
+	// SortBy orders results by a numeric field, ascending unless Desc
+	SortBy string
+	Desc   bool
+	// Limit caps the number of results; 0 means no limit
+	Limit int
+}
+
+// Query returns the documents matching q. Without SortBy they are ordered
+// by objectID.
+func (m *Memory) Query(q Query) []Document {
+	m.mu.RLock()
+	results := []Document{}
+	for _, doc := range m.docs {
+		if matches(doc, q) {
+			results = append(results, doc)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if q.SortBy != "" {
+			a, aok := number(results[i][q.SortBy])
+			b, bok := number(results[j][q.SortBy])
+			if aok != bok {
+				// Documents without the field go last
+				return aok
+			}
+			if a != b {
+				if q.Desc {
+					return a > b
+				}
+				return a < b
+			}
+		}
+		return results[i].ObjectID() < results[j].ObjectID()
+	})
+
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results
+}
+
+func matches(doc Document, q Query) bool {
+	for field, want := range q.Filters {
+		value, ok := doc[field]
+		if !ok || !strings.EqualFold(fmt.Sprint(value), want) {
+			return false
+		}
+	}
+
+	if q.Text == "" {
+		return true
+	}
+	text := strings.ToLower(q.Text)
+	for _, value := range doc {
+		if s, ok := value.(string); ok && strings.Contains(strings.ToLower(s), text) {
+			return true
+		}
+	}
+	return false
+}
+
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// normalize round-trips doc through JSON, giving a deep copy with JSON
+// types (float64 numbers, []interface{} slices)
+func normalize(doc Document) (Document, error) {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding document %s: %v", doc.ObjectID(), err)
+	}
+	var copied Document
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, fmt.Errorf("error decoding document %s: %v", doc.ObjectID(), err)
+	}
+	return copied, nil
+}
@@ -0,0 +1,109 @@
+package searchindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ToDocument converts a typed document struct into the map form the
+// indexers store. The struct must encode an "objectID".
+func ToDocument(v interface{}) (Document, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding document: %v", err)
+	}
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("error decoding document: %v", err)
+	}
+	if doc.ObjectID() == "" {
+		return nil, fmt.Errorf("document is missing an objectID")
+	}
+	return doc, nil
+}
+
+// JSONSchema describes the JSON encoding of doc, a struct, as a JSON Schema
+// (draft 2020-12) object. Fields follow their json tags; embedded structs
+// are flattened the way encoding/json flattens them. Fields without
+// omitempty, and not pointers, are required. A field's "doc" tag becomes its
+// description.
+func JSONSchema(title string, version int, doc interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	collectProperties(reflect.TypeOf(doc), properties, &required)
+
+	return map[string]interface{}{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                title,
+		"version":              version,
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// collectProperties adds t's fields to properties. Required field names are
+// appended to required unless it is nil.
+func collectProperties(t reflect.Type, properties map[string]interface{}, required *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		fieldType := field.Type
+		if field.Anonymous && name == "" {
+			// A nil embedded pointer drops all of its fields
+			if fieldType.Kind() == reflect.Pointer {
+				collectProperties(fieldType, properties, nil)
+			} else {
+				collectProperties(fieldType, properties, required)
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		optional := strings.Contains(options, "omitempty") || fieldType.Kind() == reflect.Pointer
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+
+		property := map[string]interface{}{"type": schemaType(fieldType)}
+		if description := field.Tag.Get("doc"); description != "" {
+			property["description"] = description
+		}
+		properties[name] = property
+		if !optional && required != nil {
+			*required = append(*required, name)
+		}
+	}
+}
+
+func schemaType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
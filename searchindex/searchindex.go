@@ -0,0 +1,125 @@
+// Package searchindex abstracts the search indexes the TrendLens pipelines
+// publish player documents to. Algolia is the production backend; the JSON
+// file and in-memory backends let the pipelines run, and be inspected,
+// without Algolia credentials.
+//
+// SEARCH_INDEX_BACKEND selects the backend: "algolia" (the default), "json"
+// (files under SEARCH_INDEX_DIR, default data/search-index) or "memory".
+package searchindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Document is one search record. Every document needs an "objectID".
+type Document map[string]interface{}
+
+// ObjectID returns the document's objectID, or "" if it has none
+func (d Document) ObjectID() string {
+	id, _ := d["objectID"].(string)
+	return id
+}
+
+// SaveResult describes a completed save
+type SaveResult struct {
+	Saved int `json:"saved"`
+	// TaskID is Algolia's indexing task, 0 for local backends
+	TaskID int64 `json:"taskID,omitempty"`
+}
+
+// Indexer is a single search index
+type Indexer interface {
+	// Name returns the index name
+	Name() string
+	// Save adds documents, replacing any stored under the same objectID
+	Save(ctx context.Context, docs []Document) (*SaveResult, error)
+	// Fetch returns the stored documents for ids, keyed by objectID.
+	// Missing ids are left out.
+	Fetch(ctx context.Context, ids []string) (map[string]Document, error)
+}
+
+// Querier is implemented by backends that can be searched locally
+type Querier interface {
+	Query(q Query) []Document
+}
+
+// ErrMissingCredentials is returned when the Algolia backend is selected
+// but not configured
+var ErrMissingCredentials = errors.New("Missing Algolia credentials")
+
+// Backend names accepted by SEARCH_INDEX_BACKEND
+const (
+	BackendAlgolia = "algolia"
+	BackendJSON    = "json"
+	BackendMemory  = "memory"
+)
+
+// Backend returns the configured backend name
+func Backend() string {
+	switch backend := strings.ToLower(os.Getenv("SEARCH_INDEX_BACKEND")); backend {
+	case BackendJSON, BackendMemory:
+		return backend
+	default:
+		return BackendAlgolia
+	}
+}
+
+// IndexName picks the index to use: configured (usually from an Algolia
+// env var) when set, otherwise localName for the local backends. Algolia
+// gets no fallback so a missing variable is reported, not guessed at.
+func IndexName(configured, localName string) string {
+	if configured != "" || Backend() == BackendAlgolia {
+		return configured
+	}
+	return localName
+}
+
+var (
+	localMu      sync.Mutex
+	localIndexes = make(map[string]Indexer)
+)
+
+// Open returns the index called name on the configured backend. Local
+// indexes are shared across the process so later queries see earlier saves.
+func Open(name string) (Indexer, error) {
+	backend := Backend()
+	if backend == BackendAlgolia {
+		return NewAlgolia(os.Getenv("ALGOLIA_APP_ID"), os.Getenv("ALGOLIA_API_KEY"), name)
+	}
+
+	if name == "" {
+		return nil, fmt.Errorf("index name is required")
+	}
+
+	localMu.Lock()
+	defer localMu.Unlock()
+
+	key := backend + ":" + name
+	if indexer, ok := localIndexes[key]; ok {
+		return indexer, nil
+	}
+
+	var indexer Indexer
+	switch backend {
+	case BackendJSON:
+		dir := os.Getenv("SEARCH_INDEX_DIR")
+		if dir == "" {
+			dir = "data/search-index"
+		}
+		jsonIndex, err := NewJSONFile(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		indexer = jsonIndex
+	default:
+		indexer = NewMemory(name)
+	}
+
+	localIndexes[key] = indexer
+	return indexer, nil
+}
@@ -0,0 +1,287 @@
+// Package seasons maps dates to league seasons and the season keys
+// MySportsFeeds expects ("2024-2025-regular", "2025-playoff", "2024-regular").
+//
+// Season boundaries come from each league's calendar rather than a fixed
+// table, so the current season rolls over on its own every year. Setting
+// NBA_SEASON or MLB_SEASON pins the current season, e.g. while a season's
+// dates shift.
+package seasons
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Season types
+const (
+	Regular = "regular"
+	Playoff = "playoff"
+)
+
+// Season is one regular season or postseason. Start and End are inclusive
+// dates in US Eastern time.
+type Season struct {
+	League string    `json:"league"`
+	Key    string    `json:"key"`
+	Type   string    `json:"type"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+// Contains reports whether t falls within the season's dates
+func (s Season) Contains(t time.Time) bool {
+	t = t.In(eastern)
+	return !t.Before(s.Start) && t.Before(s.End.AddDate(0, 0, 1))
+}
+
+type monthDay struct {
+	month time.Month
+	day   int
+}
+
+// calendar describes when a league's seasons start and end. Dates are
+// approximate; they only need to land in the gaps between real games.
+type calendar struct {
+	// spansYears is set when a season crosses the new year (NBA)
+	spansYears   bool
+	regularStart monthDay
+	playoffStart monthDay
+	seasonEnd    monthDay
+	// firstYear is the earliest season with data available
+	firstYear int
+}
+
+var calendars = map[string]calendar{
+	"nba": {
+		spansYears:   true,
+		regularStart: monthDay{time.October, 1},
+		playoffStart: monthDay{time.April, 15},
+		seasonEnd:    monthDay{time.June, 30},
+		firstYear:    2015,
+	},
+	"mlb": {
+		spansYears:   false,
+		regularStart: monthDay{time.March, 15},
+		playoffStart: monthDay{time.September, 30},
+		seasonEnd:    monthDay{time.November, 15},
+		firstYear:    2016,
+	},
+}
+
+// MySportsFeeds assigns games to dates in US Eastern time
+var eastern = loadEastern()
+
+func loadEastern() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Leagues returns the leagues the registry knows about
+func Leagues() []string {
+	leagues := make([]string, 0, len(calendars))
+	for league := range calendars {
+		leagues = append(leagues, league)
+	}
+	sort.Strings(leagues)
+	return leagues
+}
+
+// Current returns the season in progress for league. Between seasons it is
+// the upcoming regular season.
+func Current(league string) (Season, error) {
+	league = strings.ToLower(league)
+	if _, ok := calendars[league]; !ok {
+		return Season{}, fmt.Errorf("unknown league %q", league)
+	}
+	if pinned := os.Getenv(strings.ToUpper(league) + "_SEASON"); pinned != "" {
+		season, err := parse(league, pinned)
+		if err != nil {
+			return Season{}, fmt.Errorf("invalid %s_SEASON: %v", strings.ToUpper(league), err)
+		}
+		return season, nil
+	}
+	return At(league, time.Now())
+}
+
+// At returns the season league is in at t
+func At(league string, t time.Time) (Season, error) {
+	league = strings.ToLower(league)
+	cal, ok := calendars[league]
+	if !ok {
+		return Season{}, fmt.Errorf("unknown league %q", league)
+	}
+
+	t = t.In(eastern)
+	year := t.Year()
+	if t.Before(cal.date(year, cal.regularStart)) {
+		year--
+	}
+
+	for _, season := range []Season{cal.regular(league, year), cal.playoff(league, year)} {
+		if season.Contains(t) {
+			return season, nil
+		}
+	}
+	// Offseason: the next regular season is the one to look at
+	return cal.regular(league, year+1), nil
+}
+
+// Resolve turns a season query parameter into a Season. An empty value or
+// "current" is the current season. Besides full keys it accepts a starting
+// year ("2024") or year span ("2024-2025") for the regular season.
+func Resolve(league, value string) (Season, error) {
+	league = strings.ToLower(league)
+	if _, ok := calendars[league]; !ok {
+		return Season{}, fmt.Errorf("unknown league %q", league)
+	}
+
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" || value == "current" {
+		return Current(league)
+	}
+
+	season, err := parse(league, value)
+	if err != nil {
+		return Season{}, err
+	}
+
+	current, err := Current(league)
+	if err != nil {
+		return Season{}, err
+	}
+	if season.Start.After(current.Start) {
+		return Season{}, fmt.Errorf("season %s hasn't started yet", season.Key)
+	}
+	return season, nil
+}
+
+// List returns every season for league up to and including the current
+// one, newest first
+func List(league string) ([]Season, error) {
+	league = strings.ToLower(league)
+	cal, ok := calendars[league]
+	if !ok {
+		return nil, fmt.Errorf("unknown league %q", league)
+	}
+
+	current, err := Current(league)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []Season
+	for year := cal.firstYear; ; year++ {
+		regular := cal.regular(league, year)
+		if regular.Start.After(current.Start) {
+			break
+		}
+		list = append(list, regular)
+
+		playoff := cal.playoff(league, year)
+		if !playoff.Start.After(current.Start) {
+			list = append(list, playoff)
+		}
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Start.After(list[j].Start)
+	})
+	return list, nil
+}
+
+var (
+	spanKey = regexp.MustCompile(`^(\d{4})-(\d{4})(?:-(regular|playoff))?$`)
+	yearKey = regexp.MustCompile(`^(\d{4})(?:-(regular|playoff))?$`)
+)
+
+// parse reads a season key without checking it against the current season
+func parse(league, value string) (Season, error) {
+	cal := calendars[league]
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	var (
+		year       int
+		seasonType = Regular
+	)
+	switch {
+	case spanKey.MatchString(value):
+		if !cal.spansYears {
+			return Season{}, fmt.Errorf("invalid season %q, %s seasons are a single year like 2024", value, league)
+		}
+		m := spanKey.FindStringSubmatch(value)
+		year, _ = strconv.Atoi(m[1])
+		end, _ := strconv.Atoi(m[2])
+		if end != year+1 {
+			return Season{}, fmt.Errorf("invalid season %q, years must be consecutive", value)
+		}
+		if m[3] != "" {
+			seasonType = m[3]
+		}
+	case yearKey.MatchString(value):
+		m := yearKey.FindStringSubmatch(value)
+		year, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			seasonType = m[2]
+		}
+		// "2025-playoff" is the postseason ending the 2024-2025 season
+		if cal.spansYears && seasonType == Playoff {
+			year--
+		}
+	default:
+		return Season{}, fmt.Errorf("invalid season %q, expected a key like %s", value, cal.regular(league, 2024).Key)
+	}
+
+	if year < cal.firstYear {
+		return Season{}, fmt.Errorf("no %s data before the %s season", league, cal.regular(league, cal.firstYear).Key)
+	}
+
+	if seasonType == Playoff {
+		return cal.playoff(league, year), nil
+	}
+	return cal.regular(league, year), nil
+}
+
+// regular returns the regular season starting in year
+func (cal calendar) regular(league string, year int) Season {
+	endYear := year
+	key := fmt.Sprintf("%d-%s", year, Regular)
+	if cal.spansYears {
+		endYear = year + 1
+		key = fmt.Sprintf("%d-%d-%s", year, endYear, Regular)
+	}
+	return Season{
+		League: league,
+		Key:    key,
+		Type:   Regular,
+		Start:  cal.date(year, cal.regularStart),
+		End:    cal.date(endYear, cal.playoffStart).AddDate(0, 0, -1),
+	}
+}
+
+// playoff returns the postseason following the regular season starting in
+// year
+func (cal calendar) playoff(league string, year int) Season {
+	endYear := year
+	if cal.spansYears {
+		endYear = year + 1
+	}
+	return Season{
+		League: league,
+		Key:    fmt.Sprintf("%d-%s", endYear, Playoff),
+		Type:   Playoff,
+		Start:  cal.date(endYear, cal.playoffStart),
+		End:    cal.date(endYear, cal.seasonEnd),
+	}
+}
+
+func (cal calendar) date(year int, md monthDay) time.Time {
+	return time.Date(year, md.month, md.day, 0, 0, 0, 0, eastern)
+}
@@ -0,0 +1,199 @@
+package sportsdata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Fixtures is a Provider that reads saved MySportsFeeds responses from disk.
+// Files live at <dir>/<league>/<season>/<feed>.json, e.g.
+// data/fixtures/nba/2024-2025-regular/team_stats_totals.json. A file named
+// <feed>-<date>.json takes precedence when the query has a date, which is
+// how date-range player totals are stored.
+type Fixtures struct {
+	dir string
+}
+
+// NewFixtures creates a provider reading from dir
+func NewFixtures(dir string) *Fixtures {
+	return &Fixtures{dir: dir}
+}
+
+func (f *Fixtures) TeamTotals(ctx context.Context, q Query) ([]TeamStatsTotal, error) {
+	var response teamStatsTotalsResponse
+	if _, err := f.read(q, "team_stats_totals", &response); err != nil {
+		return nil, err
+	}
+
+	var totals []TeamStatsTotal
+	for _, total := range response.TeamStatsTotals {
+		if matchesTeam(q.Teams, total.Team.Abbreviation) {
+			totals = append(totals, total)
+		}
+	}
+	return totals, nil
+}
+
+func (f *Fixtures) PlayerTotals(ctx context.Context, q Query) ([]PlayerStatsTotal, error) {
+	var response playerStatsTotalsResponse
+	if _, err := f.read(q, "player_stats_totals", &response); err != nil {
+		return nil, err
+	}
+
+	var totals []PlayerStatsTotal
+	for _, total := range response.PlayerStatsTotals {
+		if matchesTeam(q.Teams, total.Team.Abbreviation) || matchesTeam(q.Teams, total.Player.CurrentTeam.Abbreviation) {
+			totals = append(totals, total)
+		}
+	}
+	return totals, nil
+}
+
+func (f *Fixtures) Schedule(ctx context.Context, q Query) ([]Game, error) {
+	var response gamesResponse
+	dated, err := f.read(q, "games", &response)
+	if err != nil {
+		return nil, err
+	}
+
+	var games []Game
+	for _, game := range response.Games {
+		if !matchesTeam(q.Teams, game.Schedule.AwayTeam.Abbreviation) && !matchesTeam(q.Teams, game.Schedule.HomeTeam.Abbreviation) {
+			continue
+		}
+		// A season-wide file still has to be narrowed to the requested dates
+		if !dated && !inDateRange(q.Date, game.Schedule.StartTime) {
+			continue
+		}
+		games = append(games, game)
+	}
+	return games, nil
+}
+
+func (f *Fixtures) TeamGameLogs(ctx context.Context, q Query) ([]TeamGameLog, error) {
+	var response teamGameLogsResponse
+	dated, err := f.read(q, "team_gamelogs", &response)
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []TeamGameLog
+	for _, entry := range response.GameLogs {
+		if !matchesTeam(q.Teams, entry.Team.Abbreviation) {
+			continue
+		}
+		if !dated && !inDateRange(q.Date, entry.Game.StartTime) {
+			continue
+		}
+		logs = append(logs, entry)
+	}
+	return logs, nil
+}
+
+func (f *Fixtures) PlayerGameLogs(ctx context.Context, q Query) ([]PlayerGameLog, error) {
+	var response playerGameLogsResponse
+	dated, err := f.read(q, "player_gamelogs", &response)
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []PlayerGameLog
+	for _, entry := range response.GameLogs {
+		if !matchesTeam(q.Teams, entry.Team.Abbreviation) {
+			continue
+		}
+		if !dated && !inDateRange(q.Date, entry.Game.StartTime) {
+			continue
+		}
+		logs = append(logs, entry)
+	}
+	return logs, nil
+}
+
+// read decodes the fixture for feed into out. It reports whether a
+// date-specific file was used.
+func (f *Fixtures) read(q Query, feed string, out interface{}) (bool, error) {
+	if q.League == "" || q.Season == "" {
+		return false, fmt.Errorf("league and season are required")
+	}
+
+	seasonDir := filepath.Join(f.dir, q.League, q.Season)
+
+	if q.Date != "" {
+		path := filepath.Join(seasonDir, fmt.Sprintf("%s-%s.json", feed, q.Date))
+		found, err := readJSONFile(path, out)
+		if err != nil || found {
+			return found, err
+		}
+	}
+
+	path := filepath.Join(seasonDir, feed+".json")
+	found, err := readJSONFile(path, out)
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		return false, fmt.Errorf("no fixture for %s/%s/%s", q.League, q.Season, feed)
+	}
+	return false, nil
+}
+
+// readJSONFile decodes path into out, reporting false if it doesn't exist
+func readJSONFile(path string, out interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading fixture %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("error parsing fixture %s: %v", path, err)
+	}
+	return true, nil
+}
+
+// matchesTeam reports whether abbr is one of teams (case-insensitive). An
+// empty teams list matches everything.
+func matchesTeam(teams []string, abbr string) bool {
+	if len(teams) == 0 {
+		return true
+	}
+	for _, team := range teams {
+		if strings.EqualFold(team, abbr) {
+			return true
+		}
+	}
+	return false
+}
+
+// inDateRange reports whether startTime falls on a date covered by date
+// (YYYYMMDD or YYYYMMDD-YYYYMMDD). Dates are compared in US Eastern time,
+// which is how MySportsFeeds assigns games to dates.
+func inDateRange(date, startTime string) bool {
+	if date == "" {
+		return true
+	}
+
+	start, err := time.Parse(time.RFC3339, startTime)
+	if err != nil {
+		return false
+	}
+	if loc, err := time.LoadLocation("America/New_York"); err == nil {
+		start = start.In(loc)
+	}
+	day := start.Format("20060102")
+
+	from, to, found := strings.Cut(date, "-")
+	if !found {
+		to = from
+	}
+	return day >= from && day <= to
+}
@@ -0,0 +1,190 @@
+package sportsdata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/KPWithCode/statpad2/cache"
+	"github.com/KPWithCode/statpad2/upstream"
+)
+
+const mySportsFeedsBaseURL = "https://api.mysportsfeeds.com/v2.1/pull"
+
+// MySportsFeeds is a Provider backed by the MySportsFeeds v2.1 API
+type MySportsFeeds struct {
+	apiKey  string
+	baseURL string
+	client  *upstream.Client
+	cache   *cache.Cache
+}
+
+// NewMySportsFeeds creates a client authenticating with apiKey. Requests go
+// through the shared MySportsFeeds upstream client and responses are cached
+// in the process-wide cache.
+func NewMySportsFeeds(apiKey string) *MySportsFeeds {
+	return &MySportsFeeds{
+		apiKey:  apiKey,
+		baseURL: mySportsFeedsBaseURL,
+		client:  upstream.MySportsFeeds,
+		cache:   cache.Default(),
+	}
+}
+
+// feedBackoff is the extra backoff MySportsFeeds charges for the heavier
+// feeds, in points on top of the 1 every request costs
+var feedBackoff = map[string]int{
+	"player_stats_totals": 5,
+	"player_gamelogs":     5,
+}
+
+// feedTTL is how long each feed's responses are cached. Schedules change
+// on game days; season totals only move once a game finishes.
+var feedTTL = map[string]time.Duration{
+	"games":               10 * time.Minute,
+	"team_stats_totals":   time.Hour,
+	"player_stats_totals": time.Hour,
+	"team_gamelogs":       time.Hour,
+	"player_gamelogs":     time.Hour,
+}
+
+// feedStaleFor is how long past expiry each feed's responses may still be
+// served while they're refreshed
+var feedStaleFor = map[string]time.Duration{
+	"games":               5 * time.Minute,
+	"team_stats_totals":   time.Hour,
+	"player_stats_totals": time.Hour,
+	"team_gamelogs":       time.Hour,
+	"player_gamelogs":     time.Hour,
+}
+
+func (m *MySportsFeeds) TeamTotals(ctx context.Context, q Query) ([]TeamStatsTotal, error) {
+	var response teamStatsTotalsResponse
+	if err := m.get(ctx, q, "team_stats_totals", &response); err != nil {
+		return nil, err
+	}
+	return response.TeamStatsTotals, nil
+}
+
+func (m *MySportsFeeds) PlayerTotals(ctx context.Context, q Query) ([]PlayerStatsTotal, error) {
+	var response playerStatsTotalsResponse
+	if err := m.get(ctx, q, "player_stats_totals", &response); err != nil {
+		return nil, err
+	}
+	return response.PlayerStatsTotals, nil
+}
+
+func (m *MySportsFeeds) Schedule(ctx context.Context, q Query) ([]Game, error) {
+	var response gamesResponse
+	if err := m.get(ctx, q, "games", &response); err != nil {
+		return nil, err
+	}
+	return response.Games, nil
+}
+
+func (m *MySportsFeeds) TeamGameLogs(ctx context.Context, q Query) ([]TeamGameLog, error) {
+	var response teamGameLogsResponse
+	if err := m.get(ctx, q, "team_gamelogs", &response); err != nil {
+		return nil, err
+	}
+	return response.GameLogs, nil
+}
+
+func (m *MySportsFeeds) PlayerGameLogs(ctx context.Context, q Query) ([]PlayerGameLog, error) {
+	var response playerGameLogsResponse
+	if err := m.get(ctx, q, "player_gamelogs", &response); err != nil {
+		return nil, err
+	}
+	return response.GameLogs, nil
+}
+
+// feedURL builds the request URL for feed
+func (m *MySportsFeeds) feedURL(q Query, feed string) string {
+	endpoint := fmt.Sprintf("%s/%s/%s/%s.json", m.baseURL, q.League, q.Season, feed)
+
+	params := url.Values{}
+	if len(q.Teams) > 0 {
+		// Sort so the same teams always produce the same URL and cache key
+		teams := append([]string(nil), q.Teams...)
+		sort.Strings(teams)
+		params.Set("team", strings.Join(teams, ","))
+	}
+	if q.Date != "" {
+		params.Set("date", q.Date)
+	}
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+	return endpoint
+}
+
+// get fetches feed and decodes it into out, going through the cache when
+// there is one. A 204 leaves out untouched, which MySportsFeeds returns when
+// there is nothing to report (e.g. no games on a date).
+func (m *MySportsFeeds) get(ctx context.Context, q Query, feed string, out interface{}) error {
+	if m.apiKey == "" {
+		return fmt.Errorf("API key not found in environment variables")
+	}
+	if q.League == "" || q.Season == "" {
+		return fmt.Errorf("league and season are required")
+	}
+
+	endpoint := m.feedURL(q, feed)
+
+	fetch := func(ctx context.Context) ([]byte, error) {
+		return m.fetch(ctx, endpoint, feed)
+	}
+
+	var (
+		body []byte
+		err  error
+	)
+	if m.cache != nil {
+		body, err = m.cache.Fetch(ctx, endpoint, feedTTL[feed], feedStaleFor[feed], fetch)
+	} else {
+		body, err = fetch(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		preview := string(body)
+		if len(preview) > 100 {
+			preview = preview[:100] + "..."
+		}
+		return fmt.Errorf("error parsing JSON: %v, body preview: %s", err, preview)
+	}
+	return nil
+}
+
+// fetch requests endpoint from the API. A 204 returns an empty body.
+func (m *MySportsFeeds) fetch(ctx context.Context, endpoint, feed string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.SetBasicAuth(m.apiKey, "MYSPORTSFEEDS")
+
+	resp, err := m.client.Do(req, 1+feedBackoff[feed])
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNoContent:
+		return []byte{}, nil
+	default:
+		return nil, fmt.Errorf("API returned non-200 status code: %d, body: %s", resp.StatusCode, string(resp.Body))
+	}
+}
@@ -0,0 +1,91 @@
+// Package sportsdata defines the interface the analytics handlers use to pull
+// team, player and schedule data, along with the MySportsFeeds client and a
+// file-backed fixture provider for running offline.
+//
+// The data types mirror the MySportsFeeds v2.1 JSON layout so fixture files
+// can be captured straight from the API. Other vendors should map their
+// responses onto these types.
+package sportsdata
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Query selects the data a Provider call returns
+type Query struct {
+	League string   // "nba", "mlb"
+	Season string   // Season key, e.g. "2024-2025-regular" or "current"
+	Teams  []string // Team abbreviations, empty for every team
+	Date   string   // YYYYMMDD, or YYYYMMDD-YYYYMMDD for a range
+}
+
+// Provider is a source of sports data
+type Provider interface {
+	// TeamTotals returns season-to-date team stats
+	TeamTotals(ctx context.Context, q Query) ([]TeamStatsTotal, error)
+	// PlayerTotals returns season-to-date (or date-range) player stats
+	PlayerTotals(ctx context.Context, q Query) ([]PlayerStatsTotal, error)
+	// Schedule returns the games matching the query. No games is not an
+	// error; the result is simply empty.
+	Schedule(ctx context.Context, q Query) ([]Game, error)
+	// TeamGameLogs returns per-game team stats
+	TeamGameLogs(ctx context.Context, q Query) ([]TeamGameLog, error)
+	// PlayerGameLogs returns per-game player stats
+	PlayerGameLogs(ctx context.Context, q Query) ([]PlayerGameLog, error)
+}
+
+var (
+	defaultOnce     sync.Once
+	defaultMu       sync.RWMutex
+	defaultProvider Provider
+)
+
+// Default returns the process-wide provider. It is chosen on first use by
+// SPORTSDATA_PROVIDER: "fixture" reads files from SPORTSDATA_FIXTURE_DIR
+// (default data/fixtures), anything else uses MySportsFeeds.
+func Default() Provider {
+	defaultOnce.Do(func() {
+		defaultMu.Lock()
+		defer defaultMu.Unlock()
+		if defaultProvider == nil {
+			defaultProvider = providerFromEnv()
+		}
+	})
+
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultProvider
+}
+
+// SetDefault replaces the process-wide provider
+func SetDefault(p Provider) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultProvider = p
+}
+
+func providerFromEnv() Provider {
+	switch strings.ToLower(os.Getenv("SPORTSDATA_PROVIDER")) {
+	case "fixture", "fixtures":
+		dir := os.Getenv("SPORTSDATA_FIXTURE_DIR")
+		if dir == "" {
+			dir = "data/fixtures"
+		}
+		return NewFixtures(dir)
+	default:
+		return NewMySportsFeeds(os.Getenv("MYSPORTSFEEDS_API_KEY"))
+	}
+}
+
+// ScheduleTeams flattens games into team abbreviations, away team first,
+// so each matchup occupies two consecutive entries
+func ScheduleTeams(games []Game) []string {
+	teams := make([]string, 0, len(games)*2)
+	for _, game := range games {
+		teams = append(teams, game.Schedule.AwayTeam.Abbreviation, game.Schedule.HomeTeam.Abbreviation)
+	}
+	return teams
+}
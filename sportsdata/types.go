@@ -0,0 +1,268 @@
+package sportsdata
+
+// Team identifies a team
+type Team struct {
+	ID           int    `json:"id"`
+	City         string `json:"city"`
+	Name         string `json:"name"`
+	Abbreviation string `json:"abbreviation"`
+	LogoURL      string `json:"officialLogoImageSrc"`
+}
+
+// TeamStatsTotal is a team's stats over a season
+type TeamStatsTotal struct {
+	Team  Team      `json:"team"`
+	Stats TeamStats `json:"stats"`
+}
+
+// TeamStats holds every team stat category the handlers use. Basketball
+// and baseball share it; categories a league doesn't report stay zero.
+type TeamStats struct {
+	GamesPlayed   int           `json:"gamesPlayed"`
+	Standings     Standings     `json:"standings"`
+	FieldGoals    FieldGoals    `json:"fieldGoals"`
+	FreeThrows    FreeThrows    `json:"freeThrows"`
+	Rebounds      Rebounds      `json:"rebounds"`
+	Offense       Offense       `json:"offense"`
+	Defense       Defense       `json:"defense"`
+	Miscellaneous Miscellaneous `json:"miscellaneous"`
+
+	// MLB
+	Batting  Batting  `json:"batting"`
+	Pitching Pitching `json:"pitching"`
+}
+
+type Standings struct {
+	Wins      float64 `json:"wins"`
+	Losses    float64 `json:"losses"`
+	WinPct    float64 `json:"winPct"`
+	GamesBack float64 `json:"gamesBack"`
+	// MLB only
+	RunsFor         float64 `json:"runsFor"`
+	RunsAgainst     float64 `json:"runsAgainst"`
+	RunDifferential float64 `json:"runDifferential"`
+}
+
+type FieldGoals struct {
+	FGMade           float64 `json:"fgMade"`
+	FGAtt            float64 `json:"fgAtt"`
+	FGPct            float64 `json:"fgPct"`
+	FG2PtMade        float64 `json:"fg2PtMade"`
+	FG2PtAtt         float64 `json:"fg2PtAtt"`
+	FG2PtPct         float64 `json:"fg2PtPct"`
+	FG3PtMade        float64 `json:"fg3PtMade"`
+	FG3PtAtt         float64 `json:"fg3PtAtt"`
+	FG3PtPct         float64 `json:"fg3PtPct"`
+	FGMadePerGame    float64 `json:"fgMadePerGame"`
+	FGAttPerGame     float64 `json:"fgAttPerGame"`
+	FG2PtMadePerGame float64 `json:"fg2PtMadePerGame"`
+	FG2PtAttPerGame  float64 `json:"fg2PtAttPerGame"`
+	FG3PtMadePerGame float64 `json:"fg3PtMadePerGame"`
+	FG3PtAttPerGame  float64 `json:"fg3PtAttPerGame"`
+}
+
+type FreeThrows struct {
+	FTMade        float64 `json:"ftMade"`
+	FTAtt         float64 `json:"ftAtt"`
+	FTPct         float64 `json:"ftPct"`
+	FTMadePerGame float64 `json:"ftMadePerGame"`
+	FTAttPerGame  float64 `json:"ftAttPerGame"`
+}
+
+type Rebounds struct {
+	OffReb        float64 `json:"offReb"`
+	DefReb        float64 `json:"defReb"`
+	Reb           float64 `json:"reb"`
+	OffRebPerGame float64 `json:"offRebPerGame"`
+	DefRebPerGame float64 `json:"defRebPerGame"`
+	RebPerGame    float64 `json:"rebPerGame"`
+}
+
+type Offense struct {
+	Pts        float64 `json:"pts"`
+	PtsPerGame float64 `json:"ptsPerGame"`
+	Ast        float64 `json:"ast"`
+	AstPerGame float64 `json:"astPerGame"`
+}
+
+type Defense struct {
+	TOV               float64 `json:"tov"`
+	TOVPerGame        float64 `json:"tovPerGame"`
+	STL               float64 `json:"stl"`
+	STLPerGame        float64 `json:"stlPerGame"`
+	BLK               float64 `json:"blk"`
+	BLKPerGame        float64 `json:"blkPerGame"`
+	BLKAgainst        float64 `json:"blkAgainst"`
+	BLKAgainstPerGame float64 `json:"blkAgainstPerGame"`
+	PtsAgainst        float64 `json:"ptsAgainst"`
+	PtsAgainstPerGame float64 `json:"ptsAgainstPerGame"`
+}
+
+type Miscellaneous struct {
+	Fouls             float64 `json:"fouls"`
+	FoulsPerGame      float64 `json:"foulsPerGame"`
+	FoulsDrawn        float64 `json:"foulsDrawn"`
+	FoulsDrawnPerGame float64 `json:"foulsDrawnPerGame"`
+	FoulPers          float64 `json:"foulPers"`
+	FoulPersPerGame   float64 `json:"foulPersPerGame"`
+	FoulTech          float64 `json:"foulTech"`
+	FoulTechPerGame   float64 `json:"foulTechPerGame"`
+	PlusMinus         float64 `json:"plusMinus"`
+	PlusMinusPerGame  float64 `json:"plusMinusPerGame"`
+}
+
+type Batting struct {
+	AtBats            float64 `json:"atBats"`
+	Runs              float64 `json:"runs"`
+	Hits              float64 `json:"hits"`
+	Homeruns          float64 `json:"homeruns"`
+	RunsBattedIn      float64 `json:"runsBattedIn"`
+	BatterWalks       float64 `json:"batterWalks"`
+	BattingAvg        float64 `json:"battingAvg"`
+	BatterOnBasePct   float64 `json:"batterOnBasePct"`
+	BatterSluggingPct float64 `json:"batterSluggingPct"`
+}
+
+type Pitching struct {
+	InningsPitched    float64 `json:"inningsPitched"`
+	RunsAllowed       float64 `json:"runsAllowed"`
+	EarnedRunsAllowed float64 `json:"earnedRunsAllowed"`
+	EarnedRunAvg      float64 `json:"earnedRunAvg"`
+	PitcherStrikeouts float64 `json:"pitcherStrikeouts"`
+	PitcherWalks      float64 `json:"pitcherWalks"`
+}
+
+// Player identifies a player
+type Player struct {
+	ID               int    `json:"id"`
+	FirstName        string `json:"firstName"`
+	LastName         string `json:"lastName"`
+	PrimaryPosition  string `json:"primaryPosition"`
+	OfficialImageSrc string `json:"officialImageSrc"`
+	CurrentTeam      Team   `json:"currentTeam"`
+}
+
+// PlayerStatsTotal is a player's stats over a season or date range
+type PlayerStatsTotal struct {
+	Player Player      `json:"player"`
+	Team   Team        `json:"team"`
+	Stats  PlayerStats `json:"stats"`
+}
+
+// PlayerStats holds every player stat category the handlers use. Counting
+// stats are integers, unlike the team totals.
+type PlayerStats struct {
+	GamesPlayed   int                 `json:"gamesPlayed"`
+	Offense       PlayerOffense       `json:"offense"`
+	Rebounds      PlayerRebounds      `json:"rebounds"`
+	FreeThrows    PlayerFreeThrows    `json:"freeThrows"`
+	Defense       PlayerDefense       `json:"defense"`
+	FieldGoals    PlayerFieldGoals    `json:"fieldGoals"`
+	Miscellaneous PlayerMiscellaneous `json:"miscellaneous"`
+}
+
+type PlayerOffense struct {
+	Pts        int     `json:"pts"`
+	PtsPerGame float64 `json:"ptsPerGame"`
+	Ast        int     `json:"ast"`
+	AstPerGame float64 `json:"astPerGame"`
+}
+
+type PlayerRebounds struct {
+	Reb        int     `json:"reb"`
+	RebPerGame float64 `json:"rebPerGame"`
+}
+
+type PlayerFreeThrows struct {
+	FtAtt  int `json:"ftAtt"`
+	FtMade int `json:"ftMade"`
+}
+
+type PlayerDefense struct {
+	Blk               int     `json:"blk"`
+	BlkPerGame        float64 `json:"blkPerGame"`
+	BlkAgainst        int     `json:"blkAgainst"`
+	BlkAgainstPerGame float64 `json:"blkAgainstPerGame"`
+	Stl               int     `json:"stl"`
+	StlPerGame        float64 `json:"stlPerGame"`
+	Tov               int     `json:"tov"`
+	TovPerGame        float64 `json:"tovPerGame"`
+}
+
+type PlayerFieldGoals struct {
+	Fg2PtAtt  int     `json:"fg2PtAtt"`
+	Fg2PtMade int     `json:"fg2PtMade"`
+	Fg3PtAtt  int     `json:"fg3PtAtt"`
+	Fg3PtMade int     `json:"fg3PtMade"`
+	FgAtt     int     `json:"fgAtt"`
+	FgMade    int     `json:"fgMade"`
+	Fg3PtPct  float64 `json:"fg3PtPct"`
+}
+
+type PlayerMiscellaneous struct {
+	PlusMinus         int     `json:"plusMinus"`
+	PlusMinusPerGame  float64 `json:"plusMinusPerGame"`
+	MinSeconds        int     `json:"minSeconds"`
+	MinSecondsPerGame float64 `json:"minSecondsPerGame"`
+}
+
+// Game is a scheduled or completed game
+type Game struct {
+	Schedule GameSchedule `json:"schedule"`
+	Score    GameScore    `json:"score"`
+}
+
+type GameSchedule struct {
+	ID           int    `json:"id"`
+	StartTime    string `json:"startTime"`
+	AwayTeam     Team   `json:"awayTeam"`
+	HomeTeam     Team   `json:"homeTeam"`
+	PlayedStatus string `json:"playedStatus"`
+}
+
+type GameScore struct {
+	AwayScoreTotal int `json:"awayScoreTotal"`
+	HomeScoreTotal int `json:"homeScoreTotal"`
+}
+
+// GameRef identifies the game a log entry belongs to
+type GameRef struct {
+	ID                   int    `json:"id"`
+	StartTime            string `json:"startTime"`
+	AwayTeamAbbreviation string `json:"awayTeamAbbreviation"`
+	HomeTeamAbbreviation string `json:"homeTeamAbbreviation"`
+}
+
+// TeamGameLog is one team's stats for one game
+type TeamGameLog struct {
+	Game  GameRef   `json:"game"`
+	Team  Team      `json:"team"`
+	Stats TeamStats `json:"stats"`
+}
+
+// PlayerGameLog is one player's stats for one game
+type PlayerGameLog struct {
+	Game   GameRef     `json:"game"`
+	Player Player      `json:"player"`
+	Team   Team        `json:"team"`
+	Stats  PlayerStats `json:"stats"`
+}
+
+// Response envelopes for each feed
+type (
+	teamStatsTotalsResponse struct {
+		TeamStatsTotals []TeamStatsTotal `json:"teamStatsTotals"`
+	}
+	playerStatsTotalsResponse struct {
+		PlayerStatsTotals []PlayerStatsTotal `json:"playerStatsTotals"`
+	}
+	gamesResponse struct {
+		Games []Game `json:"games"`
+	}
+	teamGameLogsResponse struct {
+		GameLogs []TeamGameLog `json:"gamelogs"`
+	}
+	playerGameLogsResponse struct {
+		GameLogs []PlayerGameLog `json:"gamelogs"`
+	}
+)
@@ -0,0 +1,288 @@
+// Package upstream provides one shared, rate-limited HTTP client per
+// external API. Every request to an upstream goes through its client so
+// the token bucket and quota accounting cover the whole process.
+//
+// Requests are retried with jittered exponential backoff on 429 and 5xx
+// responses, honoring Retry-After when the upstream sends it. Every delay
+// is capped at MaxBackoff. Request contexts are respected throughout, so a
+// client that disconnects stops any waiting or retrying on its behalf.
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Config describes an upstream API
+type Config struct {
+	Name string
+	// RequestsPerMinute is the sustained rate of request cost allowed
+	RequestsPerMinute float64
+	// Burst is the most cost that may be spent at once
+	Burst      int
+	Timeout    time.Duration
+	MaxRetries int
+	// BaseBackoff is the first retry delay, doubled on each attempt
+	BaseBackoff time.Duration
+	// MaxBackoff caps any one retry delay, including one asked for by
+	// Retry-After
+	MaxBackoff time.Duration
+	// UsedHeader and RemainingHeader name response headers carrying the
+	// upstream's own quota counts, when it sends them
+	UsedHeader      string
+	RemainingHeader string
+}
+
+// Client is a rate-limited HTTP client for one upstream
+type Client struct {
+	config  Config
+	http    *http.Client
+	limiter *rate.Limiter
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// Stats is a snapshot of a client's usage
+type Stats struct {
+	Name      string `json:"name"`
+	Requests  int    `json:"requests"`
+	Retries   int    `json:"retries"`
+	Throttled int    `json:"throttled"`
+	Failures  int    `json:"failures"`
+	// CostConsumed is the rate-limit cost spent since startup
+	CostConsumed int `json:"costConsumed"`
+	// QuotaUsed and QuotaRemaining are the upstream's own figures, -1 when
+	// it doesn't report them
+	QuotaUsed      int        `json:"quotaUsed"`
+	QuotaRemaining int        `json:"quotaRemaining"`
+	LastStatus     int        `json:"lastStatus,omitempty"`
+	LastError      string     `json:"lastError,omitempty"`
+	LastRequest    *time.Time `json:"lastRequest,omitempty"`
+
+	RequestsPerMinute float64 `json:"requestsPerMinute"`
+	Burst             int     `json:"burst"`
+}
+
+// Response is a completed upstream response with its body read
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]*Client)
+)
+
+// New creates a client and registers it for metrics. Creating a second
+// client with the same name replaces the first in the registry.
+func New(config Config) *Client {
+	if config.Burst < 1 {
+		config.Burst = 1
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+	if config.BaseBackoff == 0 {
+		config.BaseBackoff = time.Second
+	}
+	if config.MaxBackoff == 0 {
+		config.MaxBackoff = time.Minute
+	}
+
+	client := &Client{
+		config:  config,
+		http:    &http.Client{Timeout: config.Timeout},
+		limiter: rate.NewLimiter(rate.Limit(config.RequestsPerMinute/60), config.Burst),
+		stats: Stats{
+			Name:              config.Name,
+			QuotaUsed:         -1,
+			QuotaRemaining:    -1,
+			RequestsPerMinute: config.RequestsPerMinute,
+			Burst:             config.Burst,
+		},
+	}
+
+	registryMu.Lock()
+	registry[config.Name] = client
+	registryMu.Unlock()
+	return client
+}
+
+// Get sends a GET request for url
+func (c *Client) Get(ctx context.Context, url string, cost int) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	return c.Do(req, cost)
+}
+
+// Do sends req, waiting for cost tokens before each attempt. Requests with
+// a body are not retried. The response is returned for any final status;
+// only transport failures and cancellation are errors.
+func (c *Client) Do(req *http.Request, cost int) (*Response, error) {
+	ctx := req.Context()
+	if cost < 1 {
+		cost = 1
+	}
+	if cost > c.config.Burst {
+		cost = c.config.Burst
+	}
+
+	retryable := req.Body == nil || req.GetBody != nil
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.WaitN(ctx, cost); err != nil {
+			return nil, c.fail(fmt.Errorf("waiting for %s rate limit: %v", c.config.Name, err))
+		}
+
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, c.fail(err)
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := c.http.Do(attemptReq)
+		c.record(cost, resp)
+		if err != nil {
+			return nil, c.fail(fmt.Errorf("error making request: %v", err))
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, c.fail(fmt.Errorf("error reading response: %v", err))
+		}
+
+		if !shouldRetry(resp.StatusCode) || !retryable || attempt >= c.config.MaxRetries {
+			return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+		}
+
+		delay := c.backoff(attempt)
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			delay = retryAfter
+		}
+		// An upstream asking for hours would otherwise stall the request,
+		// or a job, that long
+		delay = min(delay, c.config.MaxBackoff)
+
+		c.mu.Lock()
+		c.stats.Retries++
+		c.mu.Unlock()
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, c.fail(ctx.Err())
+		}
+	}
+}
+
+// Stats returns a snapshot of the client's usage
+func (c *Client) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// AllStats returns usage for every registered client, sorted by name
+func AllStats() []Stats {
+	registryMu.Lock()
+	clients := make([]*Client, 0, len(registry))
+	for _, client := range registry {
+		clients = append(clients, client)
+	}
+	registryMu.Unlock()
+
+	stats := make([]Stats, 0, len(clients))
+	for _, client := range clients {
+		stats = append(stats, client.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+func (c *Client) record(cost int, resp *http.Response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Requests++
+	c.stats.CostConsumed += cost
+	now := time.Now()
+	c.stats.LastRequest = &now
+	if resp == nil {
+		return
+	}
+
+	c.stats.LastStatus = resp.StatusCode
+	if resp.StatusCode == http.StatusTooManyRequests {
+		c.stats.Throttled++
+	}
+	if c.config.UsedHeader != "" {
+		if used, err := strconv.Atoi(resp.Header.Get(c.config.UsedHeader)); err == nil {
+			c.stats.QuotaUsed = used
+		}
+	}
+	if c.config.RemainingHeader != "" {
+		if remaining, err := strconv.Atoi(resp.Header.Get(c.config.RemainingHeader)); err == nil {
+			c.stats.QuotaRemaining = remaining
+		}
+	}
+}
+
+func (c *Client) fail(err error) error {
+	c.mu.Lock()
+	c.stats.Failures++
+	c.stats.LastError = err.Error()
+	c.mu.Unlock()
+	return err
+}
+
+// backoff returns the delay before retry attempt+1: exponential, with up to
+// 50% jitter either way so concurrent callers don't retry in lockstep
+func (c *Client) backoff(attempt int) time.Duration {
+	delay := float64(c.config.BaseBackoff) * math.Pow(2, float64(attempt))
+	jitter := 0.5 + rand.Float64()
+	return time.Duration(delay * jitter)
+}
+
+func shouldRetry(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// parseRetryAfter reads a Retry-After header in either seconds or HTTP date
+// form
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(at); delay > 0 {
+			return delay, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,38 @@
+package upstream
+
+import "time"
+
+// Shared clients for each upstream API
+var (
+	// MySportsFeeds charges 1 point per request plus the feed's backoff and
+	// allows roughly 100 points a minute; we stay under that
+	MySportsFeeds = New(Config{
+		Name:              "mysportsfeeds",
+		RequestsPerMinute: 90,
+		Burst:             10,
+		Timeout:           45 * time.Second,
+		MaxRetries:        3,
+	})
+
+	// OddsAPI bills against a monthly credit quota, which it reports in
+	// response headers
+	OddsAPI = New(Config{
+		Name:              "oddsapi",
+		RequestsPerMinute: 30,
+		Burst:             5,
+		Timeout:           15 * time.Second,
+		MaxRetries:        2,
+		UsedHeader:        "x-requests-used",
+		RemainingHeader:   "x-requests-remaining",
+	})
+
+	// NHL is the league's public api-web.nhle.com. It has no published
+	// limit, so we keep well clear of anything that looks like scraping.
+	NHL = New(Config{
+		Name:              "nhl",
+		RequestsPerMinute: 120,
+		Burst:             10,
+		Timeout:           20 * time.Second,
+		MaxRetries:        3,
+	})
+)
@@ -0,0 +1,92 @@
+package xgmodel
+
+import (
+	"math"
+	"sort"
+)
+
+// Calibration compares predicted goal probabilities with what happened
+type Calibration struct {
+	Seasons []string `json:"seasons,omitempty"`
+	Samples int      `json:"samples"`
+	Goals   int      `json:"goals"`
+	// XGoals is the sum of the predictions, to compare with Goals
+	XGoals  float64  `json:"xGoals"`
+	LogLoss float64  `json:"logLoss"`
+	Brier   float64  `json:"brier"`
+	Deciles []Decile `json:"deciles"`
+}
+
+// Decile is one tenth of the shots, ordered by predicted probability. A
+// well-calibrated model has MeanPredicted close to ObservedRate in each.
+type Decile struct {
+	Decile        int     `json:"decile"`
+	Shots         int     `json:"shots"`
+	Goals         int     `json:"goals"`
+	MinPredicted  float64 `json:"minPredicted"`
+	MaxPredicted  float64 `json:"maxPredicted"`
+	MeanPredicted float64 `json:"meanPredicted"`
+	ObservedRate  float64 `json:"observedRate"`
+}
+
+// Calibrate scores predictions against outcomes. predictions and goals
+// must be the same length.
+func Calibrate(predictions []float64, goals []bool) *Calibration {
+	calibration := &Calibration{Samples: len(predictions), Deciles: []Decile{}}
+	if len(predictions) == 0 {
+		return calibration
+	}
+
+	order := make([]int, len(predictions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return predictions[order[a]] < predictions[order[b]]
+	})
+
+	logLoss, brier := 0.0, 0.0
+	for i, p := range predictions {
+		y := boolFeature(goals[i])
+		clamped := math.Min(math.Max(p, 1e-15), 1-1e-15)
+		logLoss -= y*math.Log(clamped) + (1-y)*math.Log(1-clamped)
+		brier += (p - y) * (p - y)
+		calibration.XGoals += p
+		if goals[i] {
+			calibration.Goals++
+		}
+	}
+	n := float64(len(predictions))
+	calibration.LogLoss = round(logLoss/n, 5)
+	calibration.Brier = round(brier/n, 5)
+	calibration.XGoals = round(calibration.XGoals, 1)
+
+	for d := 0; d < 10; d++ {
+		start, end := d*len(order)/10, (d+1)*len(order)/10
+		if start == end {
+			continue
+		}
+		decile := Decile{
+			Decile:       d + 1,
+			Shots:        end - start,
+			MinPredicted: round(predictions[order[start]], 4),
+			MaxPredicted: round(predictions[order[end-1]], 4),
+		}
+		sum := 0.0
+		for _, i := range order[start:end] {
+			sum += predictions[i]
+			if goals[i] {
+				decile.Goals++
+			}
+		}
+		decile.MeanPredicted = round(sum/float64(decile.Shots), 4)
+		decile.ObservedRate = round(float64(decile.Goals)/float64(decile.Shots), 4)
+		calibration.Deciles = append(calibration.Deciles, decile)
+	}
+	return calibration
+}
+
+func round(val float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(val*scale) / scale
+}
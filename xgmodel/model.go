@@ -0,0 +1,144 @@
+// Package xgmodel is an expected-goals model for NHL shots: a logistic
+// regression on shot location, type, rush/rebound context and skater
+// strength, trained from the MoneyPuck shot file and stored as JSON.
+package xgmodel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/KPWithCode/statpad2/logistic"
+)
+
+// ErrNoModel is returned by Load when no model has been trained yet
+var ErrNoModel = errors.New("no xG model has been trained")
+
+// Shot is the information the model scores
+type Shot struct {
+	Distance float64 `json:"distance"` // Feet from the net
+	Angle    float64 `json:"angle"`    // Degrees off the center line, either side
+	ShotType string  `json:"shotType"` // MoneyPuck code: WRIST, SNAP, SLAP, BACK, TIP, DEFL, WRAP
+	Rush     bool    `json:"rush"`
+	Rebound  bool    `json:"rebound"`
+	// SkaterAdvantage is the shooting team's skaters minus the defending
+	// team's: 1 on a 5v4 power play, -1 shorthanded
+	SkaterAdvantage int `json:"skaterAdvantage"`
+}
+
+// shotTypes get a feature each; anything else (mostly WRIST) is the baseline
+var shotTypes = []string{"SNAP", "SLAP", "BACK", "TIP", "DEFL", "WRAP"}
+
+// FeatureNames lists the model inputs in the order features returns them
+func FeatureNames() []string {
+	names := []string{"distance", "logDistance", "angle", "angleSquared", "rush", "rebound", "powerPlay", "shortHanded"}
+	for _, shotType := range shotTypes {
+		names = append(names, "type"+shotType)
+	}
+	return names
+}
+
+func features(s Shot) []float64 {
+	distance := math.Max(s.Distance, 0)
+	angle := math.Abs(s.Angle)
+
+	x := []float64{
+		distance,
+		math.Log1p(distance),
+		angle,
+		angle * angle,
+		boolFeature(s.Rush),
+		boolFeature(s.Rebound),
+		boolFeature(s.SkaterAdvantage > 0),
+		boolFeature(s.SkaterAdvantage < 0),
+	}
+	shotType := strings.ToUpper(s.ShotType)
+	for _, t := range shotTypes {
+		x = append(x, boolFeature(shotType == t))
+	}
+	return x
+}
+
+func boolFeature(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Model is a trained expected-goals model
+type Model struct {
+	TrainedAt time.Time `json:"trainedAt"`
+	Seasons   []string  `json:"seasons"`
+	Samples   int       `json:"samples"`
+	Goals     int       `json:"goals"`
+	Lambda    float64   `json:"lambda"`
+
+	Features []string `json:"features"`
+	// Inputs are standardized with Means and Scales before the weights
+	// are applied
+	Means     []float64 `json:"means"`
+	Scales    []float64 `json:"scales"`
+	Intercept float64   `json:"intercept"`
+	Weights   []float64 `json:"weights"`
+
+	// Calibration is measured on held-out shots when there are any
+	Calibration *Calibration `json:"calibration,omitempty"`
+}
+
+// Score returns the probability that s is a goal
+func (m *Model) Score(s Shot) float64 {
+	z := m.Intercept
+	for i, value := range features(s) {
+		z += m.Weights[i] * (value - m.Means[i]) / m.Scales[i]
+	}
+	return logistic.Sigmoid(z)
+}
+
+// Save writes the model to path as JSON
+func (m *Model) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding xG model: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("error creating %s: %v", filepath.Dir(path), err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing xG model: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Load reads a model saved by Save. A model trained on a different
+// feature set is rejected rather than scored wrongly.
+func Load(path string) (*Model, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoModel
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading xG model: %v", err)
+	}
+
+	var m Model
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("error parsing xG model %s: %v", path, err)
+	}
+
+	names := FeatureNames()
+	if strings.Join(m.Features, ",") != strings.Join(names, ",") {
+		return nil, fmt.Errorf("xG model %s was trained on a different feature set, retrain it", path)
+	}
+	if len(m.Weights) != len(names) || len(m.Means) != len(names) || len(m.Scales) != len(names) {
+		return nil, fmt.Errorf("xG model %s is incomplete, retrain it", path)
+	}
+	return &m, nil
+}
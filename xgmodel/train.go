@@ -0,0 +1,56 @@
+package xgmodel
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/KPWithCode/statpad2/logistic"
+)
+
+// DefaultLambda is the L2 penalty on the standardized weights
+const DefaultLambda = 1.0
+
+// Sample is a training shot and whether it went in
+type Sample struct {
+	Shot Shot
+	Goal bool
+}
+
+// Train fits the model's logistic regression to samples with an L2
+// penalty of lambda.
+func Train(samples []Sample, lambda float64) (*Model, error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no shots to train on")
+	}
+
+	rows := make([][]float64, len(samples))
+	goals := make([]bool, len(samples))
+	scored := 0
+	for i, sample := range samples {
+		rows[i] = features(sample.Shot)
+		goals[i] = sample.Goal
+		if sample.Goal {
+			scored++
+		}
+	}
+	if scored == 0 || scored == len(samples) {
+		return nil, fmt.Errorf("training shots need both goals and non-goals")
+	}
+
+	fit, err := logistic.Fit(rows, goals, lambda)
+	if err != nil {
+		return nil, fmt.Errorf("xG training failed: %v", err)
+	}
+
+	return &Model{
+		TrainedAt: time.Now().UTC(),
+		Samples:   len(samples),
+		Goals:     scored,
+		Lambda:    lambda,
+		Features:  FeatureNames(),
+		Means:     fit.Means,
+		Scales:    fit.Scales,
+		Intercept: fit.Intercept,
+		Weights:   fit.Weights,
+	}, nil
+}